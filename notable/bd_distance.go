@@ -20,10 +20,19 @@ import (
 	"github.com/paulmach/orb/geo"
 )
 
+const (
+	// The data to analyze
+	dataFile = "fb_struct_cols.ncol"
+)
+
 var (
 	// Raw data, map from person's name to birth and death
 	// locations
 	rdata map[string]*loct
+
+	// Determines how records with missing years or coordinates are
+	// treated
+	policy notable.MissingPolicy
 )
 
 // Location information for one person
@@ -48,28 +57,51 @@ func makeColix(head []string) map[string]int {
 // here.
 func readData(first, last int) {
 
-	f, g, dec := notable.GetGobDecoder("fb_struct_cols.gob.gz")
-	defer f.Close()
-	defer g.Close()
-
-	var people notable.People
-	if err := dec.Decode(&people); err != nil {
+	cf, err := notable.OpenColumnFile(dataFile)
+	if err != nil {
 		panic(err)
 	}
+	defer cf.Close()
 
-	// Populate rdata
+	// Apply the missing value policy to the fields used here
+	pol := policy
+	pol.Fields = []notable.Field{notable.FieldBYear, notable.FieldBLocLat, notable.FieldBLocLong,
+		notable.FieldDLocLat, notable.FieldDLocLong}
+
+	// Populate rdata, reading one row group at a time and skipping
+	// the columns that are not needed
 	rdata = make(map[string]*loct)
-	for i := 0; i < len(people.PrsLabel); i++ {
+	for g := 0; g < cf.NumGroups(); g++ {
 
-		if people.BYear[i] < first || people.BYear[i] > last {
-			continue
+		people, err := cf.ReadGroup(g, append(pol.Fields, notable.FieldPrsLabel)...)
+		if err != nil {
+			panic(err)
+		}
+		people = pol.ApplyPeople(people)
+
+		for i := 0; i < people.Len(); i++ {
+
+			// When missing values are kept, a person with a missing
+			// year cannot be placed in the selected range, and a
+			// person with a missing coordinate has no distance.
+			na := false
+			for _, f := range pol.Fields {
+				na = na || people.IsNA(f, i)
+			}
+			if na {
+				continue
+			}
+
+			if people.BYear[i] < first || people.BYear[i] > last {
+				continue
+			}
+
+			// Convert the coordinates to Point objects
+			birthloc := orb.Point{people.BLocLat[i], people.BLocLong[i]}
+			deathloc := orb.Point{people.DLocLat[i], people.DLocLong[i]}
+
+			rdata[people.PrsLabel[i]] = &loct{BirthLoc: birthloc, DeathLoc: deathloc}
 		}
-
-		// Convert the coordinates to Point objects
-		birthloc := orb.Point{people.BLocLat[i], people.BLocLong[i]}
-		deathloc := orb.Point{people.DLocLat[i], people.DLocLong[i]}
-
-		rdata[people.PrsLabel[i]] = &loct{BirthLoc: birthloc, DeathLoc: deathloc}
 	}
 }
 
@@ -114,8 +146,22 @@ func main() {
 	var first, last int
 	flag.IntVar(&first, "first", -100000, "First year of data selection")
 	flag.IntVar(&last, "last", 100000, "Last year of data selection")
+	missing := flag.String("missing", "keep", "Treatment of missing values: keep, drop or impute")
 	flag.Parse()
 
+	action, err := notable.ParseMissingAction(*missing)
+	if err != nil {
+		panic(err)
+	}
+	policy.Action = action
+
+	// Missing values are imputed using the mean of each field
+	if action == notable.ImputeMissing {
+		if policy.Fill, err = notable.Means(dataFile); err != nil {
+			panic(err)
+		}
+	}
+
 	readData(first, last)
 	getDistances()
 	summaries()
@@ -0,0 +1,588 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+)
+
+// convertOpts holds the flags of the convert command.
+var convertOpts struct {
+	from    string
+	in      string
+	sheet   string
+	to      string
+	out     string
+	prefix  string
+	missing string
+	workers int
+	buffer  int
+	quiet   bool
+}
+
+var convertCmd = &command{
+	short: "Convert the data from the Data S1 workbook, or any converted file, to other formats",
+	flags: func(fs *flag.FlagSet) {
+		o := &convertOpts
+		fs.StringVar(&o.from, "from", "", "Format of the input: xlsx, or one of the output formats (detected if empty)")
+		fs.StringVar(&o.in, "in", "SchichDataS1_FB.xlsx", "The input file")
+		fs.StringVar(&o.sheet, "sheet", "FB", "The sheet to read from an xlsx workbook, by name or by position counting from 0")
+		fs.StringVar(&o.to, "to", "csv,json,gob,struct,cols,ncol",
+			"Comma-separated list of output formats: "+strings.Join(outputNames(), ", "))
+		fs.StringVar(&o.out, "out", ".", "The directory in which the output files are written")
+		fs.StringVar(&o.prefix, "prefix", "fb", "The start of each output file name")
+		fs.StringVar(&o.missing, "missing", "keep", "Treatment of missing values in typed outputs: keep, drop or impute")
+		fs.IntVar(&o.workers, "workers", 0, "Number of goroutines parsing a converted input (0 for one per CPU)")
+		fs.IntVar(&o.buffer, "buffer", 1024, "Number of records waiting to be written to each output")
+		fs.BoolVar(&o.quiet, "quiet", false, "Do not display progress")
+	},
+	run: runConvert,
+}
+
+// An output is one of the formats that convert can write.
+type output struct {
+
+	// The name of the format, as given to --to
+	name string
+
+	// Appended to the prefix to make the file name
+	suffix string
+
+	// Creates a sink writing the format to the named file
+	create func(fname string) (sink, error)
+}
+
+// outputs holds the output formats.  The file names are those used by
+// the convert scripts, so the analysis scripts can read them.
+var outputs = []output{
+	{"csv", ".csv.gz", newCSVSink},
+	{"json", ".json.gz", newJSONSink},
+	{"gob", ".gob.gz", newGobSink},
+	{"json-struct", "_struct.json.gz", newJSONStructSink},
+	{"struct", "_struct.gob.gz", newStructSink},
+	{"cols", "_struct_cols.gob.gz", newColsSink},
+	{"ncol", "_struct_cols.ncol", newNcolSink},
+}
+
+// outputNames returns the names of the output formats.
+func outputNames() []string {
+	var names []string
+	for _, o := range outputs {
+		names = append(names, o.name)
+	}
+	return names
+}
+
+// A sink writes the records to one output file.  Row-oriented formats
+// (csv, json and gob) are exact copies of the input rows, starting with
+// the header.  Typed formats hold Person values, and are given a nil
+// person for the header and for records dropped by the missing value
+// policy.
+type sink interface {
+	write(row []string, person *notable.Person) error
+	close() error
+
+	// Records the checksum of the input file in the output
+	setSource(fname string) error
+}
+
+// A source yields the input records.  The first call to next returns
+// the header row and a nil person.  Later calls return a data row and
+// the corresponding Person, or io.EOF when there are no more records.
+type source interface {
+	next() ([]string, *notable.Person, error)
+
+	// The number of data rows, or 0 if not known
+	total() int
+
+	// The number of values of the field that could not be parsed,
+	// and were treated as missing, in the records read so far
+	invalid(f notable.Field) int
+
+	close() error
+}
+
+func runConvert(fs *flag.FlagSet) error {
+
+	o := &convertOpts
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	// Find the requested outputs
+	var outs []output
+	var fnames []string
+	for _, name := range strings.Split(o.to, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, out := range outputs {
+			if out.name == name {
+				outs = append(outs, out)
+				fnames = append(fnames, filepath.Join(o.out, o.prefix+out.suffix))
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown output format %q", name)
+		}
+	}
+	for _, fname := range fnames {
+		if sameFile(fname, o.in) {
+			return fmt.Errorf("output %s would overwrite the input", fname)
+		}
+	}
+
+	var policy notable.MissingPolicy
+	action, err := notable.ParseMissingAction(o.missing)
+	if err != nil {
+		return err
+	}
+	policy.Action = action
+
+	// Open the input
+	var src source
+	if o.from == "xlsx" || (o.from == "" && strings.HasSuffix(strings.ToLower(o.in), ".xlsx")) {
+		if action == notable.ImputeMissing {
+			return fmt.Errorf("missing values cannot be imputed when reading a workbook; convert it first")
+		}
+		src, err = openXLSXSource(o.in, o.sheet)
+	} else {
+		if action == notable.ImputeMissing {
+			if policy.Fill, err = notable.Means(o.in); err != nil {
+				return err
+			}
+		}
+		src, err = openReaderSource(o.in, o.from, o.workers)
+	}
+	if err != nil {
+		return err
+	}
+	defer src.close()
+
+	if err := os.MkdirAll(o.out, 0755); err != nil {
+		return err
+	}
+
+	// Create the outputs.  A constructor can fail after creating its
+	// file, so the file being created is removed along with the
+	// earlier ones.
+	var sinks []sink
+	for i, out := range outs {
+		s, err := out.create(fnames[i])
+		if err == nil {
+			sinks = append(sinks, s)
+			err = s.setSource(o.in)
+		}
+		if err != nil {
+			for _, s := range sinks {
+				s.close()
+			}
+			removeAll(fnames[0 : i+1])
+			return err
+		}
+	}
+	fan := newFanout(sinks, o.buffer)
+
+	var w io.Writer = os.Stderr
+	if o.quiet {
+		w = nil
+	}
+	prog := newProgress(w, "convert", src.total())
+
+	// Outputs that were stopped part way through are removed, so that
+	// they are not mistaken for complete files
+	fail := func(err error) error {
+		prog.done()
+		fan.close()
+		removeAll(fnames)
+		return err
+	}
+
+	// Read the input once, copying each record to all the outputs
+	var nrec, nkept int
+	for {
+		row, person, err := src.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fail(err)
+		}
+
+		if person != nil {
+			nrec++
+			if policy.Apply(person) {
+				nkept++
+			} else {
+				person = nil
+			}
+			prog.add(1)
+		}
+
+		if err := fan.write(row, person); err != nil {
+			return fail(err)
+		}
+	}
+	prog.done()
+
+	if err := fan.close(); err != nil {
+		removeAll(fnames)
+		return err
+	}
+
+	for i, out := range outs {
+		n := nrec
+		if out.typed() {
+			n = nkept
+		}
+		fmt.Printf("Wrote %d records to %s\n", n, fnames[i])
+	}
+
+	// Values that could not be parsed are missing in the typed
+	// outputs, and copied as they are to the others
+	for _, f := range notable.Fields() {
+		if n := src.invalid(f); n > 0 {
+			fmt.Printf("%d values of %s could not be parsed, and were treated as missing\n", n, f)
+		}
+	}
+
+	return nil
+}
+
+// removeAll removes the named files, ignoring errors.
+func removeAll(fnames []string) {
+	for _, fname := range fnames {
+		os.Remove(fname)
+	}
+}
+
+// typed returns true if the output holds Person values.
+func (o output) typed() bool {
+	switch o.name {
+	case "csv", "json", "gob":
+		return false
+	default:
+		return true
+	}
+}
+
+// sameFile returns true if the two paths name the same existing file.
+func sameFile(a, b string) bool {
+
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+
+	fb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(fa, fb)
+}
+
+// xlsxSource streams the rows of a sheet of an Excel workbook.
+type xlsxSource struct {
+
+	// The sheet being read
+	sr *notable.SheetReader
+
+	// The number of rows read
+	nr int
+
+	// Converts rows to Person values, bound to the header row
+	mapper *notable.RowMapper
+}
+
+// openXLSXSource opens a sheet of an Excel workbook, given by name or
+// position.
+func openXLSXSource(fname, sheet string) (*xlsxSource, error) {
+
+	sr, err := notable.OpenSheet(fname, sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &xlsxSource{sr: sr}, nil
+}
+
+func (s *xlsxSource) next() ([]string, *notable.Person, error) {
+
+	row, err := s.sr.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	s.nr++
+
+	if s.mapper == nil {
+		if s.mapper, err = notable.DefaultSchema().Bind(row); err != nil {
+			return nil, nil, err
+		}
+		return row, nil, nil
+	}
+
+	person, err := s.mapper.Person(row)
+	if err != nil {
+		return nil, nil, fmt.Errorf("row %d: %v", s.nr, err)
+	}
+
+	return row, &person, nil
+}
+
+func (s *xlsxSource) total() int {
+	if n, _ := s.sr.Dims(); n > 0 {
+		return n - 1
+	}
+	return 0
+}
+
+func (s *xlsxSource) invalid(f notable.Field) int {
+	if s.mapper == nil {
+		return 0
+	}
+	return s.mapper.Invalid(f)
+}
+
+func (s *xlsxSource) close() error {
+	return s.sr.Close()
+}
+
+// readerSource reads any of the files written by convert.
+type readerSource struct {
+	rdr    *notable.Reader
+	header bool
+}
+
+// openReaderSource opens the named file, checking that its format is
+// the expected one if from is not empty.
+func openReaderSource(fname, from string, workers int) (*readerSource, error) {
+
+	rdr, err := notable.NewParallelReader(fname, notable.Pipeline{Workers: workers})
+	if err != nil {
+		return nil, err
+	}
+
+	if from != "" && rdr.Format().String() != from {
+		rdr.Close()
+		return nil, fmt.Errorf("%s is in %s format, not %s", fname, rdr.Format(), from)
+	}
+
+	return &readerSource{rdr: rdr}, nil
+}
+
+func (s *readerSource) next() ([]string, *notable.Person, error) {
+
+	if !s.header {
+		s.header = true
+		var header []string
+		for _, f := range notable.Fields() {
+			header = append(header, f.String())
+		}
+		return header, nil, nil
+	}
+
+	if !s.rdr.Next() {
+		if err := s.rdr.Err(); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, io.EOF
+	}
+
+	person := s.rdr.Person()
+	return person.Strings(), &person, nil
+}
+
+func (s *readerSource) total() int {
+	return 0
+}
+
+func (s *readerSource) invalid(f notable.Field) int {
+	return s.rdr.Invalid(f)
+}
+
+func (s *readerSource) close() error {
+	return s.rdr.Close()
+}
+
+// csvSink writes rows in CSV format.
+type csvSink struct {
+	w *notable.CSVWriter
+}
+
+func newCSVSink(fname string) (sink, error) {
+	w, err := notable.NewCSVWriter(fname)
+	return &csvSink{w}, err
+}
+
+func (s *csvSink) write(row []string, _ *notable.Person) error {
+	return s.w.Write(row)
+}
+
+func (s *csvSink) setSource(fname string) error {
+	return s.w.SetSource(fname)
+}
+
+func (s *csvSink) close() error {
+	return s.w.Close()
+}
+
+// jsonSink writes rows as json arrays.
+type jsonSink struct {
+	enc *notable.JSONEncoder
+}
+
+func newJSONSink(fname string) (sink, error) {
+	enc, err := notable.NewJSONEncoder(fname)
+	return &jsonSink{enc}, err
+}
+
+func (s *jsonSink) write(row []string, _ *notable.Person) error {
+	return s.enc.Encode(row)
+}
+
+func (s *jsonSink) setSource(fname string) error {
+	return s.enc.SetSource(fname)
+}
+
+func (s *jsonSink) close() error {
+	return s.enc.Close()
+}
+
+// gobSink writes rows as gob-encoded slices of strings.
+type gobSink struct {
+	enc *notable.GobEncoder
+}
+
+func newGobSink(fname string) (sink, error) {
+	enc, err := notable.NewGobEncoder(fname)
+	return &gobSink{enc}, err
+}
+
+func (s *gobSink) write(row []string, _ *notable.Person) error {
+	return s.enc.Encode(row)
+}
+
+func (s *gobSink) setSource(fname string) error {
+	return s.enc.SetSource(fname)
+}
+
+func (s *gobSink) close() error {
+	return s.enc.Close()
+}
+
+// jsonStructSink writes json-encoded Person values.
+type jsonStructSink struct {
+	enc *notable.JSONEncoder
+}
+
+func newJSONStructSink(fname string) (sink, error) {
+	enc, err := notable.NewJSONEncoder(fname)
+	return &jsonStructSink{enc}, err
+}
+
+func (s *jsonStructSink) write(_ []string, person *notable.Person) error {
+	if person == nil {
+		return nil
+	}
+	return s.enc.Encode(person)
+}
+
+func (s *jsonStructSink) setSource(fname string) error {
+	return s.enc.SetSource(fname)
+}
+
+func (s *jsonStructSink) close() error {
+	return s.enc.Close()
+}
+
+// structSink writes gob-encoded Person values.
+type structSink struct {
+	enc *notable.GobEncoder
+}
+
+func newStructSink(fname string) (sink, error) {
+	enc, err := notable.NewGobEncoder(fname)
+	return &structSink{enc}, err
+}
+
+func (s *structSink) write(_ []string, person *notable.Person) error {
+	if person == nil {
+		return nil
+	}
+	return s.enc.Encode(person)
+}
+
+func (s *structSink) setSource(fname string) error {
+	return s.enc.SetSource(fname)
+}
+
+func (s *structSink) close() error {
+	return s.enc.Close()
+}
+
+// colsSink collects the records into a People value, which is written
+// as a single gob value when the sink is closed.
+type colsSink struct {
+	enc    *notable.GobEncoder
+	people notable.People
+}
+
+func newColsSink(fname string) (sink, error) {
+	enc, err := notable.NewGobEncoder(fname)
+	return &colsSink{enc: enc}, err
+}
+
+func (s *colsSink) write(_ []string, person *notable.Person) error {
+	if person != nil {
+		s.people.Append(*person)
+	}
+	return nil
+}
+
+func (s *colsSink) setSource(fname string) error {
+	return s.enc.SetSource(fname)
+}
+
+func (s *colsSink) close() error {
+
+	err := s.people.Validate()
+	if err == nil {
+		err = s.enc.Encode(&s.people)
+	}
+
+	if cerr := s.enc.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// ncolSink writes a column file.
+type ncolSink struct {
+	w *notable.ColumnWriter
+}
+
+func newNcolSink(fname string) (sink, error) {
+	w, err := notable.NewColumnWriter(fname, notable.DefaultGroupSize)
+	return &ncolSink{w}, err
+}
+
+func (s *ncolSink) write(_ []string, person *notable.Person) error {
+	if person == nil {
+		return nil
+	}
+	return s.w.Write(*person)
+}
+
+func (s *ncolSink) setSource(fname string) error {
+	return s.w.SetSource(fname)
+}
+
+func (s *ncolSink) close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+)
+
+// The input of the tests, in which Imhotep's birth year cannot be
+// parsed.
+const testCSV = `PrsLabel,BYear,BLocLabel,BLocLat,BLocLong,DYear,DLocLabel,DLocLat,DLocLong,Gender
+Ada,1815,London,51.5,-0.1,1852,London,51.5,-0.1,female
+Emmy,1882,Erlangen,49.6,11,,Bryn Mawr,40,-75.3,female
+Imhotep,c. 2650 BC,Memphis,,,,,,,male
+`
+
+// testPeople returns the people in testCSV.
+func testPeople() []notable.Person {
+
+	persons := []notable.Person{
+		{PrsLabel: "Ada", BYear: 1815, BLocLabel: "London", BLocLat: 51.5, BLocLong: -0.1,
+			DYear: 1852, DLocLabel: "London", DLocLat: 51.5, DLocLong: -0.1, Gender: "female"},
+		{PrsLabel: "Emmy", BYear: 1882, BLocLabel: "Erlangen", BLocLat: 49.6, BLocLong: 11,
+			DLocLabel: "Bryn Mawr", DLocLat: 40, DLocLong: -75.3, Gender: "female"},
+		{PrsLabel: "Imhotep", BLocLabel: "Memphis", Gender: "male"},
+	}
+	persons[1].SetNA(notable.FieldDYear)
+	for _, f := range []notable.Field{notable.FieldBYear, notable.FieldBLocLat, notable.FieldBLocLong,
+		notable.FieldDYear, notable.FieldDLocLat, notable.FieldDLocLong} {
+		persons[2].SetNA(f)
+	}
+
+	return persons
+}
+
+// convert runs the convert command with the given flags.
+func convert(args ...string) error {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	convertCmd.flags(fs)
+	if err := fs.Parse(append(args, "-quiet")); err != nil {
+		return err
+	}
+	return runConvert(fs)
+}
+
+// Every output holds the records of the input.
+func TestConvertRoundTrip(t *testing.T) {
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "people.csv")
+	if err := os.WriteFile(in, []byte(testCSV), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "out")
+	if err := convert("-in", in, "-out", out, "-to", strings.Join(outputNames(), ",")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := testPeople()
+	for _, o := range outputs {
+		fname := filepath.Join(out, "fb"+o.suffix)
+		people, err := notable.ReadPeople(fname)
+		if err != nil {
+			t.Errorf("%s: %v", o.name, err)
+			continue
+		}
+		if people.Len() != len(want) {
+			t.Errorf("%s: %d records, want %d", o.name, people.Len(), len(want))
+			continue
+		}
+		for i := range want {
+			if got := people.Row(i); !reflect.DeepEqual(got, want[i]) {
+				t.Errorf("%s: row %d is %+v, want %+v", o.name, i, got, want[i])
+			}
+		}
+	}
+
+	// The year that could not be parsed is counted
+	src, err := openReaderSource(in, "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.close()
+	for {
+		if _, _, err := src.next(); err != nil {
+			break
+		}
+	}
+	if n := src.invalid(notable.FieldBYear); n != 1 {
+		t.Errorf("%d invalid birth years, want 1", n)
+	}
+}
+
+// Outputs are removed if any of them cannot be created, including one
+// whose constructor fails after creating its file.
+func TestConvertCreateError(t *testing.T) {
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "people.csv")
+	if err := os.WriteFile(in, []byte(testCSV), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := outputs
+	defer func() { outputs = saved }()
+	outputs = append(outputs[0:len(outputs):len(outputs)], output{"broken", "_broken.gob.gz",
+		func(fname string) (sink, error) {
+			if err := os.WriteFile(fname, nil, 0644); err != nil {
+				return nil, err
+			}
+			return nil, errors.New("broken")
+		}})
+
+	out := filepath.Join(dir, "out")
+	if err := convert("-in", in, "-out", out, "-to", "csv,broken"); err == nil {
+		t.Fatalf("no error from a broken output")
+	}
+
+	for _, name := range []string{"fb.csv.gz", "fb_broken.gob.gz"} {
+		if _, err := os.Stat(filepath.Join(out, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+)
+
+// record is one row of the input, on its way to the sinks.
+type record struct {
+	row    []string
+	person *notable.Person
+}
+
+// fanout writes each record to several sinks at once.  Every sink
+// runs on its own goroutine, fed through a buffered channel, so that
+// a slow sink (such as a compressed json file) does not hold up the
+// others until its buffer is full.
+type fanout struct {
+
+	// The channel feeding each sink
+	chans []chan record
+
+	// Waits for the sink goroutines to finish
+	wg sync.WaitGroup
+
+	// Guards err
+	mu sync.Mutex
+
+	// The first error reported by a sink
+	err error
+}
+
+// newFanout starts a goroutine for each sink, with room for buffer
+// records waiting to be written.
+func newFanout(sinks []sink, buffer int) *fanout {
+
+	f := &fanout{}
+	for _, s := range sinks {
+		ch := make(chan record, buffer)
+		f.chans = append(f.chans, ch)
+		f.wg.Add(1)
+		go func(s sink) {
+			defer f.wg.Done()
+			var err error
+			for rec := range ch {
+				if err == nil {
+					err = s.write(rec.row, rec.person)
+					f.setErr(err)
+				}
+			}
+			if cerr := s.close(); err == nil {
+				err = cerr
+			}
+			f.setErr(err)
+		}(s)
+	}
+
+	return f
+}
+
+// setErr records err if it is the first error.  A nil err is
+// ignored.
+func (f *fanout) setErr(err error) {
+	f.mu.Lock()
+	if f.err == nil {
+		f.err = err
+	}
+	f.mu.Unlock()
+}
+
+// write sends a record to every sink.  The row and person must not be
+// changed afterward.  It returns an error if a sink has failed, in
+// which case the conversion should stop.
+func (f *fanout) write(row []string, person *notable.Person) error {
+
+	f.mu.Lock()
+	err := f.err
+	f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, ch := range f.chans {
+		ch <- record{row: row, person: person}
+	}
+
+	return nil
+}
+
+// close waits for every sink to write its records, then closes the
+// sinks.  It returns the first error reported by a sink.
+func (f *fanout) close() error {
+
+	for _, ch := range f.chans {
+		close(ch)
+	}
+	f.wg.Wait()
+
+	return f.err
+}
@@ -0,0 +1,110 @@
+// The notable command prepares the Freebase data on notable people
+// for analysis.  It replaces the sequence of convert scripts in the
+// parent directory with a single step.  For example, to build every
+// derived format from the Data S1 workbook:
+//
+//	notable convert --from xlsx --in SchichDataS1_FB.xlsx --to csv,json,gob,struct,cols,ncol
+//
+// To check afterward that the converted files agree:
+//
+//	notable verify --source SchichDataS1_FB.xlsx
+//
+// Run "notable help" for a list of commands, and "notable help
+// command" for the flags of a command.
+//
+// To install the command, run the following in this directory:
+//
+//	go install
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// A command is one of the subcommands of notable.
+type command struct {
+
+	// A one-line description of the command
+	short string
+
+	// Defines the command's flags
+	flags func(fs *flag.FlagSet)
+
+	// Runs the command, after its flags have been parsed
+	run func(fs *flag.FlagSet) error
+}
+
+// commands holds the subcommands, by name.
+var commands = map[string]*command{
+	"convert": convertCmd,
+	"verify":  verifyCmd,
+}
+
+// usage prints the list of commands.
+func usage() {
+
+	fmt.Fprintf(os.Stderr, "Usage: notable <command> [flags]\n\nCommands:\n")
+
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].short)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun \"notable help <command>\" for the flags of a command.\n")
+}
+
+// newFlagSet returns the flag set for the named command.
+func newFlagSet(name string, cmd *command) *flag.FlagSet {
+
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	cmd.flags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: notable %s [flags]\n\n%s\n\nFlags:\n", name, cmd.short)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+func main() {
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name, args := os.Args[1], os.Args[2:]
+
+	if name == "help" || name == "-h" || name == "--help" {
+		if len(args) > 0 {
+			if cmd, ok := commands[args[0]]; ok {
+				newFlagSet(args[0], cmd).Usage()
+				return
+			}
+		}
+		usage()
+		return
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "notable: unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	fs := newFlagSet(name, cmd)
+	fs.Parse(args)
+
+	if err := cmd.run(fs); err != nil {
+		fmt.Fprintf(os.Stderr, "notable %s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
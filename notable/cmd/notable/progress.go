@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// progress displays the number of records processed so far, updating
+// a single line of the terminal.
+type progress struct {
+
+	// Where the progress is displayed, or nil for no display
+	w io.Writer
+
+	// Describes the work being done
+	label string
+
+	// The total number of records, or 0 if not known
+	total int
+
+	// The number of records processed
+	n int
+
+	// When the work started
+	start time.Time
+
+	// When the display was last updated
+	last time.Time
+}
+
+// progressInterval is the minimum time between updates of the display.
+const progressInterval = 200 * time.Millisecond
+
+// newProgress returns a progress display writing to w, which may be
+// nil to display nothing.
+func newProgress(w io.Writer, label string, total int) *progress {
+	now := time.Now()
+	return &progress{w: w, label: label, total: total, start: now, last: now}
+}
+
+// add records that k more records have been processed.
+func (p *progress) add(k int) {
+
+	p.n += k
+
+	if p.w != nil && time.Since(p.last) >= progressInterval {
+		p.show("\r")
+		p.last = time.Now()
+	}
+}
+
+// done displays the final count and ends the line.
+func (p *progress) done() {
+	if p.w != nil {
+		p.show("\r")
+		fmt.Fprintf(p.w, "\n")
+	}
+}
+
+// show writes the current state of the work, after prefix.
+func (p *progress) show(prefix string) {
+
+	rate := float64(p.n) / time.Since(p.start).Seconds()
+
+	if p.total > 0 {
+		fmt.Fprintf(p.w, "%s%s: %d of %d records (%.0f%%, %.0f/s)  ", prefix, p.label,
+			p.n, p.total, 100*float64(p.n)/float64(p.total), rate)
+	} else {
+		fmt.Fprintf(p.w, "%s%s: %d records (%.0f/s)  ", prefix, p.label, p.n, rate)
+	}
+}
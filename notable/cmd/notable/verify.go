@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+)
+
+// verifyOpts holds the flags of the verify command.
+var verifyOpts struct {
+	dir    string
+	prefix string
+	source string
+	sample int
+	seed   int64
+	max    int
+}
+
+var verifyCmd = &command{
+	short: "Check that the converted files are intact and hold the same records",
+	flags: func(fs *flag.FlagSet) {
+		o := &verifyOpts
+		fs.StringVar(&o.dir, "dir", ".", "The directory holding the converted files")
+		fs.StringVar(&o.prefix, "prefix", "fb", "The start of each file name")
+		fs.StringVar(&o.source, "source", "",
+			"Comma-separated list of the original files, such as the workbook; files converted from anything else are reported")
+		fs.IntVar(&o.sample, "sample", 0, "Number of records to compare, chosen at random (0 to compare every record)")
+		fs.Int64Var(&o.seed, "seed", 1, "Seed for choosing the records to compare")
+		fs.IntVar(&o.max, "max", 20, "Maximum number of mismatched values to list")
+	},
+	run: runVerify,
+}
+
+// runVerify verifies the files named on the command line or, if there
+// are none, every converted file found in the directory.  The first
+// file is the reference.
+func runVerify(fs *flag.FlagSet) error {
+
+	o := &verifyOpts
+
+	fnames := fs.Args()
+	if len(fnames) == 0 {
+		for _, out := range outputs {
+			fname := filepath.Join(o.dir, o.prefix+out.suffix)
+			if _, err := os.Stat(fname); err == nil {
+				fnames = append(fnames, fname)
+			}
+		}
+	}
+	if len(fnames) < 2 {
+		return fmt.Errorf("found %d files to compare, need at least 2", len(fnames))
+	}
+
+	opts := notable.VerifyOptions{
+		Sample:        o.sample,
+		Seed:          o.seed,
+		MaxMismatches: o.max,
+	}
+	if o.source != "" {
+		opts.Sources = strings.Split(o.source, ",")
+	}
+
+	rep, err := notable.Verify(fnames, opts)
+	if err != nil {
+		return err
+	}
+
+	for i, fc := range rep.Files {
+		status := "ok"
+		if len(fc.Problems) > 0 {
+			status = "FAILED"
+		}
+		ref := ""
+		if i == 0 {
+			ref = " (reference)"
+		}
+		fmt.Printf("%s%s: %s\n", fc.Name, ref, status)
+		fmt.Printf("  format %s, %d records\n", fc.Format, fc.Records)
+		fmt.Printf("  sha256 %s (not checked)\n", hex.EncodeToString(fc.Checksum[:]))
+		if fc.Header != nil {
+			fmt.Printf("  header: %s\n", fc.Header)
+		} else {
+			fmt.Printf("  header: none\n")
+		}
+		if fc.Source != "" {
+			fmt.Printf("  converted from %s\n", fc.Source)
+		}
+		for _, p := range fc.Problems {
+			fmt.Printf("  problem: %s\n", p)
+		}
+	}
+
+	fmt.Printf("\nCompared %d records in %d files\n", rep.Compared, len(fnames))
+	for _, m := range rep.Mismatches {
+		fmt.Printf("  %s\n", m)
+	}
+	if n := rep.NumMismatches - len(rep.Mismatches); n > 0 {
+		fmt.Printf("  ... and %d more\n", n)
+	}
+
+	if !rep.OK() {
+		return fmt.Errorf("the files do not agree (%d mismatched values)", rep.NumMismatches)
+	}
+	fmt.Printf("All files agree\n")
+
+	return nil
+}
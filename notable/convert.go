@@ -8,152 +8,114 @@
 //
 // This script will store the rows of the dataset as arrays of strings.
 // See convert_structs.go and convert_structs_cols.go to convert to
-// alternative formats.
+// alternative formats.  The notable command in cmd/notable performs
+// all of these conversions in one step.
+//
+// The sheet is read as a stream, so the whole workbook is never held
+// in memory, and each row is written to all three output files as soon
+// as it is read.  Use -sheet to choose a sheet other than "FB", by
+// name or by position counting from 0.
 //
 // To obtain the dependencies for this script, run the following:
 //  go get github.com/kshedden/godata_workshop/notable/notable
-//  go get github.com/tealeg/xlsx
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 
 	"github.com/kshedden/godata_workshop/notable/notable"
-	"github.com/tealeg/xlsx"
 )
 
-// saveSheetCSV saves the contents of an Excel sheet to the
-// named file, in gzip-compressed text/csv format.
-func saveSheetCSV(sheet *xlsx.Sheet, fname string) {
-
-	f, g, cout := notable.GetCSVWriter(fname)
-	defer f.Close()
-	defer g.Close()
-	defer cout.Flush()
-
-	// The dimensions of the sheet
-	r, c := sheet.MaxRow, sheet.MaxCol
-
-	// Storage for the data in one row
-	trow := make([]string, c)
+// saveSheet saves the rows of an Excel sheet to the named files, in
+// gzip-compressed text/csv, json and gob formats.  The sheet is read
+// only once, and each row is written to all three files before the
+// next row is read.  The header of each file records the checksum of
+// the workbook, src.
+func saveSheet(sheet *notable.SheetReader, src, csvName, jsonName, gobName string) int {
 
-	// Loop over the rows of the Excel sheet
-	for i := 0; i < r; i++ {
+	cout, err := notable.NewCSVWriter(csvName)
+	if err != nil {
+		panic(err)
+	}
 
-		// Read one row from the Excel sheet
-		row, err := sheet.Row(i)
-		if err != nil {
-			panic(err)
-		}
+	jenc, err := notable.NewJSONEncoder(jsonName)
+	if err != nil {
+		panic(err)
+	}
 
-		// Copy the contents of the Excel cells into
-		// an array of strings
-		for j := 0; j < c; j++ {
-			trow[j] = row.GetCell(j).Value
-		}
+	genc, err := notable.NewGobEncoder(gobName)
+	if err != nil {
+		panic(err)
+	}
 
-		// Save one row to the output file in CSV format.
-		if err := cout.Write(trow); err != nil {
+	// Record where the data came from
+	for _, w := range []interface{ SetSource(string) error }{cout, jenc, genc} {
+		if err := w.SetSource(src); err != nil {
 			panic(err)
 		}
 	}
-}
-
-// saveSheetJSON saves the contents of an Excel sheet to the
-// named file, in gzip-compressed json format.
-func saveSheetJSON(sheet *xlsx.Sheet, fname string) {
-
-	f, g, enc := notable.GetJSONEncoder(fname)
-	defer f.Close()
-	defer g.Close()
-
-	// The dimensions of the sheet
-	r, c := sheet.MaxRow, sheet.MaxCol
-
-	// Storage for the data in one row
-	trow := make([]string, c)
 
 	// Loop over the rows of the Excel sheet
-	for i := 0; i < r; i++ {
-
-		// Read one row from the Excel sheet
-		row, err := sheet.Row(i)
-		if err != nil {
+	var nrow int
+	for ; ; nrow++ {
+
+		// Read one row from the Excel sheet, as an array of
+		// strings
+		trow, err := sheet.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
 			panic(err)
 		}
 
-		// Copy the contents of the Excel cells into
-		// an array of strings
-		for j := 0; j < c; j++ {
-			trow[j] = row.GetCell(j).Value
-		}
-
-		// Save one row to the output file in CSV format.
-		if err := enc.Encode(trow); err != nil {
+		// Save the row to each output file
+		if err := cout.Write(trow); err != nil {
 			panic(err)
 		}
-	}
-}
-
-// saveSheetGob saves the contents of an Excel sheet to the
-// named file, in gzip-compressed gob format.
-func saveSheetGob(sheet *xlsx.Sheet, fname string) {
-
-	f, g, enc := notable.GetGobEncoder(fname)
-	defer f.Close()
-	defer g.Close()
-
-	// The dimensions of the sheet
-	r, c := sheet.MaxRow, sheet.MaxCol
-
-	// Storage for the data in one row
-	trow := make([]string, c)
-
-	// Loop over the rows of the Excel sheet
-	for i := 0; i < r; i++ {
-
-		// Read one row from the Excel sheet
-		row, err := sheet.Row(i)
-		if err != nil {
+		if err := jenc.Encode(trow); err != nil {
 			panic(err)
 		}
-
-		// Copy the contents of the Excel cells into
-		// an array of strings
-		for j := 0; j < c; j++ {
-			trow[j] = row.GetCell(j).Value
+		if err := genc.Encode(trow); err != nil {
+			panic(err)
 		}
+	}
 
-		// Save one row to the output file in Cgob format.
-		if err := enc.Encode(trow); err != nil {
+	// Closing the files flushes all the data to them
+	for _, c := range []io.Closer{cout, jenc, genc} {
+		if err := c.Close(); err != nil {
 			panic(err)
 		}
 	}
+
+	return nrow
 }
 
 func main() {
 
-	// Read the Excel file
-	f, err := xlsx.OpenFile("SchichDataS1_FB.xlsx")
+	in := flag.String("in", "SchichDataS1_FB.xlsx", "The Excel workbook to convert")
+	sheetName := flag.String("sheet", "FB", "The sheet to convert, by name or by position counting from 0")
+	flag.Parse()
+
+	// Print out the names of all work sheets
+	names, err := notable.SheetNames(*in)
 	if err != nil {
 		panic(err)
 	}
-
-	// Print out the names of all work sheets
 	fmt.Printf("Sheets:\n")
-	for j, sheet := range f.Sheets {
-		fmt.Printf("%4d %s\n", j, sheet.Name)
+	for j, name := range names {
+		fmt.Printf("%4d %s\n", j, name)
 	}
 
-	// Get the only sheet in the workbook
-	fb := f.Sheet["FB"]
-
-	// Save it in compressed csv format
-	saveSheetCSV(fb, "fb.csv.gz")
-
-	// Save it in compressed json format
-	saveSheetJSON(fb, "fb.json.gz")
+	// Open the sheet for streaming
+	sheet, err := notable.OpenSheet(*in, *sheetName)
+	if err != nil {
+		panic(err)
+	}
+	defer sheet.Close()
 
-	// Save it in compressed gob format
-	saveSheetGob(fb, "fb.gob.gz")
+	// Save it in compressed csv, json and gob formats
+	n := saveSheet(sheet, *in, "fb.csv.gz", "fb.json.gz", "fb.gob.gz")
+	fmt.Printf("Wrote %d rows\n", n)
 }
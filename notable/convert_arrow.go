@@ -0,0 +1,89 @@
+// This script converts the Freebase data to the Apache Arrow IPC file
+// format, which Arrow-aware tools (pyarrow, DuckDB, Polars, ...) can
+// memory-map without copying.  It can also convert an Arrow file back
+// to a column-oriented gob file, in the format written by
+// convert_structs_cols.go.
+//
+// To write an Arrow file from any of the files produced by the other
+// convert scripts:
+//
+//  go run convert_arrow.go -in fb_struct.gob.gz -out fb.arrow
+//
+// To read an Arrow file:
+//
+//  go run convert_arrow.go -in fb.arrow -out fb_struct_cols.gob.gz
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+	"github.com/kshedden/godata_workshop/notable/notable/arrowio"
+)
+
+// toArrow writes the records in the file named in to the Arrow file
+// named out.
+func toArrow(in, out string, batchSize int) {
+
+	rdr, err := notable.NewReader(in)
+	if err != nil {
+		panic(err)
+	}
+	defer rdr.Close()
+
+	var people notable.People
+	for rdr.Next() {
+		people.Append(rdr.Person())
+	}
+
+	if err := rdr.Err(); err != nil {
+		panic(err)
+	}
+
+	if err := arrowio.WriteFile(out, &people, batchSize); err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Wrote %d records to %s\n", people.Len(), out)
+}
+
+// fromArrow reads the Arrow file named in, and saves the records as
+// a single gob-encoded People value in the file named out.
+func fromArrow(in, out string) {
+
+	people, err := arrowio.ReadFile(in)
+	if err != nil {
+		panic(err)
+	}
+
+	enc, err := notable.NewGobEncoder(out)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := enc.Encode(people); err != nil {
+		panic(err)
+	}
+
+	if err := enc.Close(); err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Wrote %d records to %s\n", people.Len(), out)
+}
+
+func main() {
+
+	in := flag.String("in", "fb_struct.gob.gz", "Input file")
+	out := flag.String("out", "fb.arrow", "Output file")
+	batchSize := flag.Int("batch", arrowio.DefaultBatchSize, "Number of rows in each record batch")
+	flag.Parse()
+
+	if strings.HasSuffix(*in, ".arrow") {
+		fromArrow(*in, *out)
+	} else {
+		toArrow(*in, *out, *batchSize)
+	}
+}
@@ -0,0 +1,101 @@
+// This script converts the Freebase data to Apache Parquet format,
+// which can be read by pandas, R, DuckDB, Spark and many other tools.
+// It can also convert a Parquet file back to a column-oriented gob
+// file, in the format written by convert_structs_cols.go.
+//
+// To write a Parquet file from any of the files produced by the other
+// convert scripts:
+//
+//  go run convert_parquet.go -in fb_struct.gob.gz -out fb.parquet
+//
+// To read a Parquet file:
+//
+//  go run convert_parquet.go -in fb.parquet -out fb_struct_cols.gob.gz
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+	"github.com/kshedden/godata_workshop/notable/notable/parquetio"
+)
+
+// toParquet writes the records in the file named in to the Parquet
+// file named out.
+func toParquet(in, out, compression string) {
+
+	codec, err := parquetio.ParseCompression(compression)
+	if err != nil {
+		panic(err)
+	}
+
+	rdr, err := notable.NewReader(in)
+	if err != nil {
+		panic(err)
+	}
+	defer rdr.Close()
+
+	w, err := parquetio.NewWriter(out, codec)
+	if err != nil {
+		panic(err)
+	}
+
+	var n int
+	for ; rdr.Next(); n++ {
+		if err := w.Write(rdr.Person()); err != nil {
+			panic(err)
+		}
+	}
+
+	if err := rdr.Err(); err != nil {
+		panic(err)
+	}
+
+	if err := w.Close(); err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Wrote %d records to %s\n", n, out)
+}
+
+// fromParquet reads the Parquet file named in, and saves the records
+// as a single gob-encoded People value in the file named out.
+func fromParquet(in, out string) {
+
+	people, err := parquetio.ReadPeople(in)
+	if err != nil {
+		panic(err)
+	}
+
+	enc, err := notable.NewGobEncoder(out)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := enc.Encode(people); err != nil {
+		panic(err)
+	}
+
+	if err := enc.Close(); err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Wrote %d records to %s\n", people.Len(), out)
+}
+
+func main() {
+
+	in := flag.String("in", "fb_struct.gob.gz", "Input file")
+	out := flag.String("out", "fb.parquet", "Output file")
+	compression := flag.String("compression", "snappy",
+		"Parquet compression: uncompressed, snappy, gzip, lz4 or zstd")
+	flag.Parse()
+
+	if strings.HasSuffix(*in, ".parquet") {
+		fromParquet(*in, *out)
+	} else {
+		toParquet(*in, *out, *compression)
+	}
+}
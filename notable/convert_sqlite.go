@@ -0,0 +1,58 @@
+// This script loads the Freebase data into a SQLite database, so that
+// ad-hoc questions can be answered with SQL instead of a new Go
+// script.  The database has a people table and a derived locations
+// table; see the documentation of the sqlio package for details.
+//
+//  go run convert_sqlite.go -in fb_struct.gob.gz -out fb.sqlite
+//
+// The database can then be queried with the sqlite3 shell, e.g.
+//
+//  sqlite3 fb.sqlite "SELECT COUNT(*) FROM people WHERE BLocLabel = 'Paris'
+//      AND DLocLabel <> 'Paris' AND DYear > 1800"
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+	"github.com/kshedden/godata_workshop/notable/notable/sqlio"
+)
+
+func main() {
+
+	in := flag.String("in", "fb_struct.gob.gz", "Input file, in any of the formats produced by the convert scripts")
+	out := flag.String("out", "fb.sqlite", "SQLite database file")
+	flag.Parse()
+
+	rdr, err := notable.NewReader(*in)
+	if err != nil {
+		panic(err)
+	}
+	defer rdr.Close()
+
+	w, err := sqlio.Create(*out)
+	if err != nil {
+		panic(err)
+	}
+
+	// The database is only changed if every record is loaded
+	var n int
+	for ; rdr.Next(); n++ {
+		if err := w.Write(rdr.Person()); err != nil {
+			w.Abort()
+			panic(err)
+		}
+	}
+
+	if err := rdr.Err(); err != nil {
+		w.Abort()
+		panic(err)
+	}
+
+	if err := w.Close(); err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Loaded %d records into %s\n", n, *out)
+}
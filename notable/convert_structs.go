@@ -6,16 +6,16 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"io"
-	"strconv"
 
 	"github.com/kshedden/godata_workshop/notable/notable"
 )
 
 const (
 	// Read the data from a file in which each data record is stored
-	// as a slice of strings.
+	// as a slice of strings.  Any of the files produced by convert.go
+	// can be used here.
 	dataFile = "fb.gob.gz"
 
 	// Write the data as a stream of struct values to this location.
@@ -24,87 +24,71 @@ const (
 
 func convert() {
 
-	var row []string
-
-	f1, g1, dec := notable.GetGobDecoder(dataFile)
-	f2, g2, enc := notable.GetGobEncoder(outFile)
+	// The records are parsed concurrently, but returned in their
+	// original order.
+	rdr, err := notable.NewParallelReader(dataFile, pipeline)
+	if err != nil {
+		panic(err)
+	}
+	defer rdr.Close()
+	rdr.Missing = policy
 
-	// It would be a resource leak not to close these
-	defer f1.Close()
-	defer g1.Close()
-	defer f2.Close()
-	defer g2.Close()
+	enc, err := notable.NewGobEncoder(outFile)
+	if err != nil {
+		panic(err)
+	}
+	if err := enc.SetSource(dataFile); err != nil {
+		panic(err)
+	}
 
-	// Loop over the data records
+	// Loop over the data records, converting each row of strings
+	// into a struct.
 	var nc int
-	for ; ; nc++ {
-
-		// Get one row of data
-		if err := dec.Decode(&row); err != nil {
-			if err == io.EOF {
-				break
-			}
+	for ; rdr.Next(); nc++ {
+		person := rdr.Person()
+		if err := enc.Encode(&person); err != nil {
 			panic(err)
 		}
+	}
 
-		// Skip the header row
-		if nc == 0 {
-			continue
-		}
+	if err := rdr.Err(); err != nil {
+		panic(err)
+	}
 
-		bloclat, err := strconv.ParseFloat(row[5], 64)
-		if err != nil {
-			panic(err)
-		}
+	// Closing the encoder flushes all the data to the file.
+	if err := enc.Close(); err != nil {
+		panic(err)
+	}
 
-		bloclon, err := strconv.ParseFloat(row[6], 64)
-		if err != nil {
-			panic(err)
-		}
+	fmt.Printf("Processed %d records\n", nc)
+}
 
-		dloclat, err := strconv.ParseFloat(row[10], 64)
-		if err != nil {
-			panic(err)
-		}
+// policy determines how records with missing years or coordinates
+// are treated.
+var policy notable.MissingPolicy
 
-		dloclon, err := strconv.ParseFloat(row[11], 64)
-		if err != nil {
-			panic(err)
-		}
+// pipeline configures the concurrent decoding of the data file.
+var pipeline notable.Pipeline
 
-		byear, err := strconv.ParseInt(row[2], 10, 64)
-		if err != nil {
-			panic(err)
-		}
+func main() {
 
-		dyear, err := strconv.ParseInt(row[7], 10, 64)
-		if err != nil {
-			panic(err)
-		}
+	missing := flag.String("missing", "keep", "Treatment of missing values: keep, drop or impute")
+	flag.IntVar(&pipeline.Workers, "workers", 0, "Number of goroutines parsing the data (0 for one per CPU)")
+	flag.IntVar(&pipeline.BatchSize, "batch", 0, "Number of records parsed together (0 for the default)")
+	flag.Parse()
 
-		// Create a struct holding the data
-		person := notable.Person{
-			PrsLabel:  row[0],
-			BYear:     int(byear),
-			BLocLabel: row[3],
-			BLocLat:   bloclat,
-			BLocLong:  bloclon,
-			DYear:     int(dyear),
-			DLocLabel: row[8],
-			DLocLat:   dloclat,
-			DLocLong:  dloclon,
-			Gender:    row[12],
-		}
+	action, err := notable.ParseMissingAction(*missing)
+	if err != nil {
+		panic(err)
+	}
+	policy.Action = action
 
-		if err := enc.Encode(&person); err != nil {
+	// Missing values are imputed using the mean of each field
+	if action == notable.ImputeMissing {
+		if policy.Fill, err = notable.Means(dataFile); err != nil {
 			panic(err)
 		}
 	}
 
-	fmt.Printf("Processed %d records\n", nc)
-}
-
-func main() {
-
 	convert()
 }
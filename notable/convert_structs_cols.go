@@ -1,58 +1,113 @@
-// Create a column-oriented version of the Freebase data.
+// Create a column-oriented version of the Freebase data.  The data
+// are saved as a column file made up of row groups (see
+// notable.ColumnWriter), which is written as the records are read, so
+// that only one row group is held in memory.
+//
+// The data can also be saved as a single gob-encoded People value,
+// which needs every column in memory at once:
+//
+//  go run convert_structs_cols.go -gob
 
 package main
 
 import (
-	"io"
+	"flag"
 
 	"github.com/kshedden/godata_workshop/notable/notable"
 )
 
+const (
+	// The file that the columns are made from
+	dataFile = "fb_struct.gob.gz"
+
+	// The column file
+	colFile = "fb_struct_cols.ncol"
+
+	// The gob-encoded People value, written if requested
+	gobFile = "fb_struct_cols.gob.gz"
+)
+
+// saveGob determines whether the gob-encoded People value is written.
+var saveGob bool
+
 func convert() {
 
-	f1, g1, dec := notable.GetGobDecoder("fb_struct.gob.gz")
+	// Any of the files produced by convert.go or convert_structs.go
+	// can be read here.
+	rdr, err := notable.NewReader(dataFile)
+	if err != nil {
+		panic(err)
+	}
+
+	// Close this to avoid a resource leak
+	defer rdr.Close()
 
-	// Close these to avoid a resource leak
-	defer f1.Close()
-	defer g1.Close()
+	cw, err := notable.NewColumnWriter(colFile, notable.DefaultGroupSize)
+	if err != nil {
+		panic(err)
+	}
+	if err := cw.SetSource(dataFile); err != nil {
+		panic(err)
+	}
 
 	var people notable.People
 
-	for {
-		var person notable.Person
-		if err := dec.Decode(&person); err != nil {
-			if err == io.EOF {
-				break
-			}
+	for rdr.Next() {
+
+		// Write the current person to the column file, which
+		// writes out each row group when it is full.
+		if err := cw.Write(rdr.Person()); err != nil {
 			panic(err)
 		}
 
 		// Append all the attributes of the current person to
-		// people.
-		people.PrsLabel = append(people.PrsLabel, person.PrsLabel)
-		people.BYear = append(people.BYear, person.BYear)
-		people.BLocLabel = append(people.BLocLabel, person.BLocLabel)
-		people.BLocLat = append(people.BLocLat, person.BLocLat)
-		people.BLocLong = append(people.BLocLong, person.BLocLong)
-		people.DYear = append(people.DYear, person.DYear)
-		people.DLocLabel = append(people.DLocLabel, person.DLocLabel)
-		people.DLocLat = append(people.DLocLat, person.DLocLat)
-		people.DLocLong = append(people.DLocLong, person.DLocLong)
-		people.Gender = append(people.Gender, person.Gender)
+		// people, including any missing values.
+		if saveGob {
+			people.Append(rdr.Person())
+		}
+	}
+
+	if err := rdr.Err(); err != nil {
+		panic(err)
+	}
+
+	// Close this, or the last row group and the footer will not be
+	// written.
+	if err := cw.Close(); err != nil {
+		panic(err)
 	}
 
-	f2, g2, enc := notable.GetGobEncoder("fb_struct_cols.gob.gz")
+	if !saveGob {
+		return
+	}
+
+	// Make sure that the columns line up before saving them.
+	if err := people.Validate(); err != nil {
+		panic(err)
+	}
 
-	// Close these, or all the data may not be written to the file.
-	defer f2.Close()
-	defer g2.Close()
+	enc, err := notable.NewGobEncoder(gobFile)
+	if err != nil {
+		panic(err)
+	}
+	if err := enc.SetSource(dataFile); err != nil {
+		panic(err)
+	}
 
 	if err := enc.Encode(&people); err != nil {
 		panic(err)
 	}
+
+	// Close this, or all the data may not be written to the file.
+	if err := enc.Close(); err != nil {
+		panic(err)
+	}
 }
 
 func main() {
 
+	flag.BoolVar(&saveGob, "gob", false, "Also save the data as a single gob-encoded People value in "+gobFile)
+	flag.Parse()
+
 	convert()
 }
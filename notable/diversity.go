@@ -0,0 +1,114 @@
+// This script measures how concentrated the birth and death locations
+// of notable people are, going beyond the entropy printed by
+// location_stats.go.  Each measure is given for the birth and the
+// death locations, together with the difference between them, and the
+// two distributions are compared by their divergence.  Every value has
+// a bootstrap confidence interval (see the diversity package).  As in
+// entropy_diff.go, only people with both a birth and a death location
+// are used (see notable.Person.HasLocations).
+//
+//  go run diversity.go -reps 2000 -seed 1
+
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+	"github.com/kshedden/godata_workshop/notable/notable/diversity"
+)
+
+const (
+	// The data to analyze
+	dataFile = "fb_struct_cols.ncol"
+)
+
+// A measure is a diversity measure of one distribution.
+type measure struct {
+	name string
+	stat func(counts []float64) float64
+}
+
+var measures = []measure{
+	{"Entropy", diversity.Entropy},
+	{"Normalized entropy", diversity.NormalizedEntropy},
+	{"Gini-Simpson", diversity.GiniSimpson},
+	{"Herfindahl", diversity.Herfindahl},
+	{"Hill, order 0", func(c []float64) float64 { return diversity.Hill(c, 0) }},
+	{"Hill, order 1", func(c []float64) float64 { return diversity.Hill(c, 1) }},
+	{"Hill, order 2", func(c []float64) float64 { return diversity.Hill(c, 2) }},
+	{"Hill, order 4", func(c []float64) float64 { return diversity.Hill(c, 4) }},
+}
+
+// readLocations returns the birth and death locations of the people
+// in the data file who have both, reading one record at a time.
+func readLocations() ([]string, []string) {
+
+	rdr, err := notable.NewReader(dataFile)
+	if err != nil {
+		panic(err)
+	}
+	defer rdr.Close()
+
+	var births, deaths []string
+	for rdr.Next() {
+		person := rdr.Person()
+		if !person.HasLocations() {
+			continue
+		}
+		births = append(births, person.BLocLabel)
+		deaths = append(deaths, person.DLocLabel)
+	}
+
+	if err := rdr.Err(); err != nil {
+		panic(err)
+	}
+
+	return births, deaths
+}
+
+// show prints an estimate with its interval.
+func show(est diversity.Estimate) string {
+	return fmt.Sprintf("%8.4f [%8.4f, %8.4f]", est.Value, est.Lower, est.Upper)
+}
+
+func main() {
+
+	var boot diversity.Bootstrap
+	flag.IntVar(&boot.Reps, "reps", 1000, "Number of bootstrap replicates")
+	flag.Float64Var(&boot.Level, "level", 0.95, "Coverage of the confidence intervals")
+	flag.Int64Var(&boot.Seed, "seed", 1, "Seed for the resampling")
+	flag.IntVar(&boot.Workers, "workers", 0, "Number of goroutines computing replicates (0 for one per CPU)")
+	alpha := flag.Float64("alpha", 0.5, "Added to each count before computing KL divergences")
+	flag.Parse()
+
+	// Give the birth and death locations common codes
+	births, deaths, k := diversity.Encode(readLocations())
+
+	fmt.Printf("%d people, %d locations, %.0f%% confidence intervals from %d replicates\n\n",
+		len(births), k, 100*boot.Level, boot.Reps)
+	fmt.Printf("%-20s %-30s %-30s %s\n", "Measure", "Birth", "Death", "Death - Birth")
+	for _, m := range measures {
+		stat := m.stat
+		b := boot.One(births, k, stat)
+		d := boot.One(deaths, k, stat)
+		diff := boot.Paired(births, deaths, k, func(cb, cd []float64) float64 {
+			return stat(cd) - stat(cb)
+		})
+		fmt.Printf("%-20s %-30s %-30s %s\n", m.name, show(b), show(d), show(diff))
+	}
+
+	// Compare the distributions
+	fmt.Printf("\n%-20s %s\n", "Divergence", "Estimate")
+	kl := boot.Paired(births, deaths, k, func(cb, cd []float64) float64 {
+		return diversity.KL(diversity.Smooth(cd, *alpha), diversity.Smooth(cb, *alpha))
+	})
+	fmt.Printf("%-20s %s\n", "KL(death || birth)", show(kl))
+	kl = boot.Paired(births, deaths, k, func(cb, cd []float64) float64 {
+		return diversity.KL(diversity.Smooth(cb, *alpha), diversity.Smooth(cd, *alpha))
+	})
+	fmt.Printf("%-20s %s\n", "KL(birth || death)", show(kl))
+	js := boot.Paired(births, deaths, k, diversity.JS)
+	fmt.Printf("%-20s %s\n", "Jensen-Shannon", show(js))
+}
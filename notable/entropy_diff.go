@@ -0,0 +1,83 @@
+// This script tests whether the entropy of the death locations of
+// notable people differs from the entropy of their birth locations,
+// the two values printed by location_stats.go.
+//
+// The test is a permutation test (see diversity.PermutationTest).  If
+// birth and death locations were equally diverse, swapping the birth
+// and death location of any person would give data that are just as
+// likely.  The birth and death locations of each person are swapped
+// at random many times, and the p-value is the proportion of the
+// swapped datasets in which the entropies differ by as much as they do
+// in the real data.  Only people with both locations are used (see
+// notable.Person.HasLocations), so that the birth and death entropies
+// are based on the same number of people.
+//
+//  go run entropy_diff.go -reps 10000 -seed 1
+
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+	"github.com/kshedden/godata_workshop/notable/notable/diversity"
+)
+
+const (
+	// The data to analyze
+	dataFile = "fb_struct.gob.gz"
+)
+
+// readLocations returns the birth and death locations of the people
+// in the data file who have both.
+func readLocations() ([]string, []string, int) {
+
+	rdr, err := notable.NewReader(dataFile)
+	if err != nil {
+		panic(err)
+	}
+	defer rdr.Close()
+
+	var births, deaths []string
+	var n int
+	for ; rdr.Next(); n++ {
+		person := rdr.Person()
+		if !person.HasLocations() {
+			continue
+		}
+		births = append(births, person.BLocLabel)
+		deaths = append(deaths, person.DLocLabel)
+	}
+
+	if err := rdr.Err(); err != nil {
+		panic(err)
+	}
+
+	return births, deaths, n
+}
+
+func main() {
+
+	var test diversity.PermutationTest
+	flag.IntVar(&test.Reps, "reps", 10000, "Number of random swaps of the birth and death locations")
+	flag.Int64Var(&test.Seed, "seed", 1, "Seed for the random swaps")
+	flag.IntVar(&test.Workers, "workers", 0, "Number of goroutines computing the swaps (0 for one per CPU)")
+	flag.Parse()
+
+	births, deaths, n := readLocations()
+	bcodes, dcodes, k := diversity.Encode(births, deaths)
+
+	res := test.Paired(bcodes, dcodes, k, diversity.Entropy)
+
+	fmt.Printf("%d of %d people have both locations, at %d distinct locations\n", len(bcodes), n, k)
+	fmt.Printf("Birth entropy: %f\n", diversity.Entropy(diversity.Tally(bcodes, k)))
+	fmt.Printf("Death entropy: %f\n", diversity.Entropy(diversity.Tally(dcodes, k)))
+	fmt.Printf("Difference (death - birth): %f (%.3f%% of the birth entropy)\n", res.Diff, 100*res.Relative)
+	fmt.Printf("Difference under random swaps: mean %f, SD %f\n", res.NullMean, res.NullSD)
+	fmt.Printf("Standardized effect size: %.3f\n", res.Effect)
+	fmt.Printf("Two-sided p-value: %.4f (%d swaps)\n", res.P, res.Reps)
+	if res.Skipped > 0 {
+		fmt.Printf("%d swaps with no finite difference were left out\n", res.Skipped)
+	}
+}
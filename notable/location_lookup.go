@@ -0,0 +1,127 @@
+// This script uses an inverted index of birth and death locations to
+// answer questions about the people born or died at a location without
+// scanning the whole dataset.
+//
+// The index is built from the data file the first time the script is
+// run (or whenever -build is given), and saved for later runs.  It is
+// rebuilt if the data file has changed since.  Only the row groups of
+// the data file that hold the people found are read.  To list
+// everyone born in Vienna:
+//
+//  go run location_lookup.go -born Vienna
+//
+// With no location given, the number of births and deaths at every
+// location is printed, along with the entropies calculated by
+// location_stats.go.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+	"github.com/kshedden/godata_workshop/notable/notable/diversity"
+)
+
+const (
+	// The data to analyze
+	dataFile = "fb_struct_cols.ncol"
+
+	// The location index
+	indexFile = "fb_locations.idx.gz"
+)
+
+// getIndex loads the location index, building and saving it first if
+// it is missing or out of date.
+func getIndex(build bool) *notable.LocationIndex {
+
+	if !build {
+		if _, err := os.Stat(indexFile); err != nil {
+			build = true
+		}
+	}
+
+	if build {
+		ix, err := notable.BuildLocationIndex(dataFile)
+		if err != nil {
+			panic(err)
+		}
+		if err := ix.Save(indexFile); err != nil {
+			panic(err)
+		}
+		return ix
+	}
+
+	ix, err := notable.LoadLocationIndex(indexFile)
+	if err != nil {
+		panic(err)
+	}
+
+	ok, err := ix.Matches(dataFile)
+	if err != nil {
+		panic(err)
+	}
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%s has changed, rebuilding %s\n", dataFile, indexFile)
+		return getIndex(true)
+	}
+
+	return ix
+}
+
+func main() {
+
+	born := flag.String("born", "", "List the people born at this location")
+	died := flag.String("died", "", "List the people who died at this location")
+	build := flag.Bool("build", false, "Rebuild the index from the data file")
+	flag.Parse()
+
+	ix := getIndex(*build)
+
+	var rows []int
+	switch {
+	case *born != "":
+		rows = ix.Born(*born)
+	case *died != "":
+		rows = ix.Died(*died)
+	default:
+		// Summarize the locations using only the index
+		var nb, nd []float64
+		fmt.Printf("%-30s %8s %8s\n", "Location", "Births", "Deaths")
+		for _, loc := range ix.Locations() {
+			b, d := len(ix.Born(loc)), len(ix.Died(loc))
+			fmt.Printf("%-30s %8d %8d\n", loc, b, d)
+			nb = append(nb, float64(b))
+			nd = append(nd, float64(d))
+		}
+		fmt.Printf("Birth entropy: %f\n", diversity.Entropy(nb))
+		fmt.Printf("Death entropy: %f\n", diversity.Entropy(nd))
+		return
+	}
+
+	// Only the selected rows are read
+	cf, err := notable.OpenColumnFile(dataFile)
+	if err != nil {
+		panic(err)
+	}
+	defer cf.Close()
+	found, err := cf.ReadRows(rows, notable.FieldPrsLabel, notable.FieldBYear, notable.FieldDYear)
+	if err != nil {
+		panic(err)
+	}
+	for i := 0; i < found.Len(); i++ {
+		p := found.Row(i)
+		fmt.Printf("%s (%s, %s)\n", p.PrsLabel, yearString(p, notable.FieldBYear, p.BYear),
+			yearString(p, notable.FieldDYear, p.DYear))
+	}
+}
+
+// yearString formats a year, which may be missing.
+func yearString(p notable.Person, f notable.Field, y int) string {
+	if p.IsNA(f) {
+		return "NA"
+	}
+	return fmt.Sprintf("%d", y)
+}
@@ -4,15 +4,24 @@
 // The data set contains locations and dates of births and deaths for
 // notable people.
 //
-// We calculate the mean year of birth and death in each location.
-// Then we sort these alphabetically by the location name, and save
-// the results as a CSV file.
+// We calculate the mean year of birth and death in each location
+// (location_windows.go does this within centuries or other spans of
+// time).  Then we sort these alphabetically by the location name, and save
+// the results as a CSV file.  Flags select another output file, format
+// (json lines, Markdown or Parquet) or order (by count or mean year),
+// and can leave out locations with few people:
+//
+//  go run location_stats.go -format markdown -sort count -min 10
 //
 // We also calculate the frequency distribution of births and deaths
-// by location, and calculate the entropy of each distribution.  A
+// by location, and calculate the entropy of each distribution (see
+// diversity.Entropy).  A
 // distribution with more entropy is more diffuse, and it turns out
 // that the the birth locations have more entropy than the death
-// locations.
+// locations.  Whether the difference is larger than would be expected
+// by chance is tested by entropy_diff.go.  The counts, and so the
+// entropies, can also be found from a location index without reading
+// the data again (see location_lookup.go), but the mean years cannot.
 //
 // See the convert.go script to prepare the data needed by this
 // script.
@@ -20,41 +29,14 @@
 package main
 
 import (
-	"encoding/csv"
+	"flag"
 	"fmt"
-	"io"
-	"math"
-	"os"
-	"sort"
-	"strconv"
 
 	"github.com/kshedden/godata_workshop/notable/notable"
+	"github.com/kshedden/godata_workshop/notable/notable/diversity"
+	"github.com/kshedden/godata_workshop/notable/notable/output"
 )
 
-// entropy returns the entropy of the frequency distribution of the
-// values of the map num.
-func entropy(num map[string]int) float64 {
-
-	// Get the total
-	tot := 0
-	for _, v := range num {
-		tot += v
-	}
-
-	// Accumulate the entropy
-	e := float64(0)
-	for _, v := range num {
-
-		// The proportion of events at this location
-		p := float64(v) / float64(tot)
-
-		// Update the entropy
-		e -= p * math.Log(p)
-	}
-
-	return e
-}
-
 const (
 	// Location of the source data
 	dataFile = "fb.gob.gz"
@@ -69,109 +51,95 @@ const (
 	death
 )
 
+// getStats calculates summary statistics for either the birth
+// locations of the death locations.
 func getStats(bd birthOrDeath) float64 {
 
-	// Storage for the current record of data being processed.
-	var row []string
-
-	fid, gid, dec := notable.GetGobDecoder(dataFile)
-
-	// It would be a resource leak not to close these
-	defer fid.Close()
-	defer gid.Close()
-
-	// Accumulate the sum of all birth years at each birth
-	// location (later will be scaled to obtain the mean).
-	year := make(map[string]float64)
+	// The data file holds rows of strings, which the reader converts
+	// to typed values using the column labels in the first row.
+	rdr, err := notable.NewReader(dataFile)
+	if err != nil {
+		panic(err)
+	}
 
-	// Accumulate the number of people born at each birth
-	// location.
-	num := make(map[string]int)
+	// It would be a resource leak not to close this
+	defer rdr.Close()
 
-	// The column where the relevant location information is stored.
-	locix := 3
-	yearix := 2
+	// Only the year being summarized is subject to the missing value
+	// policy.
+	locf, yearf := notable.FieldBLocLabel, notable.FieldBYear
 	if bd == death {
-		locix = 8
-		yearix = 7
+		locf, yearf = notable.FieldDLocLabel, notable.FieldDYear
 	}
+	rdr.Missing = policy
+	rdr.Missing.Fields = []notable.Field{yearf}
 
-	// Loop over the data records
-	var nc int
-	for ; ; nc++ {
-
-		// Read one record
-		if err := dec.Decode(&row); err != nil {
-			if err == io.EOF {
-				break
-			}
-			panic(err)
-		}
-
-		// Skip the first row (it contains column labels)
-		if nc == 0 {
-			continue
-		}
+	// Group the records by location, finding the mean year and the
+	// number of people at each location.
+	g := notable.NewGroupBy(notable.ByFields(locf), notable.Mean(yearf), notable.Count())
+	if err := g.AddReader(rdr); err != nil {
+		panic(err)
+	}
 
-		// Convert year to a number
-		y, err := strconv.ParseFloat(row[yearix], 64)
-		if err != nil {
-			continue
-		}
+	// The table has one row per location.  Locations with no
+	// observed years get a NaN mean.
+	tab := g.Table()
 
-		// Update the statistics
-		loc := row[locix]
-		year[loc] += y
-		num[loc]++
+	// The entropy is computed from every location, before any are
+	// left out of the output.
+	num, err := tab.Column("count")
+	if err != nil {
+		panic(err)
 	}
+	e := diversity.Entropy(num)
 
-	// Divide the total by the number of values to get the mean
-	for k := range year {
-		year[k] /= float64(num[k])
+	// Leave out the small locations and sort the rest as requested
+	if err := out.Arrange(tab); err != nil {
+		panic(err)
 	}
-
-	// Extract the keys and sort them.
-	var a []string
-	for k := range year {
-		a = append(a, k)
+	event := "birth"
+	if bd == death {
+		event = "death"
+	}
+	if err := out.Save(tab, event); err != nil {
+		panic(err)
 	}
-	sort.StringSlice(a).Sort()
 
-	// Save the results as a CSV file, sorted alphabetically by
-	// location.
-	fname := "birth_mean_by_year.csv"
-	if bd == death {
-		fname = "death_mean_by_year.csv"
+	return e
+}
+
+// out gives the names, format and order of the output files, set by
+// flags.
+var out = output.Options{Default: "%s_mean_by_year"}
+
+// policy determines how records with missing years are treated.  By
+// default they are dropped, so that the counts, and so the entropies,
+// include only the people whose year is known.
+var policy notable.MissingPolicy
+
+func main() {
+
+	missing := flag.String("missing", "drop", "Treatment of missing years: drop, keep or impute")
+	out.FileFlags(flag.CommandLine)
+	out.SortFlags(flag.CommandLine)
+	flag.Parse()
+	if err := out.Check(); err != nil {
+		panic(err)
 	}
 
-	// Create a file to hold the results
-	out, err := os.Create(fname)
+	action, err := notable.ParseMissingAction(*missing)
 	if err != nil {
 		panic(err)
 	}
-	defer out.Close()
-
-	// Prepare to write CSV format data
-	cout := csv.NewWriter(out)
-	defer cout.Flush()
-
-	// Write one row of data
-	var crow []string
-	for _, k := range a {
-		crow = crow[0:0]
-		crow = append(crow, fmt.Sprintf("%s", k))
-		crow = append(crow, fmt.Sprintf("%.0f", year[k]))
-		crow = append(crow, fmt.Sprintf("%d", num[k]))
-		if err := cout.Write(crow); err != nil {
+	policy.Action = action
+
+	// Missing years are imputed using the mean year
+	if action == notable.ImputeMissing {
+		if policy.Fill, err = notable.Means(dataFile); err != nil {
 			panic(err)
 		}
 	}
 
-	return entropy(num)
-}
-
-func main() {
-
 	e := getStats(birth)
 	fmt.Printf("Birth entropy: %f\n", e)
 
@@ -4,14 +4,12 @@
 package main
 
 import (
-	"encoding/csv"
+	"flag"
 	"fmt"
-	"io"
-	"math"
-	"os"
-	"sort"
 
 	"github.com/kshedden/godata_workshop/notable/notable"
+	"github.com/kshedden/godata_workshop/notable/notable/diversity"
+	"github.com/kshedden/godata_workshop/notable/notable/output"
 )
 
 const (
@@ -19,30 +17,6 @@ const (
 	dataFile = "fb_struct.gob.gz"
 )
 
-// entropy returns the entropy of the frequency distribution of the
-// values of the map num.
-func entropy(num map[string]int) float64 {
-
-	// Get the total
-	tot := 0
-	for _, v := range num {
-		tot += v
-	}
-
-	// Accumulate the entropy
-	e := float64(0)
-	for _, v := range num {
-
-		// The proportion of events at this location
-		p := float64(v) / float64(tot)
-
-		// Update the entropy
-		e -= p * math.Log(p)
-	}
-
-	return e
-}
-
 // A collection of flags that indicate whether we are working with dates
 // of birth or dates of death.
 type birthOrDeath int
@@ -56,92 +30,98 @@ const (
 // locations of the death locations.
 func getStats(bd birthOrDeath) float64 {
 
-	fid, gid, dec := notable.GetGobDecoder(dataFile)
-
-	// It would be a resource leak not to close these
-	defer fid.Close()
-	defer gid.Close()
+	// The data file can be in any of the formats produced by the
+	// convert scripts.  It is decoded concurrently, and since the
+	// statistics do not depend on the order of the records, they are
+	// taken in whatever order they are ready.
+	rdr, err := notable.NewParallelReader(dataFile, pipeline)
+	if err != nil {
+		panic(err)
+	}
 
-	// Accumulate the sum of all birth years at each birth
-	// location (later will be scaled to obtain the mean).
-	year := make(map[string]float64)
+	// It would be a resource leak not to close this
+	defer rdr.Close()
 
-	// Accumulate the number of people born at each birth
-	// location.
-	num := make(map[string]int)
+	// Only the year being summarized is subject to the missing value
+	// policy.
+	locf, yearf := notable.FieldBLocLabel, notable.FieldBYear
+	if bd == death {
+		locf, yearf = notable.FieldDLocLabel, notable.FieldDYear
+	}
+	rdr.Missing = policy
+	rdr.Missing.Fields = []notable.Field{yearf}
 
-	// Loop over the data records
-	var nc int
-	for ; ; nc++ {
+	// Group the records by location, finding the mean year and the
+	// number of people at each location.
+	g := notable.NewGroupBy(notable.ByFields(locf), notable.Mean(yearf), notable.Count())
+	if err := g.AddReader(rdr); err != nil {
+		panic(err)
+	}
 
-		var person notable.Person
-		if err := dec.Decode(&person); err != nil {
-			if err == io.EOF {
-				break
-			}
-			panic(err)
-		}
+	// The table has one row per location.  Locations with no
+	// observed years get a NaN mean.
+	tab := g.Table()
 
-		// Update the statistics
-		switch bd {
-		case birth:
-			year[person.BLocLabel] += float64(person.BYear)
-			num[person.BLocLabel]++
-		case death:
-			year[person.DLocLabel] += float64(person.DYear)
-			num[person.DLocLabel]++
-		default:
-			panic("!!")
-		}
+	// The entropy is computed from every location, before any are
+	// left out of the output.
+	num, err := tab.Column("count")
+	if err != nil {
+		panic(err)
 	}
+	e := diversity.Entropy(num)
 
-	// Divide the total by the number of values to get the mean
-	for k := range year {
-		year[k] /= float64(num[k])
+	// Leave out the small locations and sort the rest as requested
+	if err := out.Arrange(tab); err != nil {
+		panic(err)
 	}
-
-	// Extract the keys and sort them.
-	var a []string
-	for k := range year {
-		a = append(a, k)
+	event := "birth"
+	if bd == death {
+		event = "death"
+	}
+	if err := out.Save(tab, event); err != nil {
+		panic(err)
 	}
-	sort.StringSlice(a).Sort()
 
-	// Save the results as a CSV file, sorted alphabetically by
-	// location.
-	fname := "birth_mean_by_year_structs.csv"
-	if bd == death {
-		fname = "death_mean_by_year_structs.csv"
+	return e
+}
+
+// out gives the names, format and order of the output files, set by
+// flags.
+var out = output.Options{Default: "%s_mean_by_year_structs"}
+
+// policy determines how records with missing years are treated.  By
+// default they are dropped, so that the counts, and so the entropies,
+// include only the people whose year is known.
+var policy notable.MissingPolicy
+
+// pipeline configures the concurrent decoding of the data file.
+var pipeline = notable.Pipeline{Unordered: true}
+
+func main() {
+
+	missing := flag.String("missing", "drop", "Treatment of missing years: drop, keep or impute")
+	out.FileFlags(flag.CommandLine)
+	out.SortFlags(flag.CommandLine)
+	flag.IntVar(&pipeline.Workers, "workers", 0, "Number of goroutines parsing the data (0 for one per CPU)")
+	flag.IntVar(&pipeline.BatchSize, "batch", 0, "Number of records parsed together (0 for the default)")
+	flag.Parse()
+	if err := out.Check(); err != nil {
+		panic(err)
 	}
 
-	// Create a file to hold the results
-	out, err := os.Create(fname)
+	action, err := notable.ParseMissingAction(*missing)
 	if err != nil {
 		panic(err)
 	}
-	defer out.Close()
-
-	// Prepare to write CSV format data
-	cout := csv.NewWriter(out)
-	defer cout.Flush()
-
-	// Write one row of data
-	var crow []string
-	for _, k := range a {
-		crow = crow[0:0]
-		crow = append(crow, fmt.Sprintf("%s", k))
-		crow = append(crow, fmt.Sprintf("%.0f", year[k]))
-		crow = append(crow, fmt.Sprintf("%d", num[k]))
-		if err := cout.Write(crow); err != nil {
+	policy.Action = action
+
+	// Missing years are imputed using the mean year
+	if action == notable.ImputeMissing {
+		if policy.Fill, err = notable.Means(dataFile); err != nil {
 			panic(err)
 		}
 	}
 
-	return entropy(num)
-}
-
-func main() {
-
 	e := getStats(birth)
 	fmt.Printf("Birth entropy: %f\n", e)
 
@@ -1,47 +1,24 @@
 // This is equivalent to location_stats.go, using a columnwise-encoded
-// version of the data.
+// version of the data.  The data are stored in row groups (see
+// convert_structs_cols.go), so only one group needs to be held in
+// memory at a time.
 
 package main
 
 import (
-	"encoding/csv"
+	"flag"
 	"fmt"
-	"math"
-	"os"
-	"sort"
 
 	"github.com/kshedden/godata_workshop/notable/notable"
+	"github.com/kshedden/godata_workshop/notable/notable/diversity"
+	"github.com/kshedden/godata_workshop/notable/notable/output"
 )
 
 const (
 	// The data to analyze
-	dataFile = "fb_struct_cols.gob.gz"
+	dataFile = "fb_struct_cols.ncol"
 )
 
-// entropy returns the entropy of the frequency distribution of the
-// values of the map num.
-func entropy(num map[string]int) float64 {
-
-	// Get the total
-	tot := 0
-	for _, v := range num {
-		tot += v
-	}
-
-	// Accumulate the entropy
-	e := float64(0)
-	for _, v := range num {
-
-		// The proportion of events at this location
-		p := float64(v) / float64(tot)
-
-		// Update the entropy
-		e -= p * math.Log(p)
-	}
-
-	return e
-}
-
 // A collection of flags that indicate whether we are working with dates
 // of birth or dates of death.
 type birthOrDeath int
@@ -55,89 +32,105 @@ const (
 // locations of the death locations.
 func getStats(bd birthOrDeath) float64 {
 
-	fid, gid, dec := notable.GetGobDecoder(dataFile)
-
-	// It would be a resource leak not to close these
-	defer fid.Close()
-	defer gid.Close()
+	cf, err := notable.OpenColumnFile(dataFile)
+	if err != nil {
+		panic(err)
+	}
 
-	var people notable.People
+	// It would be a resource leak not to close this
+	defer cf.Close()
 
-	if err := dec.Decode(&people); err != nil {
-		panic(err)
+	// Only the location and year being summarized are read, and
+	// only the year is subject to the missing value policy.
+	locf, yearf := notable.FieldBLocLabel, notable.FieldBYear
+	if bd == death {
+		locf, yearf = notable.FieldDLocLabel, notable.FieldDYear
 	}
+	pol := policy
+	pol.Fields = []notable.Field{yearf}
+
+	// Group the records by location, finding the mean year and the
+	// number of people at each location.
+	g := notable.NewGroupBy(notable.ByFields(locf), notable.Mean(yearf), notable.Count())
 
-	// Accumulate the sum of all birth years at each birth
-	// location (later will be scaled to obtain the mean).
-	year := make(map[string]float64)
-
-	// Accumulate the number of people born at each birth
-	// location.
-	num := make(map[string]int)
-
-	// Loop over the data records
-	for i := 0; i < len(people.PrsLabel); i++ {
-
-		// Update the statistics
-		switch bd {
-		case birth:
-			year[people.BLocLabel[i]] += float64(people.BYear[i])
-			num[people.BLocLabel[i]]++
-		case death:
-			year[people.DLocLabel[i]] += float64(people.DYear[i])
-			num[people.DLocLabel[i]]++
-		default:
-			panic("!!")
+	// Loop over the row groups, holding only one group in memory at
+	// a time.
+	for i := 0; i < cf.NumGroups(); i++ {
+
+		people, err := cf.ReadGroup(i, locf, yearf)
+		if err != nil {
+			panic(err)
 		}
-	}
+		people = pol.ApplyPeople(people)
 
-	// Divide the total by the number of values to get the mean
-	for k := range year {
-		year[k] /= float64(num[k])
+		// The location column is dictionary-encoded, so the records
+		// in this group are tallied by location code.
+		locs := &people.BLocLabel
+		if bd == death {
+			locs = &people.DLocLabel
+		}
+		g.AddCodes(locs, people)
 	}
 
-	// Extract the keys and sort them.
-	var a []string
-	for k := range year {
-		a = append(a, k)
+	// The table has one row per location.  Locations with no
+	// observed years get a NaN mean.
+	tab := g.Table()
+
+	// The entropy is computed from every location, before any are
+	// left out of the output.
+	num, err := tab.Column("count")
+	if err != nil {
+		panic(err)
 	}
-	sort.StringSlice(a).Sort()
+	e := diversity.Entropy(num)
 
-	// Save the results as a CSV file, sorted alphabetically by
-	// location.
-	fname := "birth_mean_by_year_structs_cols.csv"
+	// Leave out the small locations and sort the rest as requested
+	if err := out.Arrange(tab); err != nil {
+		panic(err)
+	}
+	event := "birth"
 	if bd == death {
-		fname = "death_mean_by_year_structs_cols.csv"
+		event = "death"
+	}
+	if err := out.Save(tab, event); err != nil {
+		panic(err)
 	}
 
-	// Create a file to hold the results
-	out, err := os.Create(fname)
+	return e
+}
+
+// out gives the names, format and order of the output files, set by
+// flags.
+var out = output.Options{Default: "%s_mean_by_year_structs_cols"}
+
+// policy determines how records with missing years are treated.  By
+// default they are dropped, so that the counts, and so the entropies,
+// include only the people whose year is known.
+var policy notable.MissingPolicy
+
+func main() {
+
+	missing := flag.String("missing", "drop", "Treatment of missing years: drop, keep or impute")
+	out.FileFlags(flag.CommandLine)
+	out.SortFlags(flag.CommandLine)
+	flag.Parse()
+	if err := out.Check(); err != nil {
+		panic(err)
+	}
+
+	action, err := notable.ParseMissingAction(*missing)
 	if err != nil {
 		panic(err)
 	}
-	defer out.Close()
-
-	// Prepare to write CSV format data
-	cout := csv.NewWriter(out)
-	defer cout.Flush()
-
-	// Write one row of data
-	var crow []string
-	for _, k := range a {
-		crow = crow[0:0]
-		crow = append(crow, fmt.Sprintf("%s", k))
-		crow = append(crow, fmt.Sprintf("%.0f", year[k]))
-		crow = append(crow, fmt.Sprintf("%d", num[k]))
-		if err := cout.Write(crow); err != nil {
+	policy.Action = action
+
+	// Missing years are imputed using the mean year
+	if action == notable.ImputeMissing {
+		if policy.Fill, err = notable.Means(dataFile); err != nil {
 			panic(err)
 		}
 	}
 
-	return entropy(num)
-}
-
-func main() {
-
 	e := getStats(birth)
 	fmt.Printf("Birth entropy: %f\n", e)
 
@@ -0,0 +1,236 @@
+// This script follows how the birth and death locations of notable
+// people change over time.  The mean year at each location, found by
+// location_stats_structs.go, pools people from every era.  Here the
+// years are divided into windows, such as centuries, and the locations
+// are summarized within each window: the number of people at each
+// location, its share of the window and its rank, together with the
+// entropy of the locations in the window.
+//
+// The results are saved as a long table, with one row per window and
+// location, holding the top locations of each window.  A summary of
+// each window is also printed.  Windows can be centuries, decades, or
+// windows of any width that start every step years and may overlap:
+//
+//  go run location_windows.go -window century -top 3
+//  go run location_windows.go -window 50/10 -top 0 -format markdown
+//
+// People whose year is missing cannot be placed in a window, and are
+// left out unless the missing years are imputed.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+	"github.com/kshedden/godata_workshop/notable/notable/diversity"
+	"github.com/kshedden/godata_workshop/notable/notable/output"
+)
+
+const (
+	// The data to analyze
+	dataFile = "fb_struct.gob.gz"
+)
+
+// A collection of flags that indicate whether we are working with dates
+// of birth or dates of death.
+type birthOrDeath int
+
+const (
+	birth birthOrDeath = iota
+	death
+)
+
+// The options, set by flags
+var (
+	// The time windows
+	windows notable.Windows
+
+	// The number of locations kept in each window, or 0 to keep all
+	// of them
+	top int
+
+	// The names and format of the output files
+	out = output.Options{Default: "%s_by_window"}
+)
+
+// getStats calculates summary statistics for either the birth
+// locations or the death locations within each time window.
+func getStats(bd birthOrDeath) {
+
+	rdr, err := notable.NewParallelReader(dataFile, pipeline)
+	if err != nil {
+		panic(err)
+	}
+
+	// It would be a resource leak not to close this
+	defer rdr.Close()
+
+	// Only the year being summarized is subject to the missing value
+	// policy.
+	locf, yearf := notable.FieldBLocLabel, notable.FieldBYear
+	if bd == death {
+		locf, yearf = notable.FieldDLocLabel, notable.FieldDYear
+	}
+	rdr.Missing = policy
+	rdr.Missing.Fields = []notable.Field{yearf}
+
+	// Group the records by window and location.  A record may fall in
+	// several windows, so the records are placed in their groups
+	// explicitly with AddTo, and the key only names the columns.
+	key := notable.Key{Names: []string{"window", locf.String()}}
+	g := notable.NewGroupBy(key, notable.Mean(yearf), notable.Count())
+
+	// The windows seen, by name
+	wins := make(map[string]notable.Window)
+
+	var nskip int
+	for rdr.Next() {
+		person := rdr.Person()
+		if person.IsNA(yearf) {
+			nskip++
+			continue
+		}
+
+		loc, y := person.BLocLabel, person.BYear
+		if bd == death {
+			loc, y = person.DLocLabel, person.DYear
+		}
+		for _, w := range windows.Of(y) {
+			wins[w.String()] = w
+			g.AddTo([]string{w.String(), loc}, &person)
+		}
+	}
+	if err := rdr.Err(); err != nil {
+		panic(err)
+	}
+
+	tab := windowTable(g.Table(), wins, yearf)
+
+	event := "birth"
+	if bd == death {
+		event = "death"
+	}
+	if nskip > 0 {
+		fmt.Printf("%d people with no %s year were left out\n", nskip, event)
+	}
+	fmt.Println()
+
+	if err := out.Save(tab, event); err != nil {
+		panic(err)
+	}
+}
+
+// windowTable ranks the locations within each window of the grouped
+// table, which has one row per window and location, and returns the
+// long table that is saved.  The windows are in time order, and the
+// locations of each window in order of rank.
+func windowTable(grouped *notable.Table, wins map[string]notable.Window, yearf notable.Field) *notable.Table {
+
+	jmean := grouped.Value("mean_" + yearf.String())
+	jcount := grouped.Value("count")
+
+	// The rows of the grouped table belonging to each window
+	rows := make(map[string][]int)
+	var names []string
+	for i, k := range grouped.Keys {
+		if _, ok := rows[k[0]]; !ok {
+			names = append(names, k[0])
+		}
+		rows[k[0]] = append(rows[k[0]], i)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return wins[names[i]].Start < wins[names[j]].Start
+	})
+
+	tab := &notable.Table{
+		KeyNames: grouped.KeyNames,
+		ValueNames: []string{"first_year", "last_year", "count", "share", "rank",
+			"mean_" + yearf.String(), "window_count", "window_entropy"},
+	}
+
+	for _, name := range names {
+
+		// The grouped table is sorted by location, so the stable
+		// sort breaks ties in the count by name.
+		ix := rows[name]
+		sort.SliceStable(ix, func(a, b int) bool {
+			return grouped.Values[ix[a]][jcount] > grouped.Values[ix[b]][jcount]
+		})
+
+		counts := make([]float64, len(ix))
+		var total float64
+		for a, i := range ix {
+			counts[a] = grouped.Values[i][jcount]
+			total += counts[a]
+		}
+		e := diversity.Entropy(counts)
+
+		w := wins[name]
+		var leaders []string
+		for a, i := range ix {
+			if top > 0 && a >= top {
+				break
+			}
+			tab.Keys = append(tab.Keys, grouped.Keys[i])
+			tab.Values = append(tab.Values, []float64{float64(w.Start), float64(w.End - 1),
+				counts[a], counts[a] / total, float64(a + 1), grouped.Values[i][jmean], total, e})
+			leaders = append(leaders, fmt.Sprintf("%s (%.0f)", grouped.Keys[i][1], counts[a]))
+		}
+
+		fmt.Printf("%-14s %5.0f people at %3d locations, entropy %.4f: %s\n",
+			name, total, len(ix), e, strings.Join(leaders, ", "))
+	}
+
+	return tab
+}
+
+// policy determines how records with missing years are treated.
+var policy notable.MissingPolicy
+
+// pipeline configures the concurrent decoding of the data file.
+var pipeline = notable.Pipeline{Unordered: true}
+
+func main() {
+
+	spec := flag.String("window", "century", "Time windows: century, decade, a width in years, or width/step for overlapping windows")
+	origin := flag.Int("origin", 0, "A year at which one of the windows starts")
+	flag.IntVar(&top, "top", 5, "Number of locations kept in each window (0 for all)")
+	out.FileFlags(flag.CommandLine)
+	missing := flag.String("missing", "keep", "Treatment of missing years: keep, drop or impute")
+	flag.IntVar(&pipeline.Workers, "workers", 0, "Number of goroutines parsing the data (0 for one per CPU)")
+	flag.IntVar(&pipeline.BatchSize, "batch", 0, "Number of records parsed together (0 for the default)")
+	flag.Parse()
+
+	var err error
+	if windows, err = notable.ParseWindows(*spec); err != nil {
+		panic(err)
+	}
+	windows.Origin = *origin
+
+	if err := out.Check(); err != nil {
+		panic(err)
+	}
+
+	action, err := notable.ParseMissingAction(*missing)
+	if err != nil {
+		panic(err)
+	}
+	policy.Action = action
+
+	// Missing years are imputed using the mean year
+	if action == notable.ImputeMissing {
+		if policy.Fill, err = notable.Means(dataFile); err != nil {
+			panic(err)
+		}
+	}
+
+	fmt.Printf("Birth locations, windows of %d years starting every %d years\n", windows.Width, windows.Step)
+	getStats(birth)
+
+	fmt.Printf("Death locations, windows of %d years starting every %d years\n", windows.Width, windows.Step)
+	getStats(death)
+}
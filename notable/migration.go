@@ -0,0 +1,129 @@
+// This script looks at where notable people died relative to where
+// they were born.  The people moving between each pair of locations
+// are counted (see the migration package), and the largest flows are
+// printed, followed by the number of people moving into and out of
+// each location and the share of the people born at each location who
+// also died there.  The flows are saved as an edge list, with one row
+// for each birth and death location, and the moves into and out of
+// each location as a table of balances.
+//
+// The people can be restricted to those born in an era, or to one
+// gender:
+//
+//  go run migration.go -top 10
+//  go run migration.go -era 1800-1899 -gender female -moves -format markdown
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+	"github.com/kshedden/godata_workshop/notable/notable/migration"
+	"github.com/kshedden/godata_workshop/notable/notable/output"
+)
+
+const (
+	// The data to analyze
+	dataFile = "fb_struct_cols.ncol"
+)
+
+// parseEra returns the window of birth years given as "first-last",
+// such as "1800-1899", or nil if era is empty.
+func parseEra(era string) *notable.Window {
+
+	if era == "" {
+		return nil
+	}
+
+	// The first year may be negative, so split at the last dash
+	i := strings.LastIndex(era, "-")
+	if i <= 0 {
+		panic(fmt.Sprintf("invalid era %q", era))
+	}
+	first, err1 := strconv.Atoi(era[0:i])
+	last, err2 := strconv.Atoi(era[i+1:])
+	if err1 != nil || err2 != nil || last < first {
+		panic(fmt.Sprintf("invalid era %q", era))
+	}
+
+	return &notable.Window{Start: first, End: last + 1}
+}
+
+func main() {
+
+	era := flag.String("era", "", "Only count people born in these years, given as first-last (e.g. 1800-1899)")
+	gender := flag.String("gender", "", "Only count people of this gender")
+	top := flag.Int("top", 10, "Number of flows and of locations printed (negative for all)")
+	moves := flag.Bool("moves", false, "Leave out people who died where they were born from the flows")
+	outName := flag.String("out", "", "Edge list file (default migration_edges.<ext>)")
+	balName := flag.String("balances", "", "Balance table file (default migration_balances.<ext>)")
+	format := flag.String("format", "csv", "Output format: "+output.Formats)
+	flag.Parse()
+
+	if err := output.Check(*format); err != nil {
+		panic(err)
+	}
+	if *outName == "" {
+		*outName = "migration_edges" + output.Ext[*format]
+	}
+	if *balName == "" {
+		*balName = "migration_balances" + output.Ext[*format]
+	}
+
+	// The records are counted as they are read, without holding them
+	rdr, err := notable.NewReader(dataFile)
+	if err != nil {
+		panic(err)
+	}
+	defer rdr.Close()
+	m := migration.New(migration.Filter{Era: parseEra(*era), Gender: *gender})
+	if err := m.AddReader(rdr); err != nil {
+		panic(err)
+	}
+
+	// The tables are saved even if no one was counted, so that they
+	// do not keep the results of an earlier run
+	if err := output.Save(m.Edges(*moves), *outName, *format); err != nil {
+		panic(err)
+	}
+	if err := output.Save(m.BalanceTable(), *balName, *format); err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("%.0f people (%s) at %d locations\n", m.Total(), m.Filter(), len(m.Locations()))
+	if n := m.Incomplete(); n > 0 {
+		fmt.Printf("%d people with no birth or death location were left out\n", n)
+	}
+	if m.Total() == 0 {
+		return
+	}
+	fmt.Printf("%.1f%% died where they were born\n\n", 100*m.Retention())
+
+	fmt.Printf("%-20s %-20s %6s %7s\n", "Born", "Died", "Count", "Share")
+	for _, f := range m.Top(*top, *moves) {
+		fmt.Printf("%-20s %-20s %6.0f %6.2f%%\n", f.From, f.To, f.Count, 100*f.Count/m.Total())
+	}
+
+	fmt.Printf("\n%-20s %6s %6s %6s %6s %6s %6s %9s\n",
+		"Location", "Born", "Died", "Stayed", "In", "Out", "Net", "Retention")
+	for _, b := range m.TopBalances(*top) {
+		fmt.Printf("%-20s %6.0f %6.0f %6.0f %6.0f %6.0f %+6.0f %9s\n",
+			b.Location, b.Born, b.Died, b.Stayed, b.In, b.Out, b.Net, percent(b.Retention))
+	}
+}
+
+// percent formats a share as a percentage, or as a dash if it is NaN,
+// as is the retention of a location where no one was born.
+func percent(x float64) string {
+
+	if math.IsNaN(x) {
+		return "-"
+	}
+
+	return fmt.Sprintf("%.1f%%", 100*x)
+}
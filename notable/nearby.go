@@ -0,0 +1,138 @@
+// This script finds the notable people who were born or died near a
+// given point, using a spatial index of the birth or death locations
+// (see the spatial package).
+//
+// To list the people who died within 50 km of Rome:
+//
+//  go run nearby.go -event death -lat 41.9 -long 12.5 -km 50
+//
+// To list the 10 people born nearest to Rome:
+//
+//  go run nearby.go -event birth -lat 41.9 -long 12.5 -k 10
+//
+// A bounding box can be given instead, as -box minlat,minlong,maxlat,maxlong.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+	"github.com/kshedden/godata_workshop/notable/notable/spatial"
+)
+
+const (
+	// The data to analyze
+	dataFile = "fb_struct_cols.ncol"
+)
+
+// readLocated reads the people in the data file whose place of birth
+// or death, given by event, has known coordinates.  Only the name,
+// location and coordinates of each person are kept.
+func readLocated(event spatial.Event) *notable.People {
+
+	rdr, err := notable.NewReader(dataFile)
+	if err != nil {
+		panic(err)
+	}
+	defer rdr.Close()
+
+	located := new(notable.People)
+	for rdr.Next() {
+		p := rdr.Person()
+		q := notable.Person{PrsLabel: p.PrsLabel}
+		if event == spatial.Birth {
+			if p.IsNA(notable.FieldBLocLat) || p.IsNA(notable.FieldBLocLong) {
+				continue
+			}
+			q.BLocLabel, q.BLocLat, q.BLocLong = p.BLocLabel, p.BLocLat, p.BLocLong
+		} else {
+			if p.IsNA(notable.FieldDLocLat) || p.IsNA(notable.FieldDLocLong) {
+				continue
+			}
+			q.DLocLabel, q.DLocLat, q.DLocLong = p.DLocLabel, p.DLocLat, p.DLocLong
+		}
+		located.Append(q)
+	}
+
+	if err := rdr.Err(); err != nil {
+		panic(err)
+	}
+
+	return located
+}
+
+// parseBox parses a bounding box given as four comma-separated
+// numbers.
+func parseBox(s string) [4]float64 {
+
+	var box [4]float64
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		panic(fmt.Sprintf("box %q should have four values", s))
+	}
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			panic(err)
+		}
+		box[i] = v
+	}
+
+	return box
+}
+
+func main() {
+
+	ev := flag.String("event", "death", "Locations to search: birth or death")
+	lat := flag.Float64("lat", 41.9, "Latitude of the point")
+	long := flag.Float64("long", 12.5, "Longitude of the point")
+	km := flag.Float64("km", 50, "Find people within this many km of the point")
+	k := flag.Int("k", 0, "If positive, find this many people nearest to the point")
+	box := flag.String("box", "", "If given, find people in this box: minlat,minlong,maxlat,maxlong")
+	flag.Parse()
+
+	event, err := spatial.ParseEvent(*ev)
+	if err != nil {
+		panic(err)
+	}
+
+	people := readLocated(event)
+	ix, err := spatial.New(people, event)
+	if err != nil {
+		panic(err)
+	}
+
+	var rows []int
+	var dist []float64
+	switch {
+	case *box != "":
+		b := parseBox(*box)
+		rows = ix.Box(b[0], b[1], b[2], b[3])
+	case *k > 0:
+		for _, nb := range ix.Nearest(*lat, *long, *k) {
+			rows = append(rows, nb.Row)
+			dist = append(dist, nb.Km)
+		}
+	default:
+		rows = ix.Within(*lat, *long, *km)
+	}
+
+	found := people.Take(rows)
+	for i := 0; i < found.Len(); i++ {
+		p := found.Row(i)
+		loc := p.BLocLabel
+		if event == spatial.Death {
+			loc = p.DLocLabel
+		}
+		if dist != nil {
+			fmt.Printf("%-30s %-20s %9.2f km\n", p.PrsLabel, loc, dist[i])
+		} else {
+			fmt.Printf("%-30s %s\n", p.PrsLabel, loc)
+		}
+	}
+	fmt.Printf("%d people\n", found.Len())
+}
@@ -0,0 +1,529 @@
+package notable
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Aggregators
+//
+// Apart from Count, the aggregators summarize a Measure, which is
+// usually one of the numeric fields, skipping the records in which it
+// is missing.  A summary of a group with too few values is NaN.  All
+// the aggregators can be merged, so that a dataset can be summarized
+// in parts, such as the row groups of a column file, and the parts
+// combined.
+
+// A Measure is a number obtained from each record.  Each numeric Field
+// is a Measure, and others can be made with NewMeasure.
+type Measure interface {
+
+	// String returns the name of the measure, used to name the
+	// result columns
+	String() string
+
+	// Value returns the measure for a person, or false if it is
+	// missing
+	Value(p *Person) (float64, bool)
+}
+
+// Value returns the value of a numeric field of a person, or false if
+// it is missing.  It panics if the field is not numeric.
+func (f Field) Value(p *Person) (float64, bool) {
+	if p.IsNA(f) {
+		return 0, false
+	}
+	return p.float(f), true
+}
+
+// NewMeasure returns a measure with the given name, computed by fn.
+// For example, the age at death is given by:
+//
+//	age := notable.NewMeasure("Age", func(p *notable.Person) (float64, bool) {
+//		if p.IsNA(notable.FieldBYear) || p.IsNA(notable.FieldDYear) {
+//			return 0, false
+//		}
+//		return float64(p.DYear - p.BYear), true
+//	})
+func NewMeasure(name string, fn func(p *Person) (float64, bool)) Measure {
+	return funcMeasure{name, fn}
+}
+
+type funcMeasure struct {
+	name string
+	fn   func(p *Person) (float64, bool)
+}
+
+func (m funcMeasure) String() string {
+	return m.name
+}
+
+func (m funcMeasure) Value(p *Person) (float64, bool) {
+	return m.fn(p)
+}
+
+// numeric panics if m is a field that is not numeric.
+func numeric(m Measure) {
+	if f, ok := m.(Field); ok && !f.Nullable() {
+		panic(fmt.Sprintf("notable: %s is not numeric", f))
+	}
+}
+
+// Count returns an aggregator counting the records in each group.
+func Count() Aggregator {
+	return countAgg{}
+}
+
+type countAgg struct{}
+
+func (countAgg) Names() []string {
+	return []string{"count"}
+}
+
+func (countAgg) New() Accumulator {
+	return new(countAcc)
+}
+
+type countAcc struct {
+	n int
+}
+
+func (a *countAcc) Add(p *Person) {
+	a.n++
+}
+
+func (a *countAcc) Merge(other Accumulator) {
+	a.n += other.(*countAcc).n
+}
+
+func (a *countAcc) Values() []float64 {
+	return []float64{float64(a.n)}
+}
+
+// momentKind selects the summary given by a moments accumulator.
+type momentKind int
+
+const (
+	momentCount momentKind = iota
+	momentSum
+	momentMean
+	momentVariance
+	momentSD
+)
+
+var momentNames = []string{"nvalid", "sum", "mean", "var", "sd"}
+
+// CountValid returns an aggregator counting the records in which m is
+// not missing.
+func CountValid(m Measure) Aggregator {
+	numeric(m)
+	return momentAgg{m, momentCount}
+}
+
+// Sum returns an aggregator summing a measure.
+func Sum(m Measure) Aggregator {
+	numeric(m)
+	return momentAgg{m, momentSum}
+}
+
+// Mean returns an aggregator taking the mean of a measure.
+func Mean(m Measure) Aggregator {
+	numeric(m)
+	return momentAgg{m, momentMean}
+}
+
+// Variance returns an aggregator taking the sample variance of a
+// measure, with divisor n-1.
+func Variance(m Measure) Aggregator {
+	numeric(m)
+	return momentAgg{m, momentVariance}
+}
+
+// SD returns an aggregator taking the sample standard deviation of a
+// measure.
+func SD(m Measure) Aggregator {
+	numeric(m)
+	return momentAgg{m, momentSD}
+}
+
+type momentAgg struct {
+	m    Measure
+	kind momentKind
+}
+
+func (a momentAgg) Names() []string {
+	return []string{momentNames[a.kind] + "_" + a.m.String()}
+}
+
+func (a momentAgg) New() Accumulator {
+	return &momentAcc{m: a.m, kind: a.kind}
+}
+
+// momentAcc accumulates the number, sum, mean and sum of squared
+// deviations of the values of a measure.  The mean and squared
+// deviations are updated by Welford's method, which is accurate even
+// when the mean is large compared to the spread, as for years.
+type momentAcc struct {
+
+	// The measure being summarized
+	m Measure
+
+	// The summary that is returned
+	kind momentKind
+
+	// The number of values
+	n int
+
+	// The sum of the values
+	sum float64
+
+	// The mean of the values
+	mean float64
+
+	// The sum of squared deviations from the mean
+	m2 float64
+}
+
+func (a *momentAcc) Add(p *Person) {
+
+	x, ok := a.m.Value(p)
+	if !ok {
+		return
+	}
+
+	a.n++
+	a.sum += x
+	d := x - a.mean
+	a.mean += d / float64(a.n)
+	a.m2 += d * (x - a.mean)
+}
+
+func (a *momentAcc) Merge(other Accumulator) {
+
+	o := other.(*momentAcc)
+	if o.n == 0 {
+		return
+	}
+
+	n := a.n + o.n
+	d := o.mean - a.mean
+	a.mean += d * float64(o.n) / float64(n)
+	a.m2 += o.m2 + d*d*float64(a.n)*float64(o.n)/float64(n)
+	a.sum += o.sum
+	a.n = n
+}
+
+func (a *momentAcc) Values() []float64 {
+
+	v := math.NaN()
+	switch a.kind {
+	case momentCount:
+		v = float64(a.n)
+	case momentSum:
+		if a.n > 0 {
+			v = a.sum
+		}
+	case momentMean:
+		if a.n > 0 {
+			v = a.sum / float64(a.n)
+		}
+	case momentVariance:
+		if a.n > 1 {
+			v = a.m2 / float64(a.n-1)
+		}
+	case momentSD:
+		if a.n > 1 {
+			v = math.Sqrt(a.m2 / float64(a.n-1))
+		}
+	}
+
+	return []float64{v}
+}
+
+// Min returns an aggregator taking the smallest value of a measure.
+func Min(m Measure) Aggregator {
+	numeric(m)
+	return extremeAgg{m, false}
+}
+
+// Max returns an aggregator taking the largest value of a measure.
+func Max(m Measure) Aggregator {
+	numeric(m)
+	return extremeAgg{m, true}
+}
+
+type extremeAgg struct {
+	m   Measure
+	max bool
+}
+
+func (a extremeAgg) Names() []string {
+	if a.max {
+		return []string{"max_" + a.m.String()}
+	}
+	return []string{"min_" + a.m.String()}
+}
+
+func (a extremeAgg) New() Accumulator {
+	return &extremeAcc{m: a.m, max: a.max, v: math.NaN()}
+}
+
+// extremeAcc holds the smallest or largest value seen, or NaN.
+type extremeAcc struct {
+	m   Measure
+	max bool
+	v   float64
+}
+
+func (a *extremeAcc) Add(p *Person) {
+	if x, ok := a.m.Value(p); ok {
+		a.update(x)
+	}
+}
+
+func (a *extremeAcc) update(x float64) {
+	if math.IsNaN(a.v) || (a.max && x > a.v) || (!a.max && x < a.v) {
+		a.v = x
+	}
+}
+
+func (a *extremeAcc) Merge(other Accumulator) {
+	if o := other.(*extremeAcc); !math.IsNaN(o.v) {
+		a.update(o.v)
+	}
+}
+
+func (a *extremeAcc) Values() []float64 {
+	return []float64{a.v}
+}
+
+// Quantiles returns an aggregator giving the exact quantiles of a
+// measure at each of the probabilities.  The quantile at probability
+// q is the value at position floor(q*(n-1)) among the n sorted values.
+// Every value is held in memory until the summary is taken; see
+// ApproxQuantiles for a summary of fixed size.
+func Quantiles(m Measure, probs ...float64) Aggregator {
+	numeric(m)
+	checkProbs(probs)
+	return quantileAgg{m, probs, "q"}
+}
+
+// Median returns an aggregator giving the exact median of a measure,
+// as defined by Quantiles.
+func Median(m Measure) Aggregator {
+	numeric(m)
+	return quantileAgg{m, []float64{0.5}, "median"}
+}
+
+// checkProbs panics if a probability is not between 0 and 1.
+func checkProbs(probs []float64) {
+	for _, q := range probs {
+		if !(q >= 0 && q <= 1) {
+			panic(fmt.Sprintf("notable: invalid probability %v", q))
+		}
+	}
+}
+
+// quantileNames returns the names of the quantile columns, such as
+// q0.25_BYear.
+func quantileNames(prefix string, m Measure, probs []float64) []string {
+
+	if prefix == "median" {
+		return []string{"median_" + m.String()}
+	}
+
+	var names []string
+	for _, q := range probs {
+		names = append(names, fmt.Sprintf("%s%g_%s", prefix, q, m))
+	}
+
+	return names
+}
+
+type quantileAgg struct {
+	m      Measure
+	probs  []float64
+	prefix string
+}
+
+func (a quantileAgg) Names() []string {
+	return quantileNames(a.prefix, a.m, a.probs)
+}
+
+func (a quantileAgg) New() Accumulator {
+	return &quantileAcc{m: a.m, probs: a.probs}
+}
+
+// quantileAcc holds every value of the measure.
+type quantileAcc struct {
+	m     Measure
+	probs []float64
+	x     []float64
+}
+
+func (a *quantileAcc) Add(p *Person) {
+	if x, ok := a.m.Value(p); ok {
+		a.x = append(a.x, x)
+	}
+}
+
+func (a *quantileAcc) Merge(other Accumulator) {
+	a.x = append(a.x, other.(*quantileAcc).x...)
+}
+
+func (a *quantileAcc) Values() []float64 {
+
+	sort.Float64s(a.x)
+
+	v := make([]float64, len(a.probs))
+	for j, q := range a.probs {
+		if len(a.x) == 0 {
+			v[j] = math.NaN()
+		} else {
+			v[j] = a.x[int(q*float64(len(a.x)-1))]
+		}
+	}
+
+	return v
+}
+
+// ApproxQuantiles returns an aggregator giving approximate quantiles
+// of a measure at each of the probabilities, using a t-digest (see
+// TDigest) with the default compression.  The summary of each group
+// has a fixed size, however many values it holds, and is most
+// accurate for probabilities near 0 and 1.
+func ApproxQuantiles(m Measure, probs ...float64) Aggregator {
+	numeric(m)
+	checkProbs(probs)
+	return approxAgg{m, probs}
+}
+
+type approxAgg struct {
+	m     Measure
+	probs []float64
+}
+
+func (a approxAgg) Names() []string {
+	return quantileNames("approx_q", a.m, a.probs)
+}
+
+func (a approxAgg) New() Accumulator {
+	return &approxAcc{m: a.m, probs: a.probs, td: NewTDigest(DefaultCompression)}
+}
+
+type approxAcc struct {
+	m     Measure
+	probs []float64
+	td    *TDigest
+}
+
+func (a *approxAcc) Add(p *Person) {
+	if x, ok := a.m.Value(p); ok {
+		a.td.Add(x)
+	}
+}
+
+func (a *approxAcc) Merge(other Accumulator) {
+	a.td.Merge(other.(*approxAcc).td)
+}
+
+func (a *approxAcc) Values() []float64 {
+
+	v := make([]float64, len(a.probs))
+	for j, q := range a.probs {
+		v[j] = a.td.Quantile(q)
+	}
+
+	return v
+}
+
+// Distinct returns an aggregator counting the distinct values of a
+// field, which need not be numeric.  Missing values are not counted.
+func Distinct(f Field) Aggregator {
+	return distinctAgg{f}
+}
+
+type distinctAgg struct {
+	f Field
+}
+
+func (a distinctAgg) Names() []string {
+	return []string{"distinct_" + a.f.String()}
+}
+
+func (a distinctAgg) New() Accumulator {
+	return &distinctAcc{f: a.f, seen: make(map[string]bool)}
+}
+
+// distinctAcc holds the set of values seen.
+type distinctAcc struct {
+	f    Field
+	seen map[string]bool
+}
+
+func (a *distinctAcc) Add(p *Person) {
+	if !p.IsNA(a.f) {
+		a.seen[p.get(a.f)] = true
+	}
+}
+
+func (a *distinctAcc) Merge(other Accumulator) {
+	for v := range other.(*distinctAcc).seen {
+		a.seen[v] = true
+	}
+}
+
+func (a *distinctAcc) Values() []float64 {
+	return []float64{float64(len(a.seen))}
+}
+
+// Mode returns an aggregator giving the most common value of a
+// measure.  If several values are equally common, the smallest is
+// given.
+func Mode(m Measure) Aggregator {
+	numeric(m)
+	return modeAgg{m}
+}
+
+type modeAgg struct {
+	m Measure
+}
+
+func (a modeAgg) Names() []string {
+	return []string{"mode_" + a.m.String()}
+}
+
+func (a modeAgg) New() Accumulator {
+	return &modeAcc{m: a.m, num: make(map[float64]int)}
+}
+
+// modeAcc counts the number of times each value is seen.
+type modeAcc struct {
+	m   Measure
+	num map[float64]int
+}
+
+func (a *modeAcc) Add(p *Person) {
+	if x, ok := a.m.Value(p); ok {
+		a.num[x]++
+	}
+}
+
+func (a *modeAcc) Merge(other Accumulator) {
+	for x, n := range other.(*modeAcc).num {
+		a.num[x] += n
+	}
+}
+
+func (a *modeAcc) Values() []float64 {
+
+	mode, best := math.NaN(), 0
+	for x, n := range a.num {
+		if n > best || (n == best && x < mode) {
+			mode, best = x, n
+		}
+	}
+
+	return []float64{mode}
+}
@@ -0,0 +1,131 @@
+package notable
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// yearPeople returns people born in the given years, with a missing
+// year for each NaN.
+func yearPeople(years ...float64) []Person {
+
+	var persons []Person
+	for _, y := range years {
+		p := Person{Gender: "female"}
+		if math.IsNaN(y) {
+			p.SetNA(FieldBYear)
+		} else {
+			p.BYear = int(y)
+		}
+		persons = append(persons, p)
+	}
+
+	return persons
+}
+
+// same returns true if the values are equal, treating NaNs as equal.
+func same(x, y []float64) bool {
+
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if !(x[i] == y[i] || math.IsNaN(x[i]) && math.IsNaN(y[i]) || math.Abs(x[i]-y[i]) < 1e-9) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestAggregators(t *testing.T) {
+
+	nan := math.NaN()
+	persons := yearPeople(3, 1, 4, 1, nan, 5, 9, 2, 6, 5)
+	age := NewMeasure("Age", func(p *Person) (float64, bool) {
+		return float64(2000 - p.BYear), !p.IsNA(FieldBYear)
+	})
+
+	cases := []struct {
+		agg   Aggregator
+		names []string
+		want  []float64
+		empty []float64
+	}{
+		{Count(), []string{"count"}, []float64{10}, []float64{0}},
+		{CountValid(FieldBYear), []string{"nvalid_BYear"}, []float64{9}, []float64{0}},
+		{Sum(FieldBYear), []string{"sum_BYear"}, []float64{36}, []float64{nan}},
+		{Mean(FieldBYear), []string{"mean_BYear"}, []float64{4}, []float64{nan}},
+		{Variance(FieldBYear), []string{"var_BYear"}, []float64{6.75}, []float64{nan}},
+		{SD(FieldBYear), []string{"sd_BYear"}, []float64{math.Sqrt(6.75)}, []float64{nan}},
+		{Min(FieldBYear), []string{"min_BYear"}, []float64{1}, []float64{nan}},
+		{Max(age), []string{"max_Age"}, []float64{1999}, []float64{nan}},
+		{Quantiles(FieldBYear, 0, 0.25, 1), []string{"q0_BYear", "q0.25_BYear", "q1_BYear"},
+			[]float64{1, 2, 9}, []float64{nan, nan, nan}},
+		{Median(FieldBYear), []string{"median_BYear"}, []float64{4}, []float64{nan}},
+		{ApproxQuantiles(FieldBYear, 0, 1), []string{"approx_q0_BYear", "approx_q1_BYear"},
+			[]float64{1, 9}, []float64{nan, nan}},
+		{Distinct(FieldBYear), []string{"distinct_BYear"}, []float64{7}, []float64{0}},
+		{Distinct(FieldGender), []string{"distinct_Gender"}, []float64{1}, []float64{0}},
+		{Mode(FieldBYear), []string{"mode_BYear"}, []float64{1}, []float64{nan}},
+	}
+
+	for _, c := range cases {
+		if got := c.agg.Names(); !reflect.DeepEqual(got, c.names) {
+			t.Errorf("names %q, want %q", got, c.names)
+		}
+
+		// All the records at once, and in two merged parts
+		all, first, second := c.agg.New(), c.agg.New(), c.agg.New()
+		for i := range persons {
+			all.Add(&persons[i])
+			if i < 4 {
+				first.Add(&persons[i])
+			} else {
+				second.Add(&persons[i])
+			}
+		}
+		first.Merge(second)
+
+		if got := all.Values(); !same(got, c.want) {
+			t.Errorf("%s: %v, want %v", c.names[0], got, c.want)
+		}
+		if got := first.Values(); !same(got, c.want) {
+			t.Errorf("%s: %v when merged, want %v", c.names[0], got, c.want)
+		}
+
+		// Merging an empty part changes nothing
+		all.Merge(c.agg.New())
+		if got := all.Values(); !same(got, c.want) {
+			t.Errorf("%s: %v after merging an empty part, want %v", c.names[0], got, c.want)
+		}
+		if got := c.agg.New().Values(); !same(got, c.empty) {
+			t.Errorf("%s: %v with no records, want %v", c.names[0], got, c.empty)
+		}
+	}
+}
+
+func TestAggregatorPanics(t *testing.T) {
+
+	cases := []struct {
+		name string
+		fn   func()
+	}{
+		{"mean of a label", func() { Mean(FieldGender) }},
+		{"quantile above 1", func() { Quantiles(FieldBYear, 1.5) }},
+		{"negative quantile", func() { ApproxQuantiles(FieldBYear, -0.1) }},
+		{"NaN quantile", func() { Quantiles(FieldBYear, math.NaN()) }},
+	}
+
+	for _, c := range cases {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: no panic", c.name)
+				}
+			}()
+			c.fn()
+		}()
+	}
+}
@@ -0,0 +1,406 @@
+// Package arrowio converts between notable.People and Apache Arrow
+// record batches, and reads and writes them as Arrow IPC files and
+// streams.
+//
+// Each column of People becomes an Arrow column with the same name.
+// Years are signed 32-bit integers and coordinates are 64-bit floats,
+// with missing values stored as nulls.  The location labels and
+// gender are dictionary-encoded strings.  Arrow IPC files can be
+// memory-mapped by Arrow-aware tools such as pyarrow and DuckDB.
+package arrowio
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/kshedden/godata_workshop/notable/notable"
+)
+
+// DefaultBatchSize is the default number of rows in each record batch.
+const DefaultBatchSize = 1 << 16
+
+// dictString is the type of the dictionary-encoded string columns.
+var dictString = &arrow.DictionaryType{
+	IndexType: arrow.PrimitiveTypes.Int32,
+	ValueType: arrow.BinaryTypes.String,
+}
+
+// schema holds the Arrow layout of People.  The fields are in the
+// same order as notable.Fields.
+var schema = arrow.NewSchema([]arrow.Field{
+	{Name: "PrsLabel", Type: arrow.BinaryTypes.String},
+	{Name: "BYear", Type: arrow.PrimitiveTypes.Int32, Nullable: true},
+	{Name: "BLocLabel", Type: dictString},
+	{Name: "BLocLat", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+	{Name: "BLocLong", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+	{Name: "DYear", Type: arrow.PrimitiveTypes.Int32, Nullable: true},
+	{Name: "DLocLabel", Type: dictString},
+	{Name: "DLocLat", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+	{Name: "DLocLong", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+	{Name: "Gender", Type: dictString},
+}, nil)
+
+// Schema returns the Arrow schema used for People.
+func Schema() *arrow.Schema {
+	return schema
+}
+
+// NewRecord converts rows i through j-1 of people to an Arrow record
+// batch.  The caller must call Release on the result.
+func NewRecord(mem memory.Allocator, people *notable.People, i, j int) arrow.RecordBatch {
+
+	sub := people.Slice(i, j)
+	bb := newBatchBuilder(mem, &sub)
+	defer bb.release()
+
+	return bb.build(&sub, 0, j-i)
+}
+
+// batchBuilder builds record batches in which each dictionary-encoded
+// column uses the same dictionary.  The Arrow IPC file format does not
+// allow dictionaries to change from one batch to the next.
+type batchBuilder struct {
+	mem memory.Allocator
+
+	// The dictionary for each dictionary-encoded column
+	dicts map[notable.Field]arrow.Array
+}
+
+// newBatchBuilder returns a batchBuilder whose dictionaries are those
+// of the dictionary-encoded columns of people.
+func newBatchBuilder(mem memory.Allocator, people *notable.People) *batchBuilder {
+
+	bb := &batchBuilder{mem: mem, dicts: make(map[notable.Field]arrow.Array)}
+
+	for _, f := range notable.Fields() {
+
+		if schema.Field(int(f)).Type != dictString {
+			continue
+		}
+
+		db := array.NewStringBuilder(mem)
+		db.AppendValues(labelColumn(people, f).Dict, nil)
+		bb.dicts[f] = db.NewArray()
+		db.Release()
+	}
+
+	return bb
+}
+
+// labelColumn returns the dictionary-encoded column holding field f.
+func labelColumn(people *notable.People, f notable.Field) *notable.DictColumn {
+	switch f {
+	case notable.FieldBLocLabel:
+		return &people.BLocLabel
+	case notable.FieldDLocLabel:
+		return &people.DLocLabel
+	case notable.FieldGender:
+		return &people.Gender
+	default:
+		panic(fmt.Sprintf("arrowio: %s is not dictionary-encoded", f))
+	}
+}
+
+// build converts rows i through j-1 of people to a record batch.
+func (bb *batchBuilder) build(people *notable.People, i, j int) arrow.RecordBatch {
+
+	fields := notable.Fields()
+	builders := make([]array.Builder, len(fields))
+	for _, f := range fields {
+		if dict, ok := bb.dicts[f]; ok {
+			builders[f] = array.NewDictionaryBuilderWithDict(bb.mem, dictString, dict)
+		} else {
+			builders[f] = array.NewBuilder(bb.mem, schema.Field(int(f)).Type)
+		}
+		defer builders[f].Release()
+		builders[f].Reserve(j - i)
+	}
+
+	for k := i; k < j; k++ {
+		appendPerson(builders, people.Row(k))
+	}
+
+	cols := make([]arrow.Array, len(fields))
+	for f, b := range builders {
+		cols[f] = b.NewArray()
+		defer cols[f].Release()
+	}
+
+	return array.NewRecordBatch(schema, cols, int64(j-i))
+}
+
+// release releases the dictionaries.
+func (bb *batchBuilder) release() {
+	for _, d := range bb.dicts {
+		d.Release()
+	}
+}
+
+// appendPerson adds one person to the columns being built.
+func appendPerson(builders []array.Builder, p notable.Person) {
+
+	for _, f := range notable.Fields() {
+
+		fb := builders[f]
+		if p.IsNA(f) {
+			fb.AppendNull()
+			continue
+		}
+
+		switch f {
+		case notable.FieldPrsLabel:
+			fb.(*array.StringBuilder).Append(p.PrsLabel)
+		case notable.FieldBYear:
+			fb.(*array.Int32Builder).Append(int32(p.BYear))
+		case notable.FieldBLocLabel:
+			fb.(*array.BinaryDictionaryBuilder).AppendString(p.BLocLabel)
+		case notable.FieldBLocLat:
+			fb.(*array.Float64Builder).Append(p.BLocLat)
+		case notable.FieldBLocLong:
+			fb.(*array.Float64Builder).Append(p.BLocLong)
+		case notable.FieldDYear:
+			fb.(*array.Int32Builder).Append(int32(p.DYear))
+		case notable.FieldDLocLabel:
+			fb.(*array.BinaryDictionaryBuilder).AppendString(p.DLocLabel)
+		case notable.FieldDLocLat:
+			fb.(*array.Float64Builder).Append(p.DLocLat)
+		case notable.FieldDLocLong:
+			fb.(*array.Float64Builder).Append(p.DLocLong)
+		case notable.FieldGender:
+			fb.(*array.BinaryDictionaryBuilder).AppendString(p.Gender)
+		}
+	}
+}
+
+// AppendRecord adds the rows of an Arrow record batch to people.  The
+// columns are located by name, so they can appear in any order.
+// String columns may be plain or dictionary-encoded, and year columns
+// may hold 32 or 64 bit integers.
+func AppendRecord(people *notable.People, rec arrow.RecordBatch) error {
+
+	fields := notable.Fields()
+	cols := make([]arrow.Array, len(fields))
+	for _, f := range fields {
+		ix := rec.Schema().FieldIndices(f.String())
+		if len(ix) != 1 {
+			return fmt.Errorf("arrowio: record has %d columns named %s", len(ix), f)
+		}
+		cols[f] = rec.Column(ix[0])
+	}
+
+	for i := 0; i < int(rec.NumRows()); i++ {
+		var p notable.Person
+		for _, f := range fields {
+			if err := setField(&p, f, cols[f], i); err != nil {
+				return err
+			}
+		}
+		people.Append(p)
+	}
+
+	return nil
+}
+
+// setField sets field f of the person from row i of the column.
+func setField(p *notable.Person, f notable.Field, col arrow.Array, i int) error {
+
+	if col.IsNull(i) {
+		if !f.Nullable() {
+			return fmt.Errorf("arrowio: column %s holds a null", f)
+		}
+		p.SetNA(f)
+		return nil
+	}
+
+	switch f {
+	case notable.FieldPrsLabel:
+		return stringValue(col, i, &p.PrsLabel)
+	case notable.FieldBYear:
+		return intValue(col, i, &p.BYear)
+	case notable.FieldBLocLabel:
+		return stringValue(col, i, &p.BLocLabel)
+	case notable.FieldBLocLat:
+		return floatValue(col, i, &p.BLocLat)
+	case notable.FieldBLocLong:
+		return floatValue(col, i, &p.BLocLong)
+	case notable.FieldDYear:
+		return intValue(col, i, &p.DYear)
+	case notable.FieldDLocLabel:
+		return stringValue(col, i, &p.DLocLabel)
+	case notable.FieldDLocLat:
+		return floatValue(col, i, &p.DLocLat)
+	case notable.FieldDLocLong:
+		return floatValue(col, i, &p.DLocLong)
+	case notable.FieldGender:
+		return stringValue(col, i, &p.Gender)
+	}
+
+	return nil
+}
+
+func stringValue(col arrow.Array, i int, dst *string) error {
+	switch c := col.(type) {
+	case *array.String:
+		*dst = c.Value(i)
+	case *array.Dictionary:
+		dict, ok := c.Dictionary().(*array.String)
+		if !ok {
+			return fmt.Errorf("arrowio: unsupported dictionary type %s", c.Dictionary().DataType())
+		}
+		*dst = dict.Value(c.GetValueIndex(i))
+	default:
+		return fmt.Errorf("arrowio: unsupported string column type %s", col.DataType())
+	}
+	return nil
+}
+
+func intValue(col arrow.Array, i int, dst *int) error {
+	switch c := col.(type) {
+	case *array.Int32:
+		*dst = int(c.Value(i))
+	case *array.Int64:
+		*dst = int(c.Value(i))
+	default:
+		return fmt.Errorf("arrowio: unsupported year column type %s", col.DataType())
+	}
+	return nil
+}
+
+func floatValue(col arrow.Array, i int, dst *float64) error {
+	c, ok := col.(*array.Float64)
+	if !ok {
+		return fmt.Errorf("arrowio: unsupported coordinate column type %s", col.DataType())
+	}
+	*dst = c.Value(i)
+	return nil
+}
+
+// recordWriter is implemented by the Arrow IPC file and stream
+// writers.
+type recordWriter interface {
+	Write(arrow.RecordBatch) error
+	Close() error
+}
+
+// writeBatches writes people to w in record batches of the given size.
+func writeBatches(w recordWriter, mem memory.Allocator, people *notable.People, batchSize int) error {
+
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	bb := newBatchBuilder(mem, people)
+	defer bb.release()
+
+	for i := 0; i < people.Len(); i += batchSize {
+		j := i + batchSize
+		if j > people.Len() {
+			j = people.Len()
+		}
+		rec := bb.build(people, i, j)
+		err := w.Write(rec)
+		rec.Release()
+		if err != nil {
+			w.Close()
+			return err
+		}
+	}
+
+	return w.Close()
+}
+
+// WriteFile saves people to the named Arrow IPC file, in record
+// batches with the given number of rows.  If batchSize is not
+// positive, DefaultBatchSize is used.
+func WriteFile(fname string, people *notable.People, batchSize int) error {
+
+	fid, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+
+	mem := memory.NewGoAllocator()
+	w, err := ipc.NewFileWriter(fid, ipc.WithSchema(schema), ipc.WithAllocator(mem))
+	if err != nil {
+		fid.Close()
+		return err
+	}
+
+	err = writeBatches(w, mem, people, batchSize)
+
+	if cerr := fid.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// ReadFile reads all the record batches in the named Arrow IPC file.
+func ReadFile(fname string) (*notable.People, error) {
+
+	fid, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer fid.Close()
+
+	r, err := ipc.NewFileReader(fid, ipc.WithAllocator(memory.NewGoAllocator()))
+	if err != nil {
+		return nil, fmt.Errorf("arrowio: %s: %v", fname, err)
+	}
+	defer r.Close()
+
+	people := new(notable.People)
+	for i := 0; i < r.NumRecords(); i++ {
+		rec, err := r.RecordBatchAt(i)
+		if err != nil {
+			return nil, err
+		}
+		err = AppendRecord(people, rec)
+		rec.Release()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return people, nil
+}
+
+// WriteStream writes people to w in the Arrow IPC stream format, in
+// record batches with the given number of rows.  If batchSize is not
+// positive, DefaultBatchSize is used.
+func WriteStream(w io.Writer, people *notable.People, batchSize int) error {
+
+	mem := memory.NewGoAllocator()
+	sw := ipc.NewWriter(w, ipc.WithSchema(schema), ipc.WithAllocator(mem))
+
+	return writeBatches(sw, mem, people, batchSize)
+}
+
+// ReadStream reads all the record batches from an Arrow IPC stream.
+func ReadStream(r io.Reader) (*notable.People, error) {
+
+	sr, err := ipc.NewReader(r, ipc.WithAllocator(memory.NewGoAllocator()))
+	if err != nil {
+		return nil, err
+	}
+	defer sr.Release()
+
+	people := new(notable.People)
+	for sr.Next() {
+		if err := AppendRecord(people, sr.RecordBatch()); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := sr.Err(); err != nil {
+		return nil, err
+	}
+
+	return people, nil
+}
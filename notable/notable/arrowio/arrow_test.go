@@ -0,0 +1,76 @@
+package arrowio
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/kshedden/godata_workshop/notable/notable"
+	"github.com/kshedden/godata_workshop/notable/notable/internal/fixture"
+)
+
+// equal reports the rows of got that differ from want.
+func equal(t *testing.T, name string, got, want *notable.People) {
+
+	t.Helper()
+
+	if got.Len() != want.Len() {
+		t.Errorf("%s: %d people, want %d", name, got.Len(), want.Len())
+		return
+	}
+	for i := 0; i < want.Len(); i++ {
+		if !reflect.DeepEqual(got.Row(i), want.Row(i)) {
+			t.Errorf("%s: row %d is %+v, want %+v", name, i, got.Row(i), want.Row(i))
+		}
+	}
+}
+
+// The later batches hold labels that are not in the first.
+func TestRoundTrip(t *testing.T) {
+
+	want := fixture.People()
+
+	for _, batchSize := range []int{0, 1, 2} {
+		fname := filepath.Join(t.TempDir(), "people.arrow")
+		if err := WriteFile(fname, want, batchSize); err != nil {
+			t.Fatalf("batch size %d: %v", batchSize, err)
+		}
+		got, err := ReadFile(fname)
+		if err != nil {
+			t.Fatalf("batch size %d: %v", batchSize, err)
+		}
+		equal(t, "file", got, want)
+
+		var buf bytes.Buffer
+		if err := WriteStream(&buf, want, batchSize); err != nil {
+			t.Fatalf("batch size %d: %v", batchSize, err)
+		}
+		if got, err = ReadStream(&buf); err != nil {
+			t.Fatalf("batch size %d: %v", batchSize, err)
+		}
+		equal(t, "stream", got, want)
+	}
+}
+
+func TestNewRecord(t *testing.T) {
+
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+
+	want := fixture.People()
+	rec := NewRecord(mem, want, 1, 3)
+	defer rec.Release()
+
+	if rec.NumRows() != 2 || !rec.Schema().Equal(Schema()) {
+		t.Errorf("record has %d rows and schema %s", rec.NumRows(), rec.Schema())
+	}
+
+	got := new(notable.People)
+	if err := AppendRecord(got, rec); err != nil {
+		t.Fatal(err)
+	}
+	sub := want.Slice(1, 3)
+	equal(t, "record", got, &sub)
+}
@@ -0,0 +1,179 @@
+package notable
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+)
+
+// A Codec is a compression format used for dataset files.
+type Codec int
+
+const (
+	// NoCompression stores the data as-is
+	NoCompression Codec = iota
+
+	// Gzip uses the gzip format (file extension .gz)
+	Gzip
+
+	// Zstd uses the Zstandard format (file extension .zst)
+	Zstd
+
+	// Snappy uses the framed snappy format (file extension .sz)
+	Snappy
+
+	// LZ4 uses the LZ4 frame format (file extension .lz4)
+	LZ4
+)
+
+// codecInfo describes how each codec is recognized.
+var codecInfo = []struct {
+	name  string
+	ext   string
+	magic []byte
+}{
+	NoCompression: {name: "none"},
+	Gzip:          {name: "gzip", ext: ".gz", magic: []byte{0x1f, 0x8b}},
+	Zstd:          {name: "zstd", ext: ".zst", magic: []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	Snappy:        {name: "snappy", ext: ".sz", magic: []byte("\xff\x06\x00\x00sNaPpY")},
+	LZ4:           {name: "lz4", ext: ".lz4", magic: []byte{0x04, 0x22, 0x4d, 0x18}},
+}
+
+// String returns the name of the codec.
+func (c Codec) String() string {
+	if c < 0 || int(c) >= len(codecInfo) {
+		return fmt.Sprintf("Codec(%d)", int(c))
+	}
+	return codecInfo[c].name
+}
+
+// Ext returns the file extension conventionally used for the codec,
+// or an empty string if the data are not compressed or the codec is
+// not known.
+func (c Codec) Ext() string {
+	if c < 0 || int(c) >= len(codecInfo) {
+		return ""
+	}
+	return codecInfo[c].ext
+}
+
+// CodecFromName returns the codec implied by the extension of the
+// given file name.  Names without a recognized extension are not
+// compressed.
+func CodecFromName(fname string) Codec {
+
+	ext := strings.ToLower(filepath.Ext(fname))
+	for c, ci := range codecInfo {
+		if ci.ext != "" && ci.ext == ext {
+			return Codec(c)
+		}
+	}
+
+	return NoCompression
+}
+
+// ParseCodec returns the codec with the given name, as returned by
+// the codec's String method.
+func ParseCodec(name string) (Codec, error) {
+
+	for c, ci := range codecInfo {
+		if ci.name == strings.ToLower(name) {
+			return Codec(c), nil
+		}
+	}
+
+	return NoCompression, fmt.Errorf("notable: unknown codec %q", name)
+}
+
+// DetectCodec inspects the first few bytes available from br to
+// determine how the stream is compressed.  The bytes are not
+// consumed.
+func DetectCodec(br *bufio.Reader) (Codec, error) {
+
+	// The longest magic number is the snappy stream identifier
+	head, err := br.Peek(len(codecInfo[Snappy].magic))
+	if err != nil && err != io.EOF {
+		return NoCompression, err
+	}
+
+	for c, ci := range codecInfo {
+		if len(ci.magic) > 0 && bytes.HasPrefix(head, ci.magic) {
+			return Codec(c), nil
+		}
+	}
+
+	return NoCompression, nil
+}
+
+// NewWriter returns a WriteCloser that compresses data written to it
+// and writes the result to w.  Closing the returned writer flushes
+// the compressed stream but does not close w.
+func (c Codec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+
+	switch c {
+	case NoCompression:
+		return nopWriteCloser{w}, nil
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Zstd:
+		return zstd.NewWriter(w)
+	case Snappy:
+		return snappy.NewBufferedWriter(w), nil
+	case LZ4:
+		return lz4.NewWriter(w), nil
+	default:
+		return nil, fmt.Errorf("notable: unknown codec %v", c)
+	}
+}
+
+// NewReader returns a ReadCloser that decompresses data read from r.
+// Closing the returned reader releases the decompressor but does not
+// close r.
+func (c Codec) NewReader(r io.Reader) (io.ReadCloser, error) {
+
+	switch c {
+	case NoCompression:
+		return io.NopCloser(r), nil
+	case Gzip:
+		return gzip.NewReader(r)
+	case Zstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zstdReadCloser{zr}, nil
+	case Snappy:
+		return io.NopCloser(snappy.NewReader(r)), nil
+	case LZ4:
+		return io.NopCloser(lz4.NewReader(r)), nil
+	default:
+		return nil, fmt.Errorf("notable: unknown codec %v", c)
+	}
+}
+
+// nopWriteCloser adds a Close method that does nothing to a Writer.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// zstdReadCloser adapts a zstd decoder to the io.ReadCloser interface.
+type zstdReadCloser struct {
+	*zstd.Decoder
+}
+
+func (z zstdReadCloser) Close() error {
+	z.Decoder.Close()
+	return nil
+}
@@ -0,0 +1,94 @@
+package notable
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+
+	data := []byte(strings.Repeat("PrsLabel,BYear\nAda,1815\n", 100))
+
+	for _, c := range []Codec{NoCompression, Gzip, Zstd, Snappy, LZ4} {
+		var buf bytes.Buffer
+		zw, err := c.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := zw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		br := bufio.NewReader(&buf)
+		detected, err := DetectCodec(br)
+		if err != nil || detected != c {
+			t.Errorf("%s: detected %s, %v", c, detected, err)
+			continue
+		}
+
+		zr, err := c.NewReader(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(zr)
+		zr.Close()
+		if err != nil {
+			t.Errorf("%s: %v", c, err)
+		} else if !bytes.Equal(got, data) {
+			t.Errorf("%s: data changed", c)
+		}
+	}
+}
+
+func TestCodecNames(t *testing.T) {
+
+	cases := []struct {
+		fname string
+		codec Codec
+	}{
+		{"fb.csv", NoCompression},
+		{"fb.gob.gz", Gzip},
+		{"fb.JSON.GZ", Gzip},
+		{"fb.gob.zst", Zstd},
+		{"fb.gob.sz", Snappy},
+		{"fb.gob.lz4", LZ4},
+		{"fb.gz.bak", NoCompression},
+	}
+
+	for _, c := range cases {
+		if got := CodecFromName(c.fname); got != c.codec {
+			t.Errorf("CodecFromName(%q) = %s, want %s", c.fname, got, c.codec)
+		}
+		if got, err := ParseCodec(c.codec.String()); err != nil || got != c.codec {
+			t.Errorf("ParseCodec(%q) = %s, %v", c.codec.String(), got, err)
+		}
+	}
+
+	if _, err := ParseCodec("bzip2"); err == nil {
+		t.Errorf("unknown codec parsed")
+	}
+
+	// Unknown codecs have no extension
+	for _, c := range []Codec{-1, LZ4 + 1} {
+		if c.Ext() != "" {
+			t.Errorf("%s has extension %q", c, c.Ext())
+		}
+	}
+}
+
+// Data that are too short to hold a magic number are not compressed.
+func TestDetectCodecShort(t *testing.T) {
+
+	for _, s := range []string{"", "a", "\x1f"} {
+		c, err := DetectCodec(bufio.NewReader(strings.NewReader(s)))
+		if err != nil || c != NoCompression {
+			t.Errorf("%q: detected %s, %v", s, c, err)
+		}
+	}
+}
@@ -0,0 +1,532 @@
+package notable
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// Column files
+//
+// A column file holds People data in row groups, so that it can be
+// processed one group at a time without loading the whole dataset
+// into memory.  Within each row group, every column is stored as a
+// separately compressed chunk, so that readers can skip the columns
+// they do not need.  The layout of a column file is:
+//
+//	magic                  8 bytes
+//	row group 0            one chunk per field
+//	row group 1
+//	...
+//	footer                 gob-encoded index of the chunks
+//	footer length          8 bytes, little-endian
+//	magic                  8 bytes
+//
+// Each chunk is a gob stream, compressed with the file's codec,
+// holding the column followed (for nullable fields) by its validity
+// bitmap.  Dictionary-encoded columns are stored as their codes and
+// the dictionary of the row group.  Column files of version 1, written
+// before the columns were dictionary-encoded, are not read, and must be
+// converted again.
+
+// colMagic starts and ends every column file.
+var colMagic = []byte("NTBLCOL1")
+
+// colVersion is the version of the column file layout.
+const colVersion = 2
+
+// DefaultGroupSize is the default number of rows in each row group of
+// a column file.
+const DefaultGroupSize = 1 << 16
+
+// colFooter is the index stored at the end of a column file.
+type colFooter struct {
+
+	// The version of the file layout
+	Version int
+
+	// The codec used to compress the chunks
+	Codec Codec
+
+	// The total number of rows
+	Rows int
+
+	// The row groups, in order
+	Groups []colGroup
+
+	// The version of Person used to write the file (see
+	// SchemaVersion)
+	Schema int
+
+	// When the file was created
+	Created time.Time
+
+	// The SHA-256 checksum of the file the data were converted from,
+	// or zero if not known
+	Source [sha256.Size]byte
+}
+
+// colGroup describes one row group of a column file.
+type colGroup struct {
+
+	// The number of rows in the group
+	Rows int
+
+	// The location of each column's chunk, indexed by Field
+	Chunks []colChunk
+}
+
+// colChunk gives the position of one chunk within a column file.
+type colChunk struct {
+	Offset int64
+	Length int64
+}
+
+// ColumnWriter writes People data to a column file.
+type ColumnWriter struct {
+
+	// The codec used to compress each chunk.  It may be changed
+	// before the first call to Write.
+	Codec Codec
+
+	// The number of rows in each row group
+	groupSize int
+
+	// The file being written
+	fid *os.File
+
+	// Buffers writes to fid
+	buf *bufio.Writer
+
+	// The current position in the file
+	offset int64
+
+	// The rows of the group being assembled
+	group People
+
+	// The index of the groups written so far
+	footer colFooter
+}
+
+// NewColumnWriter creates a column file with the given number of rows
+// in each row group.  If groupSize is not positive, DefaultGroupSize
+// is used.  The chunks are compressed with gzip unless the Codec
+// field is changed.
+func NewColumnWriter(fname string, groupSize int) (*ColumnWriter, error) {
+
+	if groupSize <= 0 {
+		groupSize = DefaultGroupSize
+	}
+
+	fid, err := os.Create(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &ColumnWriter{
+		Codec:     Gzip,
+		groupSize: groupSize,
+		fid:       fid,
+		buf:       bufio.NewWriter(fid),
+	}
+	w.footer.Created = time.Now()
+
+	if err := w.write(colMagic); err != nil {
+		fid.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// SetSource records the checksum of the file that the data are being
+// converted from in the footer.
+func (w *ColumnWriter) SetSource(fname string) error {
+
+	sum, err := Checksum(fname)
+	if err != nil {
+		return err
+	}
+	w.footer.Source = sum
+
+	return nil
+}
+
+// write writes b to the file, keeping track of the position.
+func (w *ColumnWriter) write(b []byte) error {
+	n, err := w.buf.Write(b)
+	w.offset += int64(n)
+	return err
+}
+
+// Write adds one person to the file.
+func (w *ColumnWriter) Write(person Person) error {
+
+	w.group.Append(person)
+	if w.group.Len() >= w.groupSize {
+		return w.flushGroup()
+	}
+
+	return nil
+}
+
+// WritePeople adds all the people in the collection to the file.
+func (w *ColumnWriter) WritePeople(people *People) error {
+
+	for i := 0; i < people.Len(); i++ {
+		if err := w.Write(people.Row(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// flushGroup writes the rows of the current group to the file.
+func (w *ColumnWriter) flushGroup() error {
+
+	if w.group.Len() == 0 {
+		return nil
+	}
+
+	group := colGroup{
+		Rows:   w.group.Len(),
+		Chunks: make([]colChunk, numFields),
+	}
+
+	for _, f := range Fields() {
+
+		chunk, err := w.encodeChunk(f)
+		if err != nil {
+			return err
+		}
+
+		group.Chunks[f] = colChunk{Offset: w.offset, Length: int64(len(chunk))}
+		if err := w.write(chunk); err != nil {
+			return err
+		}
+	}
+
+	w.footer.Groups = append(w.footer.Groups, group)
+	w.footer.Rows += group.Rows
+	w.group = People{}
+
+	return nil
+}
+
+// encodeChunk returns the compressed chunk holding the given column
+// of the current group.
+func (w *ColumnWriter) encodeChunk(f Field) ([]byte, error) {
+
+	var buf bytes.Buffer
+	zw, err := w.Codec.NewWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	enc := gob.NewEncoder(zw)
+	if err := enc.Encode(w.group.column(f)); err != nil {
+		return nil, err
+	}
+
+	if f.Nullable() {
+		valid := w.group.Valid[f]
+		if valid == nil {
+			valid = &Bitmap{}
+		}
+		if err := enc.Encode(valid); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Close writes any remaining rows and the footer, then closes the
+// file.  It returns the first error that occurs.
+func (w *ColumnWriter) Close() error {
+
+	err := w.flushGroup()
+
+	if err == nil {
+		err = w.writeFooter()
+	}
+
+	if err == nil {
+		err = w.buf.Flush()
+	}
+
+	if cerr := w.fid.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// writeFooter writes the footer and trailing magic number.
+func (w *ColumnWriter) writeFooter() error {
+
+	w.footer.Version = colVersion
+	w.footer.Codec = w.Codec
+	w.footer.Schema = SchemaVersion
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&w.footer); err != nil {
+		return err
+	}
+
+	n := make([]byte, 8)
+	binary.LittleEndian.PutUint64(n, uint64(buf.Len()))
+
+	for _, b := range [][]byte{buf.Bytes(), n, colMagic} {
+		if err := w.write(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ColumnFile reads People data from a column file, one row group at
+// a time.
+type ColumnFile struct {
+
+	// The file being read
+	fid *os.File
+
+	// The index of the file
+	footer colFooter
+}
+
+// IsColumnFile returns true if the named file is a column file.
+func IsColumnFile(fname string) bool {
+
+	fid, err := os.Open(fname)
+	if err != nil {
+		return false
+	}
+	defer fid.Close()
+
+	head := make([]byte, len(colMagic))
+	if _, err := io.ReadFull(fid, head); err != nil {
+		return false
+	}
+
+	return bytes.Equal(head, colMagic)
+}
+
+// OpenColumnFile opens the named column file and reads its index.
+func OpenColumnFile(fname string) (*ColumnFile, error) {
+
+	fid, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	cf := &ColumnFile{fid: fid}
+	if err := cf.readFooter(); err != nil {
+		fid.Close()
+		return nil, fmt.Errorf("notable: %s: %v", fname, err)
+	}
+
+	return cf, nil
+}
+
+// readFooter reads the index from the end of the file.
+func (cf *ColumnFile) readFooter() error {
+
+	fi, err := cf.fid.Stat()
+	if err != nil {
+		return err
+	}
+
+	m := int64(len(colMagic))
+	size := fi.Size()
+	if size < 2*m+8 {
+		return fmt.Errorf("not a column file")
+	}
+
+	tail := make([]byte, 8+m)
+	if _, err := cf.fid.ReadAt(tail, size-8-m); err != nil {
+		return err
+	}
+	if !bytes.Equal(tail[8:], colMagic) {
+		return fmt.Errorf("not a column file")
+	}
+
+	n := int64(binary.LittleEndian.Uint64(tail[0:8]))
+	if n > size-2*m-8 {
+		return fmt.Errorf("corrupt footer length %d", n)
+	}
+
+	r := io.NewSectionReader(cf.fid, size-8-m-n, n)
+	if err := gob.NewDecoder(r).Decode(&cf.footer); err != nil {
+		return err
+	}
+
+	if cf.footer.Version != colVersion {
+		return fmt.Errorf("unsupported column file version %d (this program reads version %d); convert the data again",
+			cf.footer.Version, colVersion)
+	}
+
+	if cf.footer.Schema > SchemaVersion {
+		return fmt.Errorf("file has schema version %d, but this program only reads versions up to %d",
+			cf.footer.Schema, SchemaVersion)
+	}
+
+	return nil
+}
+
+// Header returns a description of the file, made from its footer.
+func (cf *ColumnFile) Header() *Header {
+	return &Header{
+		Format:        ColumnGroups,
+		SchemaVersion: cf.footer.Schema,
+		Rows:          int64(cf.footer.Rows),
+		Created:       cf.footer.Created,
+		Source:        cf.footer.Source,
+	}
+}
+
+// NumRows returns the total number of rows in the file.
+func (cf *ColumnFile) NumRows() int {
+	return cf.footer.Rows
+}
+
+// NumGroups returns the number of row groups in the file.
+func (cf *ColumnFile) NumGroups() int {
+	return len(cf.footer.Groups)
+}
+
+// GroupRows returns the number of rows in the given row group.
+func (cf *ColumnFile) GroupRows(g int) int {
+	return cf.footer.Groups[g].Rows
+}
+
+// ReadGroup reads the given columns of row group g.  If no fields are
+// given, all columns are read.  The columns that are not read have
+// the right length, so that the result can be used with all the
+// methods of People.  Those that are nullable are missing in every
+// row, so that they are not mistaken for data, and the others hold
+// empty strings.
+func (cf *ColumnFile) ReadGroup(g int, fields ...Field) (*People, error) {
+
+	if len(fields) == 0 {
+		fields = Fields()
+	}
+
+	group := cf.footer.Groups[g]
+	people := new(People)
+
+	var read FieldSet
+	for _, f := range fields {
+		if err := cf.readChunk(people, f, group.Chunks[f]); err != nil {
+			return nil, fmt.Errorf("notable: group %d, column %s: %v", g, f, err)
+		}
+		read.Add(f)
+	}
+
+	people.resize(group.Rows)
+
+	for _, f := range Fields() {
+		if !f.Nullable() || read.Has(f) || group.Rows == 0 {
+			continue
+		}
+		if people.Valid == nil {
+			people.Valid = make(map[Field]*Bitmap)
+		}
+		valid := new(Bitmap)
+		valid.Set(group.Rows-1, false)
+		people.Valid[f] = valid
+	}
+
+	return people, nil
+}
+
+// ReadRows reads the given columns of the given rows, in the order
+// the rows are given.  Only the row groups holding the rows are read,
+// each one once.  As with ReadGroup, all columns are read if no fields
+// are given.
+func (cf *ColumnFile) ReadRows(rows []int, fields ...Field) (*People, error) {
+
+	// The first row of each group, and one past the last row
+	starts := make([]int, cf.NumGroups()+1)
+	for g := 0; g < cf.NumGroups(); g++ {
+		starts[g+1] = starts[g] + cf.GroupRows(g)
+	}
+
+	groups := make(map[int]*People)
+	people := new(People)
+	for _, i := range rows {
+
+		if i < 0 || i >= cf.NumRows() {
+			return nil, fmt.Errorf("notable: row %d is out of range, the file has %d rows", i, cf.NumRows())
+		}
+
+		g := sort.SearchInts(starts, i+1) - 1
+		group, ok := groups[g]
+		if !ok {
+			var err error
+			group, err = cf.ReadGroup(g, fields...)
+			if err != nil {
+				return nil, err
+			}
+			groups[g] = group
+		}
+
+		people.Append(group.Row(i - starts[g]))
+	}
+
+	return people, nil
+}
+
+// readChunk decodes one column chunk into people.
+func (cf *ColumnFile) readChunk(people *People, f Field, chunk colChunk) error {
+
+	r := io.NewSectionReader(cf.fid, chunk.Offset, chunk.Length)
+	zr, err := cf.footer.Codec.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	dec := gob.NewDecoder(zr)
+	col := people.column(f)
+	if err := dec.Decode(col); err != nil {
+		return err
+	}
+	if dc, ok := col.(*DictColumn); ok {
+		dc.buildIndex()
+	}
+
+	if f.Nullable() {
+		valid := new(Bitmap)
+		if err := dec.Decode(valid); err != nil {
+			return err
+		}
+		if valid.N > 0 {
+			if people.Valid == nil {
+				people.Valid = make(map[Field]*Bitmap)
+			}
+			people.Valid[f] = valid
+		}
+	}
+
+	return nil
+}
+
+// Close closes the file.
+func (cf *ColumnFile) Close() error {
+	return cf.fid.Close()
+}
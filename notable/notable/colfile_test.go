@@ -0,0 +1,170 @@
+package notable
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestColumnFileGroups(t *testing.T) {
+
+	people := samplePeople()
+	fname := filepath.Join(t.TempDir(), "people.ncol")
+	writePeople(t, fname, &people)
+
+	if !IsColumnFile(fname) {
+		t.Fatalf("%s is not a column file", fname)
+	}
+
+	cf, err := OpenColumnFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cf.Close()
+
+	// writePeople puts three rows in each group
+	if cf.NumRows() != 4 || cf.NumGroups() != 2 || cf.GroupRows(0) != 3 || cf.GroupRows(1) != 1 {
+		t.Errorf("%d rows in %d groups", cf.NumRows(), cf.NumGroups())
+	}
+	if h := cf.Header(); h.Format != ColumnGroups || h.Rows != 4 {
+		t.Errorf("header %s", h)
+	}
+
+	var row int
+	for g := 0; g < cf.NumGroups(); g++ {
+		group, err := cf.ReadGroup(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < group.Len(); i++ {
+			if !reflect.DeepEqual(group.Row(i), people.Row(row)) {
+				t.Errorf("row %d is %+v, want %+v", row, group.Row(i), people.Row(row))
+			}
+			row++
+		}
+	}
+}
+
+// Nullable columns that are not read are missing, and the others
+// hold empty strings.
+func TestColumnFileReadFields(t *testing.T) {
+
+	people := samplePeople()
+	fname := filepath.Join(t.TempDir(), "people.ncol")
+	writePeople(t, fname, &people)
+
+	cf, err := OpenColumnFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cf.Close()
+
+	var unread FieldSet
+	for _, f := range []Field{FieldBYear, FieldBLocLat, FieldBLocLong, FieldDLocLat, FieldDLocLong} {
+		unread.Add(f)
+	}
+	missing := unread
+	missing.Add(FieldDYear)
+
+	cases := []struct {
+		group int
+		want  []Person
+	}{
+		{0, []Person{{PrsLabel: "Ada", DYear: 1852, NA: unread}, {PrsLabel: "Carl", DYear: 1855, NA: unread},
+			{PrsLabel: "Emmy", NA: missing}}},
+		{1, []Person{{PrsLabel: "Hypatia", NA: missing}}},
+	}
+
+	for _, c := range cases {
+		group, err := cf.ReadGroup(c.group, FieldPrsLabel, FieldDYear)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if group.Len() != len(c.want) {
+			t.Errorf("group %d has %d rows, want %d", c.group, group.Len(), len(c.want))
+			continue
+		}
+		for i, want := range c.want {
+			if got := group.Row(i); !reflect.DeepEqual(got, want) {
+				t.Errorf("group %d, row %d is %+v, want %+v", c.group, i, got, want)
+			}
+		}
+	}
+}
+
+func TestColumnFileReadRows(t *testing.T) {
+
+	people := samplePeople()
+	fname := filepath.Join(t.TempDir(), "people.ncol")
+	writePeople(t, fname, &people)
+
+	cf, err := OpenColumnFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cf.Close()
+
+	cases := [][]int{
+		{3},
+		{3, 0, 1},
+		{2, 2},
+		nil,
+	}
+
+	for _, rows := range cases {
+		got, err := cf.ReadRows(rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := people.Take(rows)
+		if got.Len() != want.Len() {
+			t.Errorf("rows %v: %d rows read, want %d", rows, got.Len(), want.Len())
+			continue
+		}
+		for i := range rows {
+			if !reflect.DeepEqual(got.Row(i), want.Row(i)) {
+				t.Errorf("rows %v: row %d is %+v, want %+v", rows, i, got.Row(i), want.Row(i))
+			}
+		}
+	}
+
+	if _, err := cf.ReadRows([]int{4}); err == nil {
+		t.Errorf("row out of range was read")
+	}
+}
+
+func TestColumnFileSource(t *testing.T) {
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "people.csv")
+	writeCSV(t, src)
+
+	fname := filepath.Join(dir, "people.ncol")
+	cw, err := NewColumnWriter(fname, DefaultGroupSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cw.SetSource(src); err != nil {
+		t.Fatal(err)
+	}
+	if err := cw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := ReadHeader(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := Checksum(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Source != sum || h.Rows != 0 {
+		t.Errorf("header %s", h)
+	}
+
+	// Other files are not column files
+	if IsColumnFile(src) {
+		t.Errorf("%s is a column file", src)
+	}
+}
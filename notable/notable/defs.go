@@ -1,12 +1,15 @@
 package notable
 
 import (
+	"bufio"
 	"compress/gzip"
 	"encoding/csv"
 	"encoding/gob"
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
+	"time"
 )
 
 // A struct holding information about a notable person
@@ -41,9 +44,14 @@ type Person struct {
 
 	// The person's gender
 	Gender string
+
+	// The fields whose values are missing
+	NA FieldSet
 }
 
 // A struct holding information about a collection of notable people.
+// The location and gender columns repeat a small set of values, so
+// they are dictionary-encoded (see DictColumn).
 type People struct {
 
 	// The person's name
@@ -53,7 +61,7 @@ type People struct {
 	BYear []int
 
 	// The person's birth location
-	BLocLabel []string
+	BLocLabel DictColumn
 
 	// The latitude of the person's birth location
 	BLocLat []float64
@@ -65,7 +73,7 @@ type People struct {
 	DYear []int
 
 	// The location where the person died
-	DLocLabel []string
+	DLocLabel DictColumn
 
 	// The latitude of the location where the person died
 	DLocLat []float64
@@ -74,86 +82,442 @@ type People struct {
 	DLocLong []float64
 
 	// The person's gender
-	Gender []string
+	Gender DictColumn
+
+	// Validity bitmaps for the columns that have missing values.  A
+	// zero bit indicates that the value in that row is missing.  Rows
+	// past the end of a bitmap, and all rows of a column without a
+	// bitmap, are valid.
+	Valid map[Field]*Bitmap
 }
 
-// GetCSVWriter returns two Closer's and a csv.Writer for writing
-// csv formatted data to the given file.
-func GetCSVWriter(fname string) (io.Closer, io.Closer, *csv.Writer) {
+// fileWriter holds the file and the compression layer that sit
+// underneath an encoder.
+type fileWriter struct {
+
+	// The file being written
+	fid *os.File
+
+	// Compresses the data before it is written to fid
+	zw io.WriteCloser
+
+	// The header, which is written again with the final number of
+	// records when the file is closed
+	hdr Header
+
+	// The position of the header in the file, or -1 if the file has
+	// no header
+	hdrAt int64
+
+	// The number of values written
+	n int64
+
+	// The first error returned by the encoder
+	werr error
+}
+
+// createFile creates the named file, which will hold records in the
+// given format, and wraps it in a compressor chosen from the file name
+// extension (see CodecFromName).
+func createFile(fname string, format Format) (*fileWriter, error) {
 
 	// Open a file for writing
 	fid, err := os.Create(fname)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	// Write compressed data to the file
-	out := gzip.NewWriter(fid)
+	fw := &fileWriter{
+		fid: fid,
+		hdr: Header{Format: format, SchemaVersion: SchemaVersion, Rows: -1, Created: time.Now()},
+	}
 
-	// Write CSV-formatted data to the file
-	cout := csv.NewWriter(out)
+	// Write compressed data to the file, starting with the header
+	fw.zw, fw.hdrAt, err = newHeaderWriter(fid, CodecFromName(fname), &fw.hdr)
+	if err != nil {
+		fid.Close()
+		return nil, err
+	}
 
-	return fid, out, cout
+	return fw, nil
 }
 
-// GetJSONEncoder returns two io.Closer's and a json encoder for writing to
-// the given file.
-func GetJSONEncoder(fname string) (io.Closer, io.Closer, *json.Encoder) {
+// count records that v is about to be written, determining the
+// format of the file from the type of the first value.  Values of
+// other types leave the format and the number of records unknown.
+func (fw *fileWriter) count(v interface{}, text bool) {
+
+	if fw.n == 0 {
+		switch v.(type) {
+		case []string, *[]string:
+			fw.hdr.Format = GobRows
+			if text {
+				fw.hdr.Format = JSONRows
+			}
+		case Person, *Person:
+			fw.hdr.Format = GobStructs
+			if text {
+				fw.hdr.Format = JSONStructs
+			}
+		case People, *People:
+			if !text {
+				fw.hdr.Format = GobColumns
+			}
+		}
+	}
+	fw.n++
 
-	// Open a file for writing
-	fid, err := os.Create(fname)
+	switch p := v.(type) {
+	case People:
+		fw.hdr.Rows = int64(p.Len())
+	case *People:
+		fw.hdr.Rows = int64(p.Len())
+	}
+}
+
+// check records err if it is the first error returned by the
+// encoder, and returns it.
+func (fw *fileWriter) check(err error) error {
+	if fw.werr == nil {
+		fw.werr = err
+	}
+	return err
+}
+
+// setSource records the checksum of the named source file in the
+// header.
+func (fw *fileWriter) setSource(fname string) error {
+
+	sum, err := Checksum(fname)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	fw.hdr.Source = sum
 
-	// Write compressed data to the file
-	out := gzip.NewWriter(fid)
+	return nil
+}
 
-	// Write the data in json format
-	enc := json.NewEncoder(out)
+// close closes the compression layer and then the file.  It returns
+// err if it is not nil, otherwise the first error that occurs while
+// closing.  If there is an error, the header is marked as incomplete.
+func (fw *fileWriter) close(err error) error {
 
-	return fid, out, enc
+	if err == nil {
+		err = fw.werr
+	}
+	if cerr := fw.zw.Close(); err == nil {
+		err = cerr
+	}
+
+	// Mark the file if it may not hold all the records
+	fw.hdr.Incomplete = err != nil
+
+	// Fill in the number of records.  Row-oriented files start with
+	// a row of column labels, which is not a record.
+	switch fw.hdr.Format {
+	case CSVRows, JSONRows, GobRows:
+		fw.hdr.Rows = fw.n - 1
+		if fw.hdr.Rows < 0 {
+			fw.hdr.Rows = 0
+		}
+	case JSONStructs, GobStructs:
+		fw.hdr.Rows = fw.n
+	}
+	if fw.hdrAt >= 0 {
+		if _, werr := fw.fid.WriteAt(fw.hdr.marshal(), fw.hdrAt); err == nil {
+			err = werr
+		}
+	}
+
+	if cerr := fw.fid.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
 }
 
-// GetGobDecoder returns a decoder for reading from a gob-encoded data source.
-// It also returns two system resources that should be closed
-// after the decoder is no longer needed.
-func GetGobDecoder(fname string) (io.Closer, io.Closer, *gob.Decoder) {
+// fileReader holds the file and the decompression layer that sit
+// underneath a decoder.
+type fileReader struct {
+
+	// The file being read
+	fid *os.File
+
+	// Decompresses the data read from fid
+	zr io.ReadCloser
+
+	// The header of the file, or nil if it has none
+	hdr *Header
+}
+
+// openFile opens the named file and wraps it in a decompressor
+// chosen by inspecting the leading bytes of the file (see
+// DetectCodec).
+func openFile(fname string) (*fileReader, error) {
 
 	// Open a reader for the file
 	fid, err := os.Open(fname)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	// Read the header, if there is one
+	br := bufio.NewReader(fid)
+	hdr, err := readHeader(br)
+	if err != nil {
+		fid.Close()
+		return nil, fmt.Errorf("notable: %s: %v", fname, err)
+	}
+
+	// Determine how the file is compressed
+	codec, err := DetectCodec(br)
+	if err != nil {
+		fid.Close()
+		return nil, err
 	}
 
 	// Decompress the stream on-the-fly
-	gid, err := gzip.NewReader(fid)
+	zr, err := codec.NewReader(br)
+	if err != nil {
+		fid.Close()
+		return nil, err
+	}
+
+	// A gzip stream holds the header in its own header
+	if gz, ok := zr.(*gzip.Reader); ok && hdr == nil {
+		if hdr, err = gzipHeader(gz); err != nil {
+			zr.Close()
+			fid.Close()
+			return nil, fmt.Errorf("notable: %s: %v", fname, err)
+		}
+	}
+
+	return &fileReader{fid: fid, zr: zr, hdr: hdr}, nil
+}
+
+// close closes the decompression layer and then the file, returning
+// the first error that occurs.
+func (fr *fileReader) close() error {
+
+	err := fr.zr.Close()
+
+	if cerr := fr.fid.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// CSVWriter writes compressed CSV data to a file.
+type CSVWriter struct {
+	*csv.Writer
+
+	fw *fileWriter
+}
+
+// NewCSVWriter returns a CSVWriter that writes to the given file.
+func NewCSVWriter(fname string) (*CSVWriter, error) {
+
+	fw, err := createFile(fname, CSVRows)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CSVWriter{Writer: csv.NewWriter(fw.zw), fw: fw}, nil
+}
+
+// Write writes one row, counting it for the file header.  The first
+// row holds the column labels.
+func (w *CSVWriter) Write(row []string) error {
+	w.fw.n++
+	return w.Writer.Write(row)
+}
+
+// SetSource records the checksum of the file that the data are being
+// converted from in the file header.
+func (w *CSVWriter) SetSource(fname string) error {
+	return w.fw.setSource(fname)
+}
+
+// Close flushes any buffered CSV data, then closes the compression stream
+// and the file.  It returns the first error that occurs.
+func (w *CSVWriter) Close() error {
+	w.Flush()
+	return w.fw.close(w.Error())
+}
+
+// JSONEncoder writes compressed json data to a file.
+type JSONEncoder struct {
+	*json.Encoder
+
+	fw *fileWriter
+}
+
+// NewJSONEncoder returns a JSONEncoder that writes to the given file.
+func NewJSONEncoder(fname string) (*JSONEncoder, error) {
+
+	fw, err := createFile(fname, UnknownFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JSONEncoder{Encoder: json.NewEncoder(fw.zw), fw: fw}, nil
+}
+
+// Encode writes the json encoding of v, counting it for the file
+// header.
+func (e *JSONEncoder) Encode(v interface{}) error {
+	e.fw.count(v, true)
+	return e.fw.check(e.Encoder.Encode(v))
+}
+
+// SetSource records the checksum of the file that the data are being
+// converted from in the file header.
+func (e *JSONEncoder) SetSource(fname string) error {
+	return e.fw.setSource(fname)
+}
+
+// Close closes the compression stream and the file, returning the first
+// error that occurs.
+func (e *JSONEncoder) Close() error {
+	return e.fw.close(nil)
+}
+
+// GobEncoder writes compressed gob data to a file.
+type GobEncoder struct {
+	*gob.Encoder
+
+	fw *fileWriter
+}
+
+// NewGobEncoder returns a GobEncoder that writes to the given file.
+func NewGobEncoder(fname string) (*GobEncoder, error) {
+
+	fw, err := createFile(fname, UnknownFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GobEncoder{Encoder: gob.NewEncoder(fw.zw), fw: fw}, nil
+}
+
+// Encode writes the gob encoding of v, counting it for the file
+// header.
+func (e *GobEncoder) Encode(v interface{}) error {
+	e.fw.count(v, false)
+	return e.fw.check(e.Encoder.Encode(v))
+}
+
+// SetSource records the checksum of the file that the data are being
+// converted from in the file header.
+func (e *GobEncoder) SetSource(fname string) error {
+	return e.fw.setSource(fname)
+}
+
+// Close closes the compression stream and the file, returning the first
+// error that occurs.
+func (e *GobEncoder) Close() error {
+	return e.fw.close(nil)
+}
+
+// GobDecoder reads compressed gob data from a file.
+type GobDecoder struct {
+	*gob.Decoder
+
+	fr *fileReader
+}
+
+// NewGobDecoder returns a GobDecoder that reads from the given file.
+func NewGobDecoder(fname string) (*GobDecoder, error) {
+
+	fr, err := openFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	if err := fr.hdr.complete(fname); err != nil {
+		fr.close()
+		return nil, err
+	}
+
+	return &GobDecoder{Decoder: gob.NewDecoder(fr.zr), fr: fr}, nil
+}
+
+// Header returns the header of the file, or nil if the file has no
+// header.
+func (d *GobDecoder) Header() *Header {
+	return d.fr.hdr
+}
+
+// Expect returns an error if the header of the file shows that it
+// does not hold records in the given format.  Files without a header
+// are not checked.
+func (d *GobDecoder) Expect(format Format) error {
+	return d.fr.hdr.expect(d.fr.fid.Name(), format)
+}
+
+// Close closes the compression stream and the file, returning the first
+// error that occurs.
+func (d *GobDecoder) Close() error {
+	return d.fr.close()
+}
+
+// GetCSVWriter returns two Closer's and a csv.Writer for writing
+// csv formatted data to the given file.  It panics if the file
+// cannot be created; see NewCSVWriter for a version that returns
+// an error.  The file header does not record the number of rows,
+// which is only known to a CSVWriter.
+func GetCSVWriter(fname string) (io.Closer, io.Closer, *csv.Writer) {
+
+	w, err := NewCSVWriter(fname)
+	if err != nil {
+		panic(err)
+	}
+
+	return w.fw.fid, w.fw.zw, w.Writer
+}
+
+// GetJSONEncoder returns two io.Closer's and a json encoder for writing to
+// the given file.  It panics if the file cannot be created; see
+// NewJSONEncoder for a version that returns an error.  The file
+// header records neither the format nor the number of records, which
+// are only known to a JSONEncoder.
+func GetJSONEncoder(fname string) (io.Closer, io.Closer, *json.Encoder) {
+
+	e, err := NewJSONEncoder(fname)
 	if err != nil {
 		panic(err)
 	}
 
-	// Use this to decode
-	dec := gob.NewDecoder(gid)
+	return e.fw.fid, e.fw.zw, e.Encoder
+}
+
+// GetGobDecoder returns a decoder for reading from a gob-encoded data source.
+// It also returns two system resources that should be closed
+// after the decoder is no longer needed.  It panics if the file
+// cannot be opened; see NewGobDecoder for a version that returns
+// an error.
+func GetGobDecoder(fname string) (io.Closer, io.Closer, *gob.Decoder) {
 
-	return fid, gid, dec
+	d, err := NewGobDecoder(fname)
+	if err != nil {
+		panic(err)
+	}
+
+	return d.fr.fid, d.fr.zr, d.Decoder
 }
 
 // GetGobEncoder returns an encoder for writing gob-encoded data to a stream.
 // It also returns two system resources that should be closed
-// after the encoder is no longer needed.
+// after the encoder is no longer needed.  It panics if the file
+// cannot be created; see NewGobEncoder for a version that returns
+// an error.  The file header records neither the format nor the
+// number of records, which are only known to a GobEncoder.
 func GetGobEncoder(fname string) (io.Closer, io.Closer, *gob.Encoder) {
 
-	// Open a reader for the file
-	fid, err := os.Create(fname)
+	e, err := NewGobEncoder(fname)
 	if err != nil {
 		panic(err)
 	}
 
-	// Decompress the stream on-the-fly
-	gid := gzip.NewWriter(fid)
-
-	// Use this to encode
-	enc := gob.NewEncoder(gid)
-
-	return fid, gid, enc
+	return e.fw.fid, e.fw.zw, e.Encoder
 }
@@ -0,0 +1,165 @@
+package notable
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// openFiles returns the number of files open in this process.
+func openFiles(t *testing.T) int {
+
+	t.Helper()
+
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("open files cannot be counted: %v", err)
+	}
+
+	return len(fds)
+}
+
+// A constructor that fails closes the file it opened.
+func TestOpenError(t *testing.T) {
+
+	dir := t.TempDir()
+
+	// A file whose writer stopped on an error
+	incomplete := filepath.Join(dir, "incomplete.gob.gz")
+	e, err := NewGobEncoder(incomplete)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.fw.close(errors.New("stopped"))
+
+	// A gzip stream with a corrupt header, and a column file with
+	// no footer
+	corrupt := filepath.Join(dir, "corrupt.gob.gz")
+	if err := os.WriteFile(corrupt, []byte{0x1f, 0x8b, 0xff, 0, 0, 0, 0, 0, 0, 0}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	truncated := filepath.Join(dir, "truncated.ncol")
+	if err := os.WriteFile(truncated, append(append([]byte{}, colMagic...), colMagic...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing", "people.csv.gz")
+
+	open := []struct {
+		name string
+		open func(fname string) error
+	}{
+		{"NewReader", func(fname string) error { _, err := NewReader(fname); return err }},
+		{"NewGobDecoder", func(fname string) error { _, err := NewGobDecoder(fname); return err }},
+		{"OpenColumnFile", func(fname string) error { _, err := OpenColumnFile(fname); return err }},
+		{"NewCSVWriter", func(fname string) error { _, err := NewCSVWriter(fname); return err }},
+	}
+	cases := []struct {
+		open  int
+		fname string
+	}{
+		{0, incomplete},
+		{0, corrupt},
+		{0, truncated},
+		{1, incomplete},
+		{1, corrupt},
+		{2, corrupt},
+		{2, truncated},
+		{3, missing},
+	}
+
+	for _, c := range cases {
+		n := openFiles(t)
+		if err := open[c.open].open(c.fname); err == nil {
+			t.Errorf("%s(%s) succeeded", open[c.open].name, filepath.Base(c.fname))
+		}
+		if m := openFiles(t); m != n {
+			t.Errorf("%s(%s) left %d files open", open[c.open].name, filepath.Base(c.fname), m-n)
+		}
+	}
+}
+
+// Close flushes the compressor before closing the file, so that every
+// record can be read back and the header is complete.
+func TestWriterClose(t *testing.T) {
+
+	people := samplePeople()
+	var labels []string
+	for _, f := range Fields() {
+		labels = append(labels, f.String())
+	}
+	p := people.Row(0)
+	row := p.Strings()
+
+	// Enough rows to fill the buffers of the compressors
+	const n = 20000
+
+	writers := []struct {
+		name  string
+		write func(fname string) error
+	}{
+		{"rows.csv", func(fname string) error {
+			w, err := NewCSVWriter(fname)
+			if err != nil {
+				return err
+			}
+			w.Write(labels)
+			for i := 0; i < n; i++ {
+				w.Write(row)
+			}
+			return w.Close()
+		}},
+		{"rows.json", func(fname string) error {
+			e, err := NewJSONEncoder(fname)
+			if err != nil {
+				return err
+			}
+			e.Encode(labels)
+			for i := 0; i < n; i++ {
+				e.Encode(row)
+			}
+			return e.Close()
+		}},
+		{"structs.gob", func(fname string) error {
+			e, err := NewGobEncoder(fname)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < n; i++ {
+				e.Encode(p)
+			}
+			return e.Close()
+		}},
+	}
+
+	for _, w := range writers {
+		for _, ext := range []string{"", ".gz", ".zst", ".sz", ".lz4"} {
+			fname := filepath.Join(t.TempDir(), w.name+ext)
+			nopen := openFiles(t)
+			if err := w.write(fname); err != nil {
+				t.Errorf("%s: %v", fname, err)
+				continue
+			}
+			if m := openFiles(t); m != nopen {
+				t.Errorf("%s: %d files left open", filepath.Base(fname), m-nopen)
+			}
+
+			rdr, err := NewReader(fname)
+			if err != nil {
+				t.Errorf("%s: %v", fname, err)
+				continue
+			}
+			var k int
+			for ; rdr.Next(); k++ {
+			}
+			if err := rdr.Err(); err != nil || k != n {
+				t.Errorf("%s: read %d records, want %d: %v", filepath.Base(fname), k, n, err)
+			}
+			// Uncompressed files have no header
+			if h := rdr.Header(); ext != "" && (h == nil || h.Rows != n || h.Incomplete) {
+				t.Errorf("%s: header %v", filepath.Base(fname), h)
+			}
+			rdr.Close()
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package notable
+
+// A DictColumn is a dictionary-encoded column of strings.  Each row
+// holds an integer code, which is the position of the row's value in
+// the dictionary.  Columns that repeat a small set of values, such as
+// locations and gender, take much less space in this form, and can be
+// grouped by code without hashing the strings.
+type DictColumn struct {
+
+	// The code of each row
+	Codes []int32
+
+	// The distinct values, indexed by code
+	Dict []string
+
+	// Map from value to code.  It is built when the column is
+	// created or decoded, never by a lookup, so that a column that
+	// is not being changed can be read from several goroutines.
+	index map[string]int32
+}
+
+// NewDictColumn returns a column holding the given values.
+func NewDictColumn(values []string) DictColumn {
+
+	var c DictColumn
+	for _, v := range values {
+		c.Append(v)
+	}
+
+	return c
+}
+
+// Len returns the number of rows in the column.
+func (c *DictColumn) Len() int {
+	return len(c.Codes)
+}
+
+// NumCodes returns the number of distinct codes, which is the size of
+// the dictionary.  Codes run from 0 to NumCodes()-1.
+func (c *DictColumn) NumCodes() int {
+	return len(c.Dict)
+}
+
+// Value returns the string held in row i.
+func (c *DictColumn) Value(i int) string {
+	return c.Dict[c.Codes[i]]
+}
+
+// Code returns the code of the given value, and false if the value is
+// not in the dictionary.  Code does not change the column, so it may be
+// called from several goroutines at once.  If the column was built
+// from its fields rather than by this package, the dictionary is
+// searched.
+func (c *DictColumn) Code(v string) (int32, bool) {
+
+	if c.index != nil {
+		code, ok := c.index[v]
+		return code, ok
+	}
+
+	for code, w := range c.Dict {
+		if w == v {
+			return int32(code), true
+		}
+	}
+
+	return 0, false
+}
+
+// Append adds a value to the end of the column, extending the
+// dictionary if the value has not been seen before.
+func (c *DictColumn) Append(v string) {
+	c.Codes = append(c.Codes, c.intern(v))
+}
+
+// Strings returns the values of the column as a slice of strings.
+func (c *DictColumn) Strings() []string {
+
+	x := make([]string, len(c.Codes))
+	for i, code := range c.Codes {
+		x[i] = c.Dict[code]
+	}
+
+	return x
+}
+
+// Slice returns rows i through j-1 of the column.  The codes share
+// storage with c, and the dictionary is shared until either column
+// adds a new value to it.  Neither has spare capacity, so appending to
+// the result copies them rather than overwriting the rows of c.
+func (c *DictColumn) Slice(i, j int) DictColumn {
+
+	s := DictColumn{
+		Codes: c.Codes[i:j:j],
+		Dict:  c.Dict[0:len(c.Dict):len(c.Dict)],
+	}
+	s.buildIndex()
+
+	return s
+}
+
+// intern returns the code of v, adding v to the dictionary if needed.
+func (c *DictColumn) intern(v string) int32 {
+
+	c.buildIndex()
+	code, ok := c.index[v]
+	if !ok {
+		code = int32(len(c.Dict))
+		c.Dict = append(c.Dict, v)
+		c.index[v] = code
+	}
+
+	return code
+}
+
+// buildIndex creates the map from values to codes if it is not
+// present, as is the case after the column is decoded.  It must be
+// called before the column is shared between goroutines.
+func (c *DictColumn) buildIndex() {
+
+	if c.index != nil {
+		return
+	}
+
+	c.index = make(map[string]int32, len(c.Dict))
+	for code, v := range c.Dict {
+		if _, ok := c.index[v]; !ok {
+			c.index[v] = int32(code)
+		}
+	}
+}
+
+// resize sets the length of the column to n, filling any new rows
+// with the empty string.
+func (c *DictColumn) resize(n int) {
+
+	if len(c.Codes) >= n {
+		c.Codes = c.Codes[0:n]
+		return
+	}
+
+	code := c.intern("")
+	for len(c.Codes) < n {
+		c.Codes = append(c.Codes, code)
+	}
+}
@@ -0,0 +1,91 @@
+package notable
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestDictColumn(t *testing.T) {
+
+	cases := []struct {
+		values []string
+		dict   []string
+		codes  []int32
+	}{
+		{nil, nil, nil},
+		{[]string{"a"}, []string{"a"}, []int32{0}},
+		{[]string{"b", "a", "b", "", "a"}, []string{"b", "a", ""}, []int32{0, 1, 0, 2, 1}},
+	}
+
+	for _, c := range cases {
+		col := NewDictColumn(c.values)
+		if !reflect.DeepEqual(col.Dict, c.dict) || !reflect.DeepEqual(col.Codes, c.codes) {
+			t.Errorf("%q: got dict %q codes %v, want %q %v", c.values, col.Dict, col.Codes, c.dict, c.codes)
+		}
+		if got := col.Strings(); len(c.values) > 0 && !reflect.DeepEqual(got, c.values) {
+			t.Errorf("%q: Strings gave %q", c.values, got)
+		}
+		for code, v := range c.dict {
+			if got, ok := col.Code(v); !ok || got != int32(code) {
+				t.Errorf("%q: Code(%q) = %d, %v", c.values, v, got, ok)
+			}
+		}
+		if _, ok := col.Code("missing"); ok {
+			t.Errorf("%q: found a value not in the dictionary", c.values)
+		}
+	}
+}
+
+// A column built from its fields, as after decoding, has no index
+// until one is built.
+func TestDictColumnCodeWithoutIndex(t *testing.T) {
+
+	col := DictColumn{Codes: []int32{1, 0}, Dict: []string{"x", "y"}}
+	if code, ok := col.Code("y"); !ok || code != 1 {
+		t.Errorf("Code(y) = %d, %v", code, ok)
+	}
+	if col.index != nil {
+		t.Errorf("Code built the index")
+	}
+}
+
+// Appending to a slice of a column must not change the parent.
+func TestDictColumnSliceAppend(t *testing.T) {
+
+	col := NewDictColumn([]string{"a", "b", "c", "a"})
+	part := col.Slice(1, 2)
+	part.Append("d")
+	part.Append("a")
+
+	if got, want := col.Strings(), []string{"a", "b", "c", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parent changed to %q", got)
+	}
+	if got, want := part.Strings(), []string{"b", "d", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("slice holds %q, want %q", got, want)
+	}
+	if _, ok := col.Code("d"); ok {
+		t.Errorf("value added to the slice is in the parent's dictionary")
+	}
+}
+
+// Lookups from several goroutines must not race; run with -race.
+func TestDictColumnConcurrentCode(t *testing.T) {
+
+	col := DictColumn{Codes: []int32{0, 1, 2}, Dict: []string{"x", "y", "z"}}
+	col.buildIndex()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if code, ok := col.Code("z"); !ok || code != 2 {
+					t.Errorf("Code(z) = %d, %v", code, ok)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,202 @@
+package diversity
+
+import (
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Encode gives each distinct label in x and y a code from 0 to k-1,
+// returning the codes of the labels in each slice and k.  The codes
+// are shared, so that counts of the two sets of labels refer to the
+// same categories.
+func Encode(x, y []string) ([]int, []int, int) {
+
+	codes := make(map[string]int)
+	encode := func(labels []string) []int {
+		c := make([]int, len(labels))
+		for i, v := range labels {
+			code, ok := codes[v]
+			if !ok {
+				code = len(codes)
+				codes[v] = code
+			}
+			c[i] = code
+		}
+		return c
+	}
+
+	cx := encode(x)
+	cy := encode(y)
+
+	return cx, cy, len(codes)
+}
+
+// Tally returns the number of times each of the codes 0 to k-1
+// appears.
+func Tally(codes []int, k int) []float64 {
+
+	counts := make([]float64, k)
+	for _, c := range codes {
+		counts[c]++
+	}
+
+	return counts
+}
+
+// An Estimate is the value of a measure with a confidence interval.
+type Estimate struct {
+
+	// The value for the data
+	Value float64
+
+	// The limits of the confidence interval
+	Lower, Upper float64
+}
+
+// A Bootstrap computes percentile bootstrap confidence intervals.
+// Each replicate draws as many people as there are in the data, at
+// random with replacement, and computes the measure for them.  The
+// interval holds the central Level fraction of the replicate values.
+// Replicates giving NaN are left out.
+//
+// The replicates are computed in parallel.  Each uses its own random
+// number generator, seeded from Seed and its position, so that the
+// result does not depend on the number of workers.
+type Bootstrap struct {
+
+	// The number of replicates.  Defaults to 1000.
+	Reps int
+
+	// The coverage of the interval.  Defaults to 0.95.
+	Level float64
+
+	// Seeds the resampling
+	Seed int64
+
+	// The number of goroutines computing replicates.  Defaults to
+	// GOMAXPROCS.
+	Workers int
+}
+
+// setDefaults fills in the fields that are not set.
+func (b *Bootstrap) setDefaults() {
+
+	if b.Reps <= 0 {
+		b.Reps = 1000
+	}
+
+	if b.Level <= 0 || b.Level >= 1 {
+		b.Level = 0.95
+	}
+
+	if b.Workers <= 0 {
+		b.Workers = runtime.GOMAXPROCS(0)
+	}
+}
+
+// One estimates a measure of one distribution, given the code of each
+// person's category, from 0 to k-1.
+func (b Bootstrap) One(codes []int, k int, stat func(counts []float64) float64) Estimate {
+
+	return b.run(len(codes), stat(Tally(codes, k)), func(idx []int) float64 {
+		counts := make([]float64, k)
+		for _, i := range idx {
+			counts[codes[i]]++
+		}
+		return stat(counts)
+	})
+}
+
+// Paired estimates a measure that compares two distributions, such as
+// the birth and death locations, given two codes for each person.
+// People are resampled with both of their codes, so that the
+// dependence between the two is kept.
+func (b Bootstrap) Paired(x, y []int, k int, stat func(cx, cy []float64) float64) Estimate {
+
+	if len(x) != len(y) {
+		panic("diversity: paired codes have different lengths")
+	}
+
+	return b.run(len(x), stat(Tally(x, k), Tally(y, k)), func(idx []int) float64 {
+		cx := make([]float64, k)
+		cy := make([]float64, k)
+		for _, i := range idx {
+			cx[x[i]]++
+			cy[y[i]]++
+		}
+		return stat(cx, cy)
+	})
+}
+
+// run computes the replicates of a statistic of n people, given a
+// function computing the statistic for the people with the given
+// positions, and returns the interval around value.
+func (b Bootstrap) run(n int, value float64, stat func(idx []int) float64) Estimate {
+
+	b.setDefaults()
+
+	reps := replicate(b.Reps, b.Workers, n, b.Seed, func(rng *rand.Rand, idx []int) float64 {
+		for i := range idx {
+			idx[i] = rng.Intn(n)
+		}
+		return stat(idx)
+	})
+
+	// Sort the replicates, leaving out NaNs
+	var x []float64
+	for _, v := range reps {
+		if !math.IsNaN(v) {
+			x = append(x, v)
+		}
+	}
+	sort.Float64s(x)
+
+	est := Estimate{Value: value, Lower: math.NaN(), Upper: math.NaN()}
+	if len(x) > 0 {
+		est.Lower = x[int((1-b.Level)/2*float64(len(x)-1))]
+		est.Upper = x[int((1+b.Level)/2*float64(len(x)-1))]
+	}
+
+	return est
+}
+
+// replicate calls fn reps times, using the given number of goroutines,
+// and returns the results in order.  Replicate r is given a random
+// number generator seeded with the r'th value drawn from a generator
+// seeded with seed, so that runs with different seeds share no
+// replicates, and the results do not depend on the number of
+// goroutines.  It is also given a scratch
+// slice of length n, which is allocated once for each goroutine and
+// so holds whatever the previous replicate left in it.
+func replicate(reps, workers, n int, seed int64, fn func(rng *rand.Rand, scratch []int) float64) []float64 {
+
+	seeds := make([]int64, reps)
+	rng := rand.New(rand.NewSource(seed))
+	for r := range seeds {
+		seeds[r] = rng.Int63()
+	}
+
+	x := make([]float64, reps)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scratch := make([]int, n)
+			for r := range next {
+				x[r] = fn(rand.New(rand.NewSource(seeds[r])), scratch)
+			}
+		}()
+	}
+	for r := 0; r < reps; r++ {
+		next <- r
+	}
+	close(next)
+	wg.Wait()
+
+	return x
+}
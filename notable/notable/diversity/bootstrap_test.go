@@ -0,0 +1,62 @@
+package diversity
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// The results do not depend on the number of workers, although each
+// worker reuses its resampling buffer.
+func TestBootstrapWorkers(t *testing.T) {
+
+	x := []int{0, 0, 0, 1, 1, 2, 2, 2, 2, 3}
+	y := []int{0, 1, 1, 1, 2, 2, 3, 3, 3, 3}
+
+	var first [2]Estimate
+	for _, workers := range []int{1, 2, 7} {
+		b := Bootstrap{Reps: 200, Seed: 3, Workers: workers}
+		est := [2]Estimate{
+			b.One(x, 4, Entropy),
+			b.Paired(x, y, 4, func(cx, cy []float64) float64 {
+				return Herfindahl(cy) - Herfindahl(cx)
+			}),
+		}
+		if workers == 1 {
+			first = est
+			continue
+		}
+		if est != first {
+			t.Errorf("%d workers gave %v, one worker %v", workers, est, first)
+		}
+	}
+
+	if first[0].Value != Entropy(Tally(x, 4)) {
+		t.Errorf("value %f, want the entropy of the data", first[0].Value)
+	}
+	for _, e := range first {
+		if !(e.Lower <= e.Upper) || math.IsNaN(e.Lower) {
+			t.Errorf("invalid interval %v", e)
+		}
+	}
+}
+
+// Runs with adjacent seeds share no replicates.
+func TestReplicateSeeds(t *testing.T) {
+
+	draw := func(rng *rand.Rand, _ []int) float64 {
+		return rng.Float64()
+	}
+	a := replicate(50, 3, 0, 1, draw)
+	b := replicate(50, 3, 0, 2, draw)
+
+	seen := make(map[float64]bool)
+	for _, v := range a {
+		seen[v] = true
+	}
+	for r, v := range b {
+		if seen[v] {
+			t.Errorf("replicate %d with seed 2 repeats one with seed 1", r)
+		}
+	}
+}
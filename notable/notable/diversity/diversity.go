@@ -0,0 +1,182 @@
+// Package diversity measures how evenly notable people are spread over
+// locations, or any other categories, and how much two distributions
+// over the same categories differ.
+//
+// Each measure takes counts, one per category, and treats them as a
+// distribution by dividing by their total.  Logarithms are natural
+// logarithms, as in the location stats scripts.  Uncertainty in the
+// measures is obtained with a Bootstrap, which resamples people.
+//
+// For example, to compare the concentration of birth and death
+// locations, with a 95% confidence interval for the difference:
+//
+//	births, deaths, k := diversity.Encode(people.BLocLabel.Strings(), people.DLocLabel.Strings())
+//	b := diversity.Bootstrap{Seed: 1}
+//	est := b.Paired(births, deaths, k, func(cb, cd []float64) float64 {
+//		return diversity.Herfindahl(cd) - diversity.Herfindahl(cb)
+//	})
+package diversity
+
+import (
+	"math"
+)
+
+// proportions returns the counts divided by their total.
+func proportions(counts []float64) []float64 {
+
+	tot := 0.0
+	for _, c := range counts {
+		tot += c
+	}
+
+	p := make([]float64, len(counts))
+	for i, c := range counts {
+		p[i] = c / tot
+	}
+
+	return p
+}
+
+// Entropy returns the Shannon entropy of the distribution, -sum p log p.
+func Entropy(counts []float64) float64 {
+
+	e := 0.0
+	for _, p := range proportions(counts) {
+		if p > 0 {
+			e -= p * math.Log(p)
+		}
+	}
+
+	return e
+}
+
+// Richness returns the number of categories with a positive count.
+func Richness(counts []float64) int {
+
+	s := 0
+	for _, c := range counts {
+		if c > 0 {
+			s++
+		}
+	}
+
+	return s
+}
+
+// NormalizedEntropy returns the entropy divided by its largest
+// possible value, the logarithm of the number of categories observed
+// (Pielou's evenness).  It is 1 when the observed categories are
+// equally common, and NaN when fewer than two are observed.
+func NormalizedEntropy(counts []float64) float64 {
+
+	s := Richness(counts)
+	if s < 2 {
+		return math.NaN()
+	}
+
+	return Entropy(counts) / math.Log(float64(s))
+}
+
+// Herfindahl returns the Herfindahl-Hirschman concentration index,
+// sum p^2, the probability that two people drawn at random (with
+// replacement) share a category.  It ranges from 1/k for k equally
+// common categories to 1 when there is only one.
+func Herfindahl(counts []float64) float64 {
+
+	h := 0.0
+	for _, p := range proportions(counts) {
+		h += p * p
+	}
+
+	return h
+}
+
+// GiniSimpson returns the Gini-Simpson index, 1 - Herfindahl, the
+// probability that two people drawn at random are in different
+// categories.
+func GiniSimpson(counts []float64) float64 {
+	return 1 - Herfindahl(counts)
+}
+
+// Hill returns the Hill number, or effective number of categories, of
+// order q: (sum p^q)^(1/(1-q)).  Order 0 gives the richness, order 1
+// (taken as a limit) the exponential of the entropy, and order 2 the
+// inverse of the Herfindahl index.  Higher orders give more weight to
+// the common categories.
+func Hill(counts []float64, q float64) float64 {
+
+	if q == 1 {
+		return math.Exp(Entropy(counts))
+	}
+
+	s := 0.0
+	for _, p := range proportions(counts) {
+		if p > 0 {
+			s += math.Pow(p, q)
+		}
+	}
+
+	return math.Pow(s, 1/(1-q))
+}
+
+// KL returns the Kullback-Leibler divergence of the distribution q
+// from the distribution p, sum p log(p/q).  The counts must be given
+// for the same categories, in the same order.  The divergence is
+// infinite if some category has a positive count in p but not in q;
+// see Smooth for a way to avoid this.
+func KL(p, q []float64) float64 {
+
+	checkLen(p, q)
+	pp, qq := proportions(p), proportions(q)
+
+	d := 0.0
+	for i := range pp {
+		if pp[i] == 0 {
+			continue
+		}
+		if qq[i] == 0 {
+			return math.Inf(1)
+		}
+		d += pp[i] * math.Log(pp[i]/qq[i])
+	}
+
+	return d
+}
+
+// JS returns the Jensen-Shannon divergence between two distributions,
+// the mean divergence of each from their average.  Unlike KL, it is
+// symmetric and always finite, ranging from 0 for identical
+// distributions to log 2 for distributions with no category in
+// common.
+func JS(p, q []float64) float64 {
+
+	checkLen(p, q)
+	pp, qq := proportions(p), proportions(q)
+
+	m := make([]float64, len(pp))
+	for i := range pp {
+		m[i] = (pp[i] + qq[i]) / 2
+	}
+
+	return (KL(pp, m) + KL(qq, m)) / 2
+}
+
+// Smooth returns the counts with alpha added to each, so that every
+// category has a positive count.
+func Smooth(counts []float64, alpha float64) []float64 {
+
+	s := make([]float64, len(counts))
+	for i, c := range counts {
+		s[i] = c + alpha
+	}
+
+	return s
+}
+
+// checkLen panics if the distributions have different numbers of
+// categories.
+func checkLen(p, q []float64) {
+	if len(p) != len(q) {
+		panic("diversity: distributions have different numbers of categories")
+	}
+}
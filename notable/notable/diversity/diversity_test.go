@@ -0,0 +1,107 @@
+package diversity
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// near returns true if x and y are equal to within rounding, or are
+// both NaN or the same infinity.
+func near(x, y float64) bool {
+	return x == y || math.Abs(x-y) < 1e-12 || math.IsNaN(x) && math.IsNaN(y)
+}
+
+func TestMeasures(t *testing.T) {
+
+	nan := math.NaN()
+
+	cases := []struct {
+		counts       []float64
+		entropy      float64
+		normalized   float64
+		herfindahl   float64
+		richness     int
+		hill0, hill2 float64
+	}{
+		// k equally common categories, with or without empty ones
+		{[]float64{5, 5, 5, 5}, math.Log(4), 1, 0.25, 4, 4, 4},
+		{[]float64{2, 0, 2, 0}, math.Log(2), 1, 0.5, 2, 2, 2},
+		{[]float64{7}, 0, nan, 1, 1, 1, 1},
+		{[]float64{3, 1}, -0.75*math.Log(0.75) - 0.25*math.Log(0.25),
+			(-0.75*math.Log(0.75) - 0.25*math.Log(0.25)) / math.Log(2), 0.625, 2, 2, 1.6},
+	}
+
+	for _, c := range cases {
+		got := []float64{Entropy(c.counts), NormalizedEntropy(c.counts), Herfindahl(c.counts),
+			GiniSimpson(c.counts), float64(Richness(c.counts)), Hill(c.counts, 0), Hill(c.counts, 2)}
+		want := []float64{c.entropy, c.normalized, c.herfindahl, 1 - c.herfindahl,
+			float64(c.richness), c.hill0, c.hill2}
+		names := []string{"entropy", "normalized entropy", "Herfindahl", "Gini-Simpson",
+			"richness", "Hill 0", "Hill 2"}
+		for i := range got {
+			if !near(got[i], want[i]) {
+				t.Errorf("%v: %s is %v, want %v", c.counts, names[i], got[i], want[i])
+			}
+		}
+
+		// The Hill numbers of order near 1 approach exp(entropy)
+		if h := Hill(c.counts, 1); !near(h, math.Exp(c.entropy)) {
+			t.Errorf("%v: Hill 1 is %v, want %v", c.counts, h, math.Exp(c.entropy))
+		}
+		if h := Hill(c.counts, 1+1e-7); math.Abs(h-math.Exp(c.entropy)) > 1e-5 {
+			t.Errorf("%v: Hill near 1 is %v, want %v", c.counts, h, math.Exp(c.entropy))
+		}
+	}
+}
+
+func TestDivergences(t *testing.T) {
+
+	cases := []struct {
+		p, q   []float64
+		kl, js float64
+	}{
+		{[]float64{1, 2, 3}, []float64{2, 4, 6}, 0, 0},
+		{[]float64{1, 0}, []float64{0, 1}, math.Inf(1), math.Log(2)},
+		{[]float64{1, 1}, []float64{1, 0}, math.Inf(1), (0.5*math.Log(2.0/3) + 0.5*math.Log(2) + math.Log(4.0/3)) / 2},
+		{[]float64{1, 0}, []float64{1, 1}, math.Log(2), (0.5*math.Log(2.0/3) + 0.5*math.Log(2) + math.Log(4.0/3)) / 2},
+		{[]float64{3, 1}, []float64{1, 3}, 0.5 * math.Log(3), 0.75*math.Log(1.5) + 0.25*math.Log(0.5)},
+	}
+
+	for _, c := range cases {
+		if kl := KL(c.p, c.q); !near(kl, c.kl) {
+			t.Errorf("KL(%v, %v) = %v, want %v", c.p, c.q, kl, c.kl)
+		}
+		js, rev := JS(c.p, c.q), JS(c.q, c.p)
+		if !near(js, c.js) || !near(js, rev) {
+			t.Errorf("JS(%v, %v) = %v and %v reversed, want %v", c.p, c.q, js, rev, c.js)
+		}
+		if js < 0 || js > math.Log(2)+1e-12 {
+			t.Errorf("JS(%v, %v) = %v is out of range", c.p, c.q, js)
+		}
+	}
+
+	// Smoothing makes the divergence finite
+	if kl := KL([]float64{1, 0}, Smooth([]float64{0, 1}, 0.5)); math.IsInf(kl, 1) {
+		t.Errorf("KL is infinite after smoothing")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("no panic for distributions of different lengths")
+		}
+	}()
+	KL([]float64{1, 2}, []float64{1, 2, 3})
+}
+
+func TestEncode(t *testing.T) {
+
+	x, y, k := Encode([]string{"Rome", "Paris", "Rome"}, []string{"Vienna", "Rome"})
+	if !reflect.DeepEqual(x, []int{0, 1, 0}) || !reflect.DeepEqual(y, []int{2, 0}) || k != 3 {
+		t.Errorf("codes %v and %v, and %d categories", x, y, k)
+	}
+
+	if got := Tally(x, k); !reflect.DeepEqual(got, []float64{2, 1, 0}) {
+		t.Errorf("tally %v", got)
+	}
+}
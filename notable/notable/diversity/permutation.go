@@ -0,0 +1,155 @@
+package diversity
+
+import (
+	"math"
+	"math/rand"
+	"runtime"
+)
+
+// A PermutationTest tests whether a measure differs between two
+// distributions over the same people, such as the birth and death
+// locations.  Under the null hypothesis that the two labels of each
+// person are exchangeable, swapping them does not change the
+// distribution of the difference in the measure.  Each replicate
+// swaps the labels of each person with probability 1/2 and computes
+// the difference again.  Since each person keeps both labels, the two
+// samples always have the same size.
+//
+// Like a Bootstrap, the replicates are computed in parallel, each
+// with a random number generator seeded from Seed and its position.
+// Replicates in which the difference is not finite, such as when the
+// measure is NaN for a distribution with one category, are left out.
+type PermutationTest struct {
+
+	// The number of replicates.  Defaults to 1000.
+	Reps int
+
+	// Seeds the swapping
+	Seed int64
+
+	// The number of goroutines computing replicates.  Defaults to
+	// GOMAXPROCS.
+	Workers int
+}
+
+// A TestResult holds the outcome of a permutation test.
+type TestResult struct {
+
+	// The observed difference, the measure of y minus the measure of
+	// x
+	Diff float64
+
+	// The two-sided p-value: the proportion of replicates, counting
+	// the observed data as one, in which the difference is at least
+	// as large in absolute value as Diff
+	P float64
+
+	// The mean and standard deviation of the difference over the
+	// replicates
+	NullMean, NullSD float64
+
+	// The standardized effect size, (Diff - NullMean) / NullSD.  It
+	// is NaN if NullSD is zero, so that swapping the labels never
+	// changes the difference, as when every person has the same two
+	// labels.
+	Effect float64
+
+	// The relative difference, Diff divided by the measure of x
+	Relative float64
+
+	// The number of replicates used
+	Reps int
+
+	// The number of replicates left out because the difference was
+	// not finite
+	Skipped int
+}
+
+// setDefaults fills in the fields that are not set.
+func (t *PermutationTest) setDefaults() {
+
+	if t.Reps <= 0 {
+		t.Reps = 1000
+	}
+
+	if t.Workers <= 0 {
+		t.Workers = runtime.GOMAXPROCS(0)
+	}
+}
+
+// Paired tests whether stat differs between the distributions of the
+// codes in x and y, which give two labels for each person as codes
+// from 0 to k-1.
+func (t PermutationTest) Paired(x, y []int, k int, stat func(counts []float64) float64) TestResult {
+
+	if len(x) != len(y) {
+		panic("diversity: paired codes have different lengths")
+	}
+	t.setDefaults()
+
+	sx := stat(Tally(x, k))
+	diff := stat(Tally(y, k)) - sx
+
+	// People with the same two labels are not affected by swapping
+	var swap []int
+	cx, cy := make([]float64, k), make([]float64, k)
+	for i := range x {
+		if x[i] == y[i] {
+			cx[x[i]]++
+			cy[y[i]]++
+		} else {
+			swap = append(swap, i)
+		}
+	}
+
+	reps := replicate(t.Reps, t.Workers, 0, t.Seed, func(rng *rand.Rand, _ []int) float64 {
+		px := append([]float64(nil), cx...)
+		py := append([]float64(nil), cy...)
+		for _, i := range swap {
+			a, b := x[i], y[i]
+			if rng.Intn(2) == 1 {
+				a, b = b, a
+			}
+			px[a]++
+			py[b]++
+		}
+		return stat(py) - stat(px)
+	})
+
+	// Leave out the replicates with no finite difference
+	var used []float64
+	for _, d := range reps {
+		if !math.IsNaN(d) && !math.IsInf(d, 0) {
+			used = append(used, d)
+		}
+	}
+	n := len(used)
+	res := TestResult{Diff: diff, Reps: n, Skipped: t.Reps - n, Relative: diff / sx}
+
+	// Differences within rounding error of the observed one are
+	// counted as being as large
+	tol := 1e-12 * math.Max(1, math.Abs(diff))
+	extreme := 1
+	var sum, ss float64
+	for _, d := range used {
+		if math.Abs(d) >= math.Abs(diff)-tol {
+			extreme++
+		}
+		sum += d
+	}
+	res.P = float64(extreme) / float64(n+1)
+	res.NullMean = sum / float64(n)
+	for _, d := range used {
+		ss += (d - res.NullMean) * (d - res.NullMean)
+	}
+	if n > 1 {
+		res.NullSD = math.Sqrt(ss / float64(n-1))
+	}
+
+	res.Effect = math.NaN()
+	if res.NullSD > 0 {
+		res.Effect = (diff - res.NullMean) / res.NullSD
+	}
+
+	return res
+}
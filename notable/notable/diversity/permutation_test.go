@@ -0,0 +1,43 @@
+package diversity
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPermutationPaired(t *testing.T) {
+
+	cases := []struct {
+		name    string
+		x, y    []int
+		k       int
+		stat    func([]float64) float64
+		nanSD   bool
+		skipped bool
+	}{
+		{"different labels", []int{0, 0, 0, 1, 2, 2}, []int{1, 1, 2, 2, 2, 2}, 3, Entropy, false, false},
+		{"same labels", []int{0, 1, 2}, []int{0, 1, 2}, 3, Entropy, true, false},
+		{"one category", []int{0, 0}, []int{1, 1}, 2, NormalizedEntropy, true, true},
+	}
+
+	for _, c := range cases {
+		test := PermutationTest{Reps: 500, Seed: 1, Workers: 3}
+		res := test.Paired(c.x, c.y, c.k, c.stat)
+
+		if res.Reps+res.Skipped != 500 {
+			t.Errorf("%s: %d used and %d skipped replicates", c.name, res.Reps, res.Skipped)
+		}
+		if (res.Skipped > 0) != c.skipped {
+			t.Errorf("%s: %d replicates skipped", c.name, res.Skipped)
+		}
+		if res.P <= 0 || res.P > 1 {
+			t.Errorf("%s: p-value %f", c.name, res.P)
+		}
+		if math.IsNaN(res.Effect) != c.nanSD {
+			t.Errorf("%s: effect %f with null SD %f", c.name, res.Effect, res.NullSD)
+		}
+		if c.nanSD && res.NullSD != 0 {
+			t.Errorf("%s: null SD %f, want 0", c.name, res.NullSD)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package notable
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Grouped summaries
+//
+// A GroupBy divides the records into groups using a Key, and keeps a
+// running summary of each group for every Aggregator.  Records are
+// added one at a time, so the data never need to be held in memory,
+// and the summaries are returned as a Table with one row per group.
+// For example, the mean birth year and the number of people by birth
+// place and gender are obtained with:
+//
+//	g := notable.NewGroupBy(notable.ByFields(notable.FieldBLocLabel, notable.FieldGender),
+//		notable.Mean(notable.FieldBYear), notable.Count())
+//	if err := g.AddReader(rdr); err != nil {
+//		...
+//	}
+//	tab := g.Table()
+
+// A Key assigns each record to a group.
+type Key struct {
+
+	// The names of the key columns in the result
+	Names []string
+
+	// Func returns the values of the key columns for a person, one
+	// for each name
+	Func func(p *Person) []string
+}
+
+// ByFields returns a key that groups the records by the values of the
+// given fields.  Records with a missing value form their own group,
+// with the value "NA".
+func ByFields(fields ...Field) Key {
+
+	var names []string
+	for _, f := range fields {
+		names = append(names, f.String())
+	}
+
+	return Key{
+		Names: names,
+		Func: func(p *Person) []string {
+			vals := make([]string, len(fields))
+			for j, f := range fields {
+				vals[j] = naString(p, f)
+			}
+			return vals
+		},
+	}
+}
+
+// An Aggregator describes a summary that is computed for each group.
+type Aggregator interface {
+
+	// Names returns the names of the columns holding the summary in
+	// the result
+	Names() []string
+
+	// New returns an accumulator for one group, holding no records
+	New() Accumulator
+}
+
+// An Accumulator holds the running summary of one group.
+type Accumulator interface {
+
+	// Add includes a record in the summary
+	Add(p *Person)
+
+	// Merge includes the records summarized by another accumulator,
+	// which must come from the same Aggregator
+	Merge(other Accumulator)
+
+	// Values returns the summary, one value for each of the names
+	// of the Aggregator
+	Values() []float64
+}
+
+// A GroupBy computes summaries of the records in each group.
+type GroupBy struct {
+
+	// Assigns records to groups
+	key Key
+
+	// The summaries computed for each group
+	aggs []Aggregator
+
+	// The groups seen so far, by their joined key values
+	groups map[string]*group
+}
+
+// group is the state of one group.
+type group struct {
+
+	// The values of the key columns
+	key []string
+
+	// One accumulator per aggregator
+	accs []Accumulator
+}
+
+// keySep separates the values of the key columns when they are joined
+// to index the groups.
+const keySep = "\x1f"
+
+// NewGroupBy returns a GroupBy that groups records using key and
+// summarizes each group with the aggregators.
+func NewGroupBy(key Key, aggs ...Aggregator) *GroupBy {
+	return &GroupBy{key: key, aggs: aggs, groups: make(map[string]*group)}
+}
+
+// Add includes one record in the summaries.
+func (g *GroupBy) Add(p *Person) {
+
+	vals := g.key.Func(p)
+	if len(vals) != len(g.key.Names) {
+		panic(fmt.Sprintf("notable: key has %d names but gave %d values", len(g.key.Names), len(vals)))
+	}
+
+	g.find(vals).add(p)
+}
+
+// AddTo includes one record in the summaries of the group with the
+// given key values, in place of the group given by the key.  This
+// allows a record to be counted in several groups, such as
+// overlapping time windows.
+func (g *GroupBy) AddTo(vals []string, p *Person) {
+
+	if len(vals) != len(g.key.Names) {
+		panic(fmt.Sprintf("notable: key has %d names but was given %d values", len(g.key.Names), len(vals)))
+	}
+
+	g.find(vals).add(p)
+}
+
+// find returns the group with the given key values, creating it if
+// needed.
+func (g *GroupBy) find(vals []string) *group {
+
+	k := strings.Join(vals, keySep)
+	gr, ok := g.groups[k]
+	if !ok {
+		gr = &group{key: vals}
+		for _, a := range g.aggs {
+			gr.accs = append(gr.accs, a.New())
+		}
+		g.groups[k] = gr
+	}
+
+	return gr
+}
+
+// add includes a record in every summary of the group.
+func (gr *group) add(p *Person) {
+	for _, acc := range gr.accs {
+		acc.Add(p)
+	}
+}
+
+// AddPeople includes every record of people in the summaries.
+func (g *GroupBy) AddPeople(people *People) {
+	for i := 0; i < people.Len(); i++ {
+		person := people.Row(i)
+		g.Add(&person)
+	}
+}
+
+// AddCodes includes every record of people in the summaries, grouping
+// them by the dictionary codes of col, which must be a column of
+// people, such as &people.BLocLabel.  The key must have a single
+// column, which is given the value in col.Dict of each code in place
+// of the value from the key.  Each group is looked up once per code,
+// rather than once per record.
+func (g *GroupBy) AddCodes(col *DictColumn, people *People) {
+
+	if len(g.key.Names) != 1 {
+		panic(fmt.Sprintf("notable: key has %d names but codes give 1 value", len(g.key.Names)))
+	}
+	if len(col.Codes) != people.Len() {
+		panic(fmt.Sprintf("notable: column has %d codes but there are %d people", len(col.Codes), people.Len()))
+	}
+
+	groups := make([]*group, col.NumCodes())
+	for i, code := range col.Codes {
+		gr := groups[code]
+		if gr == nil {
+			gr = g.find([]string{col.Dict[code]})
+			groups[code] = gr
+		}
+		person := people.Row(i)
+		gr.add(&person)
+	}
+}
+
+// AddReader includes every remaining record of r in the summaries.  It
+// returns any error from reading the records.
+func (g *GroupBy) AddReader(r *Reader) error {
+
+	for r.Next() {
+		person := r.Person()
+		g.Add(&person)
+	}
+
+	return r.Err()
+}
+
+// Merge includes the summaries of other, which must have been created
+// with the same key and aggregators, so that the records can be
+// summarized in parts, for example by several goroutines.
+func (g *GroupBy) Merge(other *GroupBy) {
+
+	for _, ogr := range other.groups {
+		gr := g.find(ogr.key)
+		for j, acc := range gr.accs {
+			acc.Merge(ogr.accs[j])
+		}
+	}
+}
+
+// NumGroups returns the number of groups seen so far.
+func (g *GroupBy) NumGroups() int {
+	return len(g.groups)
+}
+
+// Table returns the summaries, with one row per group, sorted by the
+// key values.
+func (g *GroupBy) Table() *Table {
+
+	t := &Table{KeyNames: g.key.Names}
+	for _, a := range g.aggs {
+		t.ValueNames = append(t.ValueNames, a.Names()...)
+	}
+
+	for _, gr := range g.groups {
+		var vals []float64
+		for _, acc := range gr.accs {
+			vals = append(vals, acc.Values()...)
+		}
+		t.Keys = append(t.Keys, gr.key)
+		t.Values = append(t.Values, vals)
+	}
+	t.Sort()
+
+	return t
+}
@@ -0,0 +1,69 @@
+package notable
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestGroupBy(t *testing.T) {
+
+	people := samplePeople()
+	g := NewGroupBy(ByFields(FieldDLocLabel), Mean(FieldBYear), Count())
+	g.AddPeople(&people)
+
+	tab := g.Table()
+	if want := []string{"mean_BYear", "count"}; !reflect.DeepEqual(tab.ValueNames, want) {
+		t.Fatalf("value names %q, want %q", tab.ValueNames, want)
+	}
+
+	// Hypatia has no birth year, so the mean for Alexandria is NaN
+	want := map[string][2]float64{
+		"Alexandria": {math.NaN(), 1},
+		"Bryn Mawr":  {1882, 1},
+		"Gottingen":  {1777, 1},
+		"London":     {1815, 1},
+	}
+	if len(tab.Keys) != len(want) {
+		t.Fatalf("got %d groups, want %d", len(tab.Keys), len(want))
+	}
+	for i, key := range tab.Keys {
+		w, ok := want[key[0]]
+		got := tab.Values[i]
+		if !ok || got[1] != w[1] || !(got[0] == w[0] || math.IsNaN(got[0]) && math.IsNaN(w[0])) {
+			t.Errorf("%s: got %v, want %v", key[0], got, w)
+		}
+	}
+}
+
+// Grouping by dictionary codes gives the same table as grouping by the
+// values, also when the groups are added in parts with different
+// dictionaries.
+func TestGroupByAddCodes(t *testing.T) {
+
+	people := samplePeople()
+	people.Append(people.Row(1))
+	people.Append(people.Row(3))
+
+	byValue := NewGroupBy(ByFields(FieldBLocLabel), Mean(FieldDYear), Count())
+	byValue.AddPeople(&people)
+
+	byCode := NewGroupBy(ByFields(FieldBLocLabel), Mean(FieldDYear), Count())
+	for _, part := range [][2]int{{0, 2}, {2, 6}} {
+		p := people.Slice(part[0], part[1])
+		p.BLocLabel = NewDictColumn(p.BLocLabel.Strings())
+		byCode.AddCodes(&p.BLocLabel, &p)
+	}
+
+	want, got := byValue.Table(), byCode.Table()
+	if !reflect.DeepEqual(got.Keys, want.Keys) {
+		t.Fatalf("keys %q, want %q", got.Keys, want.Keys)
+	}
+	for i := range want.Values {
+		for j, w := range want.Values[i] {
+			if g := got.Values[i][j]; g != w && !(math.IsNaN(g) && math.IsNaN(w)) {
+				t.Errorf("%s: %s is %v, want %v", want.Keys[i][0], want.ValueNames[j], g, w)
+			}
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package notable
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// File headers
+//
+// Every compressed file written by CSVWriter, JSONEncoder or
+// GobEncoder holds a header describing its contents.  The header is
+// stored in a part of the compressed stream that decompressors skip,
+// so that the files can still be read by other tools, such as zcat,
+// pandas or R:
+//
+//	gzip          a subfield, with ID "NT", of the extra field of the gzip header
+//	zstd, lz4     a skippable frame before the compressed data
+//	snappy        a skippable chunk after the stream identifier
+//
+// Uncompressed files have nowhere to put a header, and have none.
+// The header has a fixed size, so that the number of records, which
+// is only known when the file is closed, can be filled in then.  Its
+// layout, with integers in little-endian order, is:
+//
+//	magic                  8 bytes
+//	format                 2 bytes
+//	schema version         2 bytes
+//	flags                  4 bytes
+//	number of records      8 bytes, -1 if not known
+//	creation time          8 bytes, nanoseconds since 1970
+//	source checksum        32 bytes, SHA-256, zero if no source
+//
+// The number of records is not known for files written through
+// GetCSVWriter, GetJSONEncoder or GetGobEncoder, which return the
+// underlying encoder, and the format is only known for GetCSVWriter.
+//
+// Files without a header, such as those written by older versions of
+// this package, can still be read.  Column files describe themselves in their footer instead (see
+// ColumnWriter).
+
+// hdrMagic starts every file that has a header.
+var hdrMagic = []byte("NTBLHDR1")
+
+// hdrSize is the size of a header in bytes.
+const hdrSize = 64
+
+// hdrIncomplete is the flag set in the header of a file whose writer
+// stopped on an error.
+const hdrIncomplete = 1
+
+// Where the header is kept in the compressed stream
+var (
+	// The ID of the subfield of a gzip extra field
+	hdrGzipID = [2]byte{'N', 'T'}
+
+	// The magic number of a zstd or lz4 skippable frame.  The low
+	// four bits may be anything.
+	hdrSkipMagic uint32 = 0x184d2a5e
+
+	// The type of a snappy skippable chunk
+	hdrSnappyChunk byte = 0x9e
+)
+
+// SchemaVersion is the version of the fields of Person.  It is
+// increased when fields are added, removed or change meaning, so that
+// files written with a different set of fields are detected.
+const SchemaVersion = 1
+
+// A Header describes the contents of a file.
+type Header struct {
+
+	// The format of the records
+	Format Format
+
+	// The version of Person used to write the file (see
+	// SchemaVersion)
+	SchemaVersion int
+
+	// The number of records, or -1 if not known.  The header row of
+	// a row-oriented file is not counted.
+	Rows int64
+
+	// When the file was created
+	Created time.Time
+
+	// The SHA-256 checksum of the file the data were converted from,
+	// or zero if not known
+	Source [sha256.Size]byte
+
+	// True if the writer stopped on an error, so that the file holds
+	// only some of the records
+	Incomplete bool
+}
+
+// HasSource returns true if the checksum of the source file is known.
+func (h *Header) HasSource() bool {
+	return h.Source != [sha256.Size]byte{}
+}
+
+// String returns a one-line description of the header.
+func (h *Header) String() string {
+
+	rows := "unknown"
+	if h.Rows >= 0 {
+		rows = fmt.Sprintf("%d", h.Rows)
+	}
+
+	src := "unknown"
+	if h.HasSource() {
+		src = hex.EncodeToString(h.Source[:])
+	}
+
+	s := fmt.Sprintf("format %s, schema version %d, %s records, created %s, source sha256 %s",
+		h.Format, h.SchemaVersion, rows, h.Created.UTC().Format(time.RFC3339), src)
+	if h.Incomplete {
+		s += ", incomplete"
+	}
+
+	return s
+}
+
+// marshal encodes the header.
+func (h *Header) marshal() []byte {
+
+	b := make([]byte, hdrSize)
+	copy(b, hdrMagic)
+	binary.LittleEndian.PutUint16(b[8:], uint16(h.Format))
+	binary.LittleEndian.PutUint16(b[10:], uint16(h.SchemaVersion))
+	if h.Incomplete {
+		binary.LittleEndian.PutUint32(b[12:], hdrIncomplete)
+	}
+	binary.LittleEndian.PutUint64(b[16:], uint64(h.Rows))
+	binary.LittleEndian.PutUint64(b[24:], uint64(h.Created.UnixNano()))
+	copy(b[32:], h.Source[:])
+
+	return b
+}
+
+// unmarshalHeader decodes a header, checking that it can be read by
+// this version of the package.
+func unmarshalHeader(b []byte) (*Header, error) {
+
+	if len(b) < hdrSize || !bytes.Equal(b[0:len(hdrMagic)], hdrMagic) {
+		return nil, fmt.Errorf("invalid file header")
+	}
+
+	h := &Header{
+		Format:        Format(binary.LittleEndian.Uint16(b[8:])),
+		SchemaVersion: int(binary.LittleEndian.Uint16(b[10:])),
+		Rows:          int64(binary.LittleEndian.Uint64(b[16:])),
+		Created:       time.Unix(0, int64(binary.LittleEndian.Uint64(b[24:]))),
+		Incomplete:    binary.LittleEndian.Uint32(b[12:])&hdrIncomplete != 0,
+	}
+	copy(h.Source[:], b[32:hdrSize])
+
+	if h.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("file has schema version %d, but this program only reads versions up to %d",
+			h.SchemaVersion, SchemaVersion)
+	}
+
+	return h, nil
+}
+
+// newHeaderWriter returns a compressor that writes to w, first
+// writing the parts of the stream that hold the header h.  It also
+// returns the position of the header in the stream, so that it can be
+// written again when the file is closed, or -1 if the codec has no
+// place for a header.
+func newHeaderWriter(w io.Writer, codec Codec, h *Header) (io.WriteCloser, int64, error) {
+
+	var prefix []byte
+	var at int64
+	switch codec {
+	case Gzip:
+		// The gzip header is written with the first data: ten fixed
+		// bytes, the length of the extra field, then the subfield's
+		// ID and length
+		gz := gzip.NewWriter(w)
+		gz.Header.Extra = append([]byte{hdrGzipID[0], hdrGzipID[1], hdrSize, 0}, h.marshal()...)
+		return gz, 16, nil
+	case Zstd, LZ4:
+		prefix = make([]byte, 8)
+		binary.LittleEndian.PutUint32(prefix, hdrSkipMagic)
+		binary.LittleEndian.PutUint32(prefix[4:], hdrSize)
+		at = 8
+	case Snappy:
+		// A snappy stream must start with its identifier, which the
+		// compressor writes again
+		prefix = append([]byte(nil), codecInfo[Snappy].magic...)
+		prefix = append(prefix, hdrSnappyChunk, hdrSize, 0, 0)
+		at = int64(len(prefix))
+	default:
+		zw, err := codec.NewWriter(w)
+		return zw, -1, err
+	}
+
+	if _, err := w.Write(append(prefix, h.marshal()...)); err != nil {
+		return nil, 0, err
+	}
+	zw, err := codec.NewWriter(w)
+
+	return zw, at, err
+}
+
+// readHeader reads the header from the start of br, if there is one
+// that is stored outside of the compressed data, leaving br at the
+// start of the compressed data.  It returns nil if there is no such
+// header.
+func readHeader(br *bufio.Reader) (*Header, error) {
+
+	// A skippable frame, used by zstd and lz4
+	if head, _ := br.Peek(8 + hdrSize); len(head) == 8+hdrSize &&
+		binary.LittleEndian.Uint32(head)&^0xf == hdrSkipMagic&^0xf &&
+		binary.LittleEndian.Uint32(head[4:]) == hdrSize &&
+		bytes.HasPrefix(head[8:], hdrMagic) {
+		h, err := unmarshalHeader(head[8:])
+		if err != nil {
+			return nil, err
+		}
+		_, err = br.Discard(8 + hdrSize)
+		return h, err
+	}
+
+	// A skippable chunk after a snappy stream identifier.  The
+	// compressed stream repeats the identifier, so the chunk is
+	// removed with the first one.
+	magic := codecInfo[Snappy].magic
+	n := len(magic) + 4
+	if head, _ := br.Peek(n + hdrSize); len(head) == n+hdrSize &&
+		bytes.HasPrefix(head, magic) &&
+		bytes.Equal(head[len(magic):n], []byte{hdrSnappyChunk, hdrSize, 0, 0}) &&
+		bytes.HasPrefix(head[n:], hdrMagic) {
+		h, err := unmarshalHeader(head[n:])
+		if err != nil {
+			return nil, err
+		}
+		_, err = br.Discard(n + hdrSize)
+		return h, err
+	}
+
+	return nil, nil
+}
+
+// gzipHeader returns the header held in the extra field of a gzip
+// stream, or nil if there is none.
+func gzipHeader(gz *gzip.Reader) (*Header, error) {
+
+	// The extra field is a list of subfields, each with a two byte
+	// ID and a two byte length
+	extra := gz.Header.Extra
+	for len(extra) >= 4 {
+		n := int(binary.LittleEndian.Uint16(extra[2:]))
+		if len(extra) < 4+n {
+			break
+		}
+		if extra[0] == hdrGzipID[0] && extra[1] == hdrGzipID[1] && n == hdrSize {
+			return unmarshalHeader(extra[4 : 4+n])
+		}
+		extra = extra[4+n:]
+	}
+
+	return nil, nil
+}
+
+// ReadHeader returns the header of the named file.  For a column file,
+// the header is made from the file's footer.  It returns nil and no
+// error if the file has no header.
+func ReadHeader(fname string) (*Header, error) {
+
+	if IsColumnFile(fname) {
+		cf, err := OpenColumnFile(fname)
+		if err != nil {
+			return nil, err
+		}
+		defer cf.Close()
+		return cf.Header(), nil
+	}
+
+	fr, err := openFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer fr.close()
+
+	return fr.hdr, nil
+}
+
+// ExpectFormat returns an error if the header of the named file shows
+// that it does not hold records in the given format.  Files without a
+// header are not checked.
+func ExpectFormat(fname string, format Format) error {
+
+	h, err := ReadHeader(fname)
+	if err != nil {
+		return err
+	}
+
+	return h.expect(fname, format)
+}
+
+// expect returns an error describing the mismatch if the header is not
+// nil and gives a known format other than format.
+func (h *Header) expect(fname string, format Format) error {
+
+	if h == nil || h.Format == UnknownFormat || h.Format == format {
+		return nil
+	}
+
+	return fmt.Errorf("notable: %s holds %s (%s), not %s (%s)",
+		fname, h.Format.describe(), h.Format, format.describe(), format)
+}
+
+// complete returns an error if the header is not nil and shows that
+// the file was not completely written.
+func (h *Header) complete(fname string) error {
+
+	if h != nil && h.Incomplete {
+		return fmt.Errorf("notable: %s is incomplete: it was not completely written", fname)
+	}
+
+	return nil
+}
+
+// describe returns a description of the contents of a file in the
+// format.
+func (f Format) describe() string {
+	switch f {
+	case CSVRows:
+		return "rows of strings in CSV format"
+	case JSONRows:
+		return "json arrays of strings"
+	case JSONStructs:
+		return "json-encoded Person values"
+	case GobRows:
+		return "gob-encoded rows of strings"
+	case GobStructs:
+		return "gob-encoded Person values"
+	case GobColumns:
+		return "a gob-encoded People value"
+	case ColumnGroups:
+		return "People data in row groups"
+	default:
+		return "data in an unknown format"
+	}
+}
+
+// Checksum returns the SHA-256 checksum of the named file.
+func Checksum(fname string) ([sha256.Size]byte, error) {
+
+	var sum [sha256.Size]byte
+
+	fid, err := os.Open(fname)
+	if err != nil {
+		return sum, err
+	}
+	defer fid.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, fid); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+
+	return sum, nil
+}
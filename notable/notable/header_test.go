@@ -0,0 +1,200 @@
+package notable
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The rows written to each test file
+var headerRows = [][]string{
+	{"PrsLabel", "BYear"},
+	{"Ada", "1815"},
+	{"Carl", "1777"},
+}
+
+// writeCSV writes headerRows to the named file with a CSVWriter, and
+// returns the uncompressed contents.
+func writeCSV(t *testing.T, fname string) []byte {
+
+	t.Helper()
+
+	w, err := NewCSVWriter(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range headerRows {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return []byte("PrsLabel,BYear\nAda,1815\nCarl,1777\n")
+}
+
+func TestHeaderRoundTrip(t *testing.T) {
+
+	for _, ext := range []string{".csv", ".csv.gz", ".csv.zst", ".csv.sz", ".csv.lz4"} {
+		fname := filepath.Join(t.TempDir(), "test"+ext)
+		want := writeCSV(t, fname)
+
+		h, err := ReadHeader(fname)
+		if err != nil {
+			t.Errorf("%s: %v", ext, err)
+			continue
+		}
+		if ext == ".csv" {
+			if h != nil {
+				t.Errorf("%s: uncompressed file has a header", ext)
+			}
+		} else if h == nil {
+			t.Errorf("%s: no header", ext)
+		} else if h.Format != CSVRows || h.Rows != 2 || h.SchemaVersion != SchemaVersion {
+			t.Errorf("%s: got header %v", ext, h)
+		}
+
+		// Other tools read the data without knowing of the header
+		fid, err := os.Open(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := CodecFromName(fname).NewReader(fid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(zr)
+		if err != nil {
+			t.Errorf("%s: %v", ext, err)
+		} else if !bytes.Equal(got, want) {
+			t.Errorf("%s: read %q, want %q", ext, got, want)
+		}
+		zr.Close()
+		fid.Close()
+
+		// As does this package
+		fr, err := openFile(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err = io.ReadAll(fr.zr)
+		if err != nil {
+			t.Errorf("%s: %v", ext, err)
+		} else if !bytes.Equal(got, want) {
+			t.Errorf("%s: openFile read %q, want %q", ext, got, want)
+		}
+		fr.close()
+	}
+}
+
+// A gzip file written by this package is read by the standard library,
+// with the header in the extra field.
+func TestHeaderGzipExtra(t *testing.T) {
+
+	fname := filepath.Join(t.TempDir(), "test.csv.gz")
+	writeCSV(t, fname)
+
+	fid, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fid.Close()
+	gz, err := gzip.NewReader(fid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := gzipHeader(gz)
+	if err != nil || h == nil || h.Rows != 2 {
+		t.Errorf("got header %v, %v", h, err)
+	}
+}
+
+// The encoders fill in the format and the number of records.
+func TestHeaderEncoders(t *testing.T) {
+
+	people := samplePeople()
+	dir := t.TempDir()
+
+	cases := []struct {
+		name   string
+		text   bool
+		values []interface{}
+		format Format
+		rows   int64
+	}{
+		{"rows.json.gz", true, []interface{}{[]string{"a"}, []string{"b"}}, JSONRows, 1},
+		{"structs.gob.zst", false, []interface{}{people.Row(0), people.Row(1), people.Row(2)}, GobStructs, 3},
+		{"columns.gob.lz4", false, []interface{}{people}, GobColumns, int64(people.Len())},
+	}
+
+	for _, c := range cases {
+		fname := filepath.Join(dir, c.name)
+		var err error
+		if c.text {
+			var e *JSONEncoder
+			if e, err = NewJSONEncoder(fname); err == nil {
+				for _, v := range c.values {
+					e.Encode(v)
+				}
+				err = e.Close()
+			}
+		} else {
+			var e *GobEncoder
+			if e, err = NewGobEncoder(fname); err == nil {
+				for _, v := range c.values {
+					e.Encode(v)
+				}
+				err = e.Close()
+			}
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h, err := ReadHeader(fname)
+		if err != nil || h == nil {
+			t.Errorf("%s: got header %v, %v", c.name, h, err)
+			continue
+		}
+		if h.Format != c.format || h.Rows != c.rows {
+			t.Errorf("%s: got format %s with %d records, want %s with %d",
+				c.name, h.Format, h.Rows, c.format, c.rows)
+		}
+	}
+}
+
+// A file whose writer stopped on an error is marked, and not read.
+func TestHeaderIncomplete(t *testing.T) {
+
+	people := samplePeople()
+	for _, ext := range []string{".gob.gz", ".gob.zst", ".gob.sz", ".gob.lz4"} {
+		fname := filepath.Join(t.TempDir(), "test"+ext)
+		e, err := NewGobEncoder(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Encode(people.Row(0))
+		if err := e.fw.close(errors.New("stopped")); err == nil {
+			t.Errorf("%s: close lost the error", ext)
+		}
+
+		h, err := ReadHeader(fname)
+		if err != nil || h == nil || !h.Incomplete || h.Rows != 1 {
+			t.Errorf("%s: got header %v, %v", ext, h, err)
+		}
+		if r, err := NewReader(fname); err == nil {
+			r.Close()
+			t.Errorf("%s: incomplete file was read", ext)
+		}
+		if d, err := NewGobDecoder(fname); err == nil {
+			d.Close()
+			t.Errorf("%s: incomplete file was decoded", ext)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+// Package fixture holds the data used by the tests of the packages
+// that store People in other formats (parquetio, arrowio and sqlio).
+package fixture
+
+import "github.com/kshedden/godata_workshop/notable/notable"
+
+// People returns a few people, including missing values and empty
+// labels.
+func People() *notable.People {
+
+	persons := []notable.Person{
+		{PrsLabel: "Ada", BYear: 1815, BLocLabel: "London", BLocLat: 51.5, BLocLong: -0.1,
+			DYear: 1852, DLocLabel: "London", DLocLat: 51.5, DLocLong: -0.1, Gender: "female"},
+		{PrsLabel: "Emmy", BYear: 1882, BLocLabel: "Erlangen", BLocLat: 49.6, BLocLong: 11.0,
+			DLocLabel: "Bryn Mawr", DLocLat: 40.0, DLocLong: -75.3, Gender: "female"},
+		{PrsLabel: "Imhotep", BYear: -2650, BLocLabel: "Memphis"},
+	}
+	persons[1].SetNA(notable.FieldDYear)
+	for _, f := range []notable.Field{notable.FieldDYear, notable.FieldBLocLat, notable.FieldBLocLong,
+		notable.FieldDLocLat, notable.FieldDLocLong} {
+		persons[2].SetNA(f)
+	}
+
+	people := new(notable.People)
+	for _, p := range persons {
+		people.Append(p)
+	}
+
+	return people
+}
@@ -0,0 +1,242 @@
+package notable
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+)
+
+// A LocationIndex maps each location label to the rows of a dataset
+// in which that location is the place of birth or death.  Rows are
+// numbered from zero in the order that NewReader returns the records
+// of the dataset, so that an index built from one file can be used
+// with any of the other files produced from the same data.
+//
+// The index gives the people at a location, and so the number of
+// people at every location, without reading the dataset.  Statistics
+// of other fields, such as the mean year of birth at each location,
+// still need those fields to be read, as location_stats.go does.
+//
+// An index built from a file records the file's checksum, which Save
+// stores in the source field of the index file's header, so that an
+// index can be checked against the file it was built from (see
+// Matches).
+type LocationIndex struct {
+
+	// The number of rows indexed
+	rows int
+
+	// The checksum of the indexed file, or zero if the index was not
+	// built from a file
+	source [sha256.Size]byte
+
+	// The rows of the people born at each location, in increasing
+	// order
+	birth map[string][]int
+
+	// The rows of the people who died at each location, in
+	// increasing order
+	death map[string][]int
+}
+
+// locIndexVersion is the version of the saved index layout.
+const locIndexVersion = 1
+
+// locIndexFile is the form in which a LocationIndex is saved.
+type locIndexFile struct {
+	Version int
+	Rows    int
+	Birth   map[string][]int
+	Death   map[string][]int
+}
+
+// NewLocationIndex returns an index of the given people.
+func NewLocationIndex(people *People) *LocationIndex {
+
+	ix := &LocationIndex{
+		birth: make(map[string][]int),
+		death: make(map[string][]int),
+	}
+	ix.Add(people)
+
+	return ix
+}
+
+// Add indexes the given people, numbering their rows after the rows
+// already in the index.
+func (ix *LocationIndex) Add(people *People) {
+
+	addRows(ix.birth, &people.BLocLabel, ix.rows)
+	addRows(ix.death, &people.DLocLabel, ix.rows)
+	ix.rows += people.Len()
+}
+
+// addRows adds the rows of a location column to m.  The rows are
+// first grouped by dictionary code, so that each label is looked up
+// in m only once.
+func addRows(m map[string][]int, col *DictColumn, offset int) {
+
+	rows := make([][]int, col.NumCodes())
+	for i, code := range col.Codes {
+		rows[code] = append(rows[code], offset+i)
+	}
+
+	for code, loc := range col.Dict {
+		if len(rows[code]) > 0 {
+			m[loc] = append(m[loc], rows[code]...)
+		}
+	}
+}
+
+// BuildLocationIndex returns an index of the named dataset, which may
+// be in any of the formats read by NewReader.  Only the location
+// columns of a column file are read.
+func BuildLocationIndex(fname string) (*LocationIndex, error) {
+
+	ix := NewLocationIndex(&People{})
+
+	sum, err := Checksum(fname)
+	if err != nil {
+		return nil, err
+	}
+	ix.source = sum
+
+	if IsColumnFile(fname) {
+
+		cf, err := OpenColumnFile(fname)
+		if err != nil {
+			return nil, err
+		}
+		defer cf.Close()
+
+		for g := 0; g < cf.NumGroups(); g++ {
+			people, err := cf.ReadGroup(g, FieldBLocLabel, FieldDLocLabel)
+			if err != nil {
+				return nil, err
+			}
+			ix.Add(people)
+		}
+
+		return ix, nil
+	}
+
+	rdr, err := NewReader(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer rdr.Close()
+
+	for rdr.Next() {
+		person := rdr.Person()
+		ix.birth[person.BLocLabel] = append(ix.birth[person.BLocLabel], ix.rows)
+		ix.death[person.DLocLabel] = append(ix.death[person.DLocLabel], ix.rows)
+		ix.rows++
+	}
+	if err := rdr.Err(); err != nil {
+		return nil, err
+	}
+
+	return ix, nil
+}
+
+// NumRows returns the number of rows in the indexed dataset.
+func (ix *LocationIndex) NumRows() int {
+	return ix.rows
+}
+
+// Born returns the rows of the people born at the given location, in
+// increasing order.  The result must not be modified.
+func (ix *LocationIndex) Born(loc string) []int {
+	return ix.birth[loc]
+}
+
+// Died returns the rows of the people who died at the given location,
+// in increasing order.  The result must not be modified.
+func (ix *LocationIndex) Died(loc string) []int {
+	return ix.death[loc]
+}
+
+// Locations returns the labels of all the places of birth or death,
+// sorted alphabetically.
+func (ix *LocationIndex) Locations() []string {
+
+	var locs []string
+	for loc := range ix.birth {
+		locs = append(locs, loc)
+	}
+	for loc := range ix.death {
+		if _, ok := ix.birth[loc]; !ok {
+			locs = append(locs, loc)
+		}
+	}
+	sort.Strings(locs)
+
+	return locs
+}
+
+// Matches returns true if the index was built from the named file,
+// and the file has not changed since.
+func (ix *LocationIndex) Matches(fname string) (bool, error) {
+
+	if ix.source == [sha256.Size]byte{} {
+		return false, nil
+	}
+
+	sum, err := Checksum(fname)
+	if err != nil {
+		return false, err
+	}
+
+	return sum == ix.source, nil
+}
+
+// Save writes the index to the named file, compressed according to
+// the file name extension (see CodecFromName).
+func (ix *LocationIndex) Save(fname string) error {
+
+	enc, err := NewGobEncoder(fname)
+	if err != nil {
+		return err
+	}
+	enc.fw.hdr.Source = ix.source
+
+	err = enc.Encode(&locIndexFile{
+		Version: locIndexVersion,
+		Rows:    ix.rows,
+		Birth:   ix.birth,
+		Death:   ix.death,
+	})
+
+	return enc.fw.close(err)
+}
+
+// LoadLocationIndex reads an index written by LocationIndex.Save.
+func LoadLocationIndex(fname string) (*LocationIndex, error) {
+
+	dec, err := NewGobDecoder(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+
+	var f locIndexFile
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("notable: %s: %v", fname, err)
+	}
+	if f.Version != locIndexVersion {
+		return nil, fmt.Errorf("notable: %s: unsupported location index version %d", fname, f.Version)
+	}
+
+	ix := &LocationIndex{rows: f.Rows, birth: f.Birth, death: f.Death}
+	if h := dec.Header(); h != nil {
+		ix.source = h.Source
+	}
+	if ix.birth == nil {
+		ix.birth = make(map[string][]int)
+	}
+	if ix.death == nil {
+		ix.death = make(map[string][]int)
+	}
+
+	return ix, nil
+}
@@ -0,0 +1,118 @@
+package notable
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// checkIndex compares an index of the sample people, repeated twice,
+// with the expected rows.
+func checkIndex(t *testing.T, name string, ix *LocationIndex) {
+
+	t.Helper()
+
+	cases := []struct {
+		loc        string
+		born, died []int
+	}{
+		{"London", []int{0, 4}, []int{0, 4}},
+		{"Brunswick", []int{1, 5}, nil},
+		{"Gottingen", nil, []int{1, 5}},
+		{"Alexandria", []int{3, 7}, []int{3, 7}},
+		{"Paris", nil, nil},
+	}
+
+	if ix.NumRows() != 8 {
+		t.Errorf("%s: %d rows, want 8", name, ix.NumRows())
+	}
+	for _, c := range cases {
+		if got := ix.Born(c.loc); !reflect.DeepEqual(got, c.born) {
+			t.Errorf("%s: born in %s %v, want %v", name, c.loc, got, c.born)
+		}
+		if got := ix.Died(c.loc); !reflect.DeepEqual(got, c.died) {
+			t.Errorf("%s: died in %s %v, want %v", name, c.loc, got, c.died)
+		}
+	}
+
+	want := []string{"Alexandria", "Brunswick", "Bryn Mawr", "Erlangen", "Gottingen", "London"}
+	if got := ix.Locations(); !reflect.DeepEqual(got, want) {
+		t.Errorf("%s: locations %q, want %q", name, got, want)
+	}
+}
+
+func TestLocationIndex(t *testing.T) {
+
+	sample := samplePeople()
+	var people People
+	for k := 0; k < 2; k++ {
+		for i := 0; i < sample.Len(); i++ {
+			people.Append(sample.Row(i))
+		}
+	}
+
+	ix := NewLocationIndex(&sample)
+	ix.Add(&sample)
+	checkIndex(t, "added", ix)
+
+	// Column files are read a group at a time
+	dir := t.TempDir()
+	for _, name := range []string{"people.ncol", "people.gob.gz"} {
+		fname := filepath.Join(dir, name)
+		writePeople(t, fname, &people)
+		ix, err := BuildLocationIndex(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkIndex(t, name, ix)
+	}
+
+	fname := filepath.Join(dir, "index.gob.zst")
+	if err := ix.Save(fname); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadLocationIndex(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIndex(t, "loaded", loaded)
+}
+
+// An index matches the file it was built from until the file changes.
+func TestLocationIndexSource(t *testing.T) {
+
+	people := samplePeople()
+	dir := t.TempDir()
+	data := filepath.Join(dir, "people.ncol")
+	writePeople(t, data, &people)
+
+	ix, err := BuildLocationIndex(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fname := filepath.Join(dir, "index.gob.gz")
+	if err := ix.Save(fname); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadLocationIndex(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, x := range []*LocationIndex{ix, loaded} {
+		if ok, err := x.Matches(data); err != nil || !ok {
+			t.Errorf("index does not match its data file: %v", err)
+		}
+	}
+
+	// Indexes not built from a file match no file
+	if ok, _ := NewLocationIndex(&people).Matches(data); ok {
+		t.Errorf("index built in memory matches %s", data)
+	}
+
+	fewer := people.Slice(0, 2)
+	writePeople(t, data, &fewer)
+	if ok, err := loaded.Matches(data); err != nil || ok {
+		t.Errorf("index matches a changed data file: %v", err)
+	}
+}
@@ -0,0 +1,397 @@
+// Package migration counts the moves of notable people from their
+// birth location to their death location.
+//
+// The counts form an origin-destination matrix, with one row for each
+// birth location and one column for each death location.  Few of the
+// possible pairs of locations occur, so the matrix is stored sparsely.
+// From the matrix are derived the largest flows, the number of people
+// moving in and out of each location, and the retention of each
+// location: the share of the people born there who also died there.
+// A Filter restricts the people counted to an era or a gender.
+//
+// For example, to find the ten largest moves of women born in the
+// nineteenth century:
+//
+//	m := migration.New(migration.Filter{Era: &notable.Window{Start: 1800, End: 1900}, Gender: "female"})
+//	m.AddPeople(people)
+//	top := m.Top(10, false)
+package migration
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+)
+
+// A Filter selects the people whose moves are counted.  The zero
+// value selects everyone.
+type Filter struct {
+
+	// If not nil, only people born in the window are counted, and
+	// people with no birth year are left out
+	Era *notable.Window
+
+	// If not empty, only people of this gender are counted
+	Gender string
+}
+
+// Keep returns true if the filter selects the person.
+func (f Filter) Keep(p *notable.Person) bool {
+
+	if f.Era != nil {
+		if p.IsNA(notable.FieldBYear) || p.BYear < f.Era.Start || p.BYear >= f.Era.End {
+			return false
+		}
+	}
+
+	if f.Gender != "" && p.Gender != f.Gender {
+		return false
+	}
+
+	return true
+}
+
+// String describes the people selected by the filter.
+func (f Filter) String() string {
+
+	s := "everyone"
+	if f.Gender != "" {
+		s = "gender " + f.Gender
+	}
+	if f.Era != nil {
+		s += fmt.Sprintf(", born %s", f.Era)
+	}
+
+	return s
+}
+
+// pair is a birth and death location, as indices into the labels.
+type pair struct {
+	from, to int
+}
+
+// A Matrix holds the number of people moving between each pair of
+// locations.
+type Matrix struct {
+
+	// Selects the people counted
+	filter Filter
+
+	// The names of the locations, in the order first seen
+	labels []string
+
+	// The position of each location in labels
+	index map[string]int
+
+	// The number of people for each pair of locations that occurs
+	counts map[pair]float64
+
+	// The number of people counted
+	total float64
+
+	// The number of people left out because a location is empty
+	incomplete int
+}
+
+// A Flow is the number of people moving between two locations.
+type Flow struct {
+
+	// The birth location
+	From string
+
+	// The death location
+	To string
+
+	// The number of people
+	Count float64
+}
+
+// A Balance summarizes the moves into and out of one location.
+type Balance struct {
+
+	// The name of the location
+	Location string
+
+	// The number of people born at the location
+	Born float64
+
+	// The number of people who died at the location
+	Died float64
+
+	// The number of people born and died at the location
+	Stayed float64
+
+	// The number of people who died at the location but were born
+	// elsewhere
+	In float64
+
+	// The number of people born at the location who died elsewhere
+	Out float64
+
+	// In minus Out, which is also Died minus Born
+	Net float64
+
+	// Stayed divided by Born, or NaN if no one was born at the
+	// location
+	Retention float64
+}
+
+// New returns an empty matrix, which counts the people selected by the
+// filter.
+func New(filter Filter) *Matrix {
+
+	return &Matrix{
+		filter: filter,
+		index:  make(map[string]int),
+		counts: make(map[pair]float64),
+	}
+}
+
+// Filter returns the filter used to select the people counted.
+func (m *Matrix) Filter() Filter {
+	return m.filter
+}
+
+// locate returns the position of a location, adding it if needed.
+func (m *Matrix) locate(loc string) int {
+
+	i, ok := m.index[loc]
+	if !ok {
+		i = len(m.labels)
+		m.labels = append(m.labels, loc)
+		m.index[loc] = i
+	}
+
+	return i
+}
+
+// Add counts one person, if the filter selects them.  People whose
+// birth or death location is empty are not counted, but the number of
+// them is kept (see Incomplete).
+func (m *Matrix) Add(p *notable.Person) {
+
+	if !m.filter.Keep(p) {
+		return
+	}
+
+	if !p.HasLocations() {
+		m.incomplete++
+		return
+	}
+
+	m.counts[pair{m.locate(p.BLocLabel), m.locate(p.DLocLabel)}]++
+	m.total++
+}
+
+// AddPeople counts every person in people selected by the filter.
+func (m *Matrix) AddPeople(people *notable.People) {
+	for i := 0; i < people.Len(); i++ {
+		person := people.Row(i)
+		m.Add(&person)
+	}
+}
+
+// AddReader counts every remaining person of r selected by the
+// filter.  It returns any error from reading the records.
+func (m *Matrix) AddReader(r *notable.Reader) error {
+
+	for r.Next() {
+		person := r.Person()
+		m.Add(&person)
+	}
+
+	return r.Err()
+}
+
+// Merge adds the counts of other, which should use the same filter,
+// so that the people can be counted in parts.
+func (m *Matrix) Merge(other *Matrix) {
+
+	for p, c := range other.counts {
+		m.counts[pair{m.locate(other.labels[p.from]), m.locate(other.labels[p.to])}] += c
+	}
+	m.total += other.total
+	m.incomplete += other.incomplete
+}
+
+// Total returns the number of people counted.
+func (m *Matrix) Total() float64 {
+	return m.total
+}
+
+// Incomplete returns the number of people selected by the filter who
+// were not counted because their birth or death location is empty.
+func (m *Matrix) Incomplete() int {
+	return m.incomplete
+}
+
+// Locations returns the names of the locations that are a birth or
+// death location of someone counted, in sorted order.
+func (m *Matrix) Locations() []string {
+
+	locs := append([]string(nil), m.labels...)
+	sort.Strings(locs)
+
+	return locs
+}
+
+// Count returns the number of people born at from who died at to.
+func (m *Matrix) Count(from, to string) float64 {
+
+	i, ok := m.index[from]
+	if !ok {
+		return 0
+	}
+	j, ok := m.index[to]
+	if !ok {
+		return 0
+	}
+
+	return m.counts[pair{i, j}]
+}
+
+// Flows returns the pairs of locations with a nonzero count, from the
+// largest count to the smallest.  Pairs with the same count are
+// ordered by their birth location and then their death location.  If
+// moves is true, the people who died where they were born are left
+// out.
+func (m *Matrix) Flows(moves bool) []Flow {
+
+	var flows []Flow
+	for p, c := range m.counts {
+		if moves && p.from == p.to {
+			continue
+		}
+		flows = append(flows, Flow{From: m.labels[p.from], To: m.labels[p.to], Count: c})
+	}
+
+	sort.Slice(flows, func(i, j int) bool {
+		a, b := flows[i], flows[j]
+		switch {
+		case a.Count != b.Count:
+			return a.Count > b.Count
+		case a.From != b.From:
+			return a.From < b.From
+		default:
+			return a.To < b.To
+		}
+	})
+
+	return flows
+}
+
+// Top returns the k largest flows, as ordered by Flows, or all of them
+// if k is negative.
+func (m *Matrix) Top(k int, moves bool) []Flow {
+
+	flows := m.Flows(moves)
+	if k >= 0 && k < len(flows) {
+		flows = flows[0:k]
+	}
+
+	return flows
+}
+
+// Balances returns the moves into and out of each location, ordered
+// by location name.
+func (m *Matrix) Balances() []Balance {
+
+	bal := make([]Balance, len(m.labels))
+	for i, loc := range m.labels {
+		bal[i].Location = loc
+	}
+
+	for p, c := range m.counts {
+		bal[p.from].Born += c
+		bal[p.to].Died += c
+		if p.from == p.to {
+			bal[p.from].Stayed += c
+		} else {
+			bal[p.from].Out += c
+			bal[p.to].In += c
+		}
+	}
+
+	for i := range bal {
+		b := &bal[i]
+		b.Net = b.In - b.Out
+		b.Retention = b.Stayed / b.Born
+	}
+
+	sort.Slice(bal, func(i, j int) bool {
+		return bal[i].Location < bal[j].Location
+	})
+
+	return bal
+}
+
+// TopBalances returns the balances of the k locations with the most
+// people born or died there, largest first, or of all the locations
+// if k is negative.  Ties are ordered by location name.
+func (m *Matrix) TopBalances(k int) []Balance {
+
+	bal := m.Balances()
+	sort.SliceStable(bal, func(i, j int) bool {
+		return bal[i].Born+bal[i].Died > bal[j].Born+bal[j].Died
+	})
+	if k >= 0 && k < len(bal) {
+		bal = bal[0:k]
+	}
+
+	return bal
+}
+
+// Retention returns the share of the people counted who died where
+// they were born, or NaN if no one was counted.
+func (m *Matrix) Retention() float64 {
+
+	if m.total == 0 {
+		return math.NaN()
+	}
+
+	var stayed float64
+	for p, c := range m.counts {
+		if p.from == p.to {
+			stayed += c
+		}
+	}
+
+	return stayed / m.total
+}
+
+// Edges returns the matrix as an edge list, with one row for each pair
+// of locations with a nonzero count, ordered as by Flows.  The table
+// can be written in any of the formats of notable.Table.
+func (m *Matrix) Edges(moves bool) *notable.Table {
+
+	t := &notable.Table{
+		KeyNames:   []string{notable.FieldBLocLabel.String(), notable.FieldDLocLabel.String()},
+		ValueNames: []string{"count", "share"},
+	}
+
+	for _, f := range m.Flows(moves) {
+		t.Keys = append(t.Keys, []string{f.From, f.To})
+		t.Values = append(t.Values, []float64{f.Count, f.Count / m.total})
+	}
+
+	return t
+}
+
+// BalanceTable returns the balances as a table, with one row for each
+// location.
+func (m *Matrix) BalanceTable() *notable.Table {
+
+	t := &notable.Table{
+		KeyNames:   []string{"location"},
+		ValueNames: []string{"born", "died", "stayed", "in", "out", "net", "retention"},
+	}
+
+	for _, b := range m.Balances() {
+		t.Keys = append(t.Keys, []string{b.Location})
+		t.Values = append(t.Values, []float64{b.Born, b.Died, b.Stayed, b.In, b.Out, b.Net, b.Retention})
+	}
+
+	return t
+}
@@ -0,0 +1,96 @@
+package migration
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+)
+
+// person returns a person born and died at the given locations.
+func person(born, died string, year int, gender string) notable.Person {
+	return notable.Person{BLocLabel: born, DLocLabel: died, BYear: year, Gender: gender}
+}
+
+// sample returns a matrix counting a few people, selected by the
+// filter.
+func sample(f Filter) *Matrix {
+
+	m := New(f)
+	for _, p := range []notable.Person{
+		person("Rome", "Paris", 1650, "male"),
+		person("Rome", "Paris", 1750, "female"),
+		person("Rome", "Rome", 1820, "male"),
+		person("Paris", "Rome", 1830, "female"),
+		person("Vienna", "Paris", 1840, "male"),
+		person("Vienna", "", 1850, "male"),
+	} {
+		m.Add(&p)
+	}
+
+	return m
+}
+
+func TestMatrixFlows(t *testing.T) {
+
+	cases := []struct {
+		filter Filter
+		moves  bool
+		flows  []Flow
+		total  float64
+	}{
+		{Filter{}, false, []Flow{{"Rome", "Paris", 2}, {"Paris", "Rome", 1}, {"Rome", "Rome", 1}, {"Vienna", "Paris", 1}}, 5},
+		{Filter{}, true, []Flow{{"Rome", "Paris", 2}, {"Paris", "Rome", 1}, {"Vienna", "Paris", 1}}, 5},
+		{Filter{Gender: "female"}, false, []Flow{{"Paris", "Rome", 1}, {"Rome", "Paris", 1}}, 2},
+		{Filter{Era: &notable.Window{Start: 1800, End: 1900}}, false,
+			[]Flow{{"Paris", "Rome", 1}, {"Rome", "Rome", 1}, {"Vienna", "Paris", 1}}, 3},
+	}
+
+	for _, c := range cases {
+		m := sample(c.filter)
+		if got := m.Flows(c.moves); !reflect.DeepEqual(got, c.flows) {
+			t.Errorf("%s: flows %v, want %v", c.filter, got, c.flows)
+		}
+		if m.Total() != c.total {
+			t.Errorf("%s: total %f, want %f", c.filter, m.Total(), c.total)
+		}
+	}
+
+	m := sample(Filter{})
+	if m.Incomplete() != 1 {
+		t.Errorf("%d incomplete people, want 1", m.Incomplete())
+	}
+	if got := m.Top(1, false); len(got) != 1 || got[0].Count != 2 {
+		t.Errorf("top flow %v", got)
+	}
+	if got := m.Top(-1, false); len(got) != 4 {
+		t.Errorf("%d flows with a negative limit, want 4", len(got))
+	}
+}
+
+func TestMatrixBalances(t *testing.T) {
+
+	m := sample(Filter{})
+	want := []Balance{
+		{Location: "Paris", Born: 1, Died: 3, In: 3, Out: 1, Net: 2, Retention: 0},
+		{Location: "Rome", Born: 3, Died: 2, Stayed: 1, In: 1, Out: 2, Net: -1, Retention: 1.0 / 3},
+		{Location: "Vienna", Born: 1, Out: 1, Net: -1, Retention: 0},
+	}
+	if got := m.Balances(); !reflect.DeepEqual(got, want) {
+		t.Errorf("balances %+v, want %+v", got, want)
+	}
+
+	// Five people were born or died in Rome, four in Paris
+	top := m.TopBalances(2)
+	if len(top) != 2 || top[0].Location != "Rome" || top[1].Location != "Paris" {
+		t.Errorf("top balances %+v", top)
+	}
+
+	if r := m.Retention(); r != 0.2 {
+		t.Errorf("retention %f, want 0.2", r)
+	}
+	if r := New(Filter{}).Retention(); !math.IsNaN(r) {
+		t.Errorf("retention %f with no people, want NaN", r)
+	}
+}
@@ -0,0 +1,352 @@
+package notable
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Missing data
+//
+// The birth and death years and coordinates of a person may be
+// missing from the source data.  A missing value is recorded
+// explicitly: in a Person, the field is included in the NA set and
+// holds its zero value; in People, the field's validity bitmap has a
+// zero bit for the row.  Labels (names, locations and gender) are
+// never missing, but may be empty.
+//
+// Converters and statistics follow a MissingPolicy chosen by the
+// caller.  The zero value of MissingPolicy keeps records with missing
+// values, marking the values as NA.
+
+// A FieldSet is a set of fields.
+type FieldSet uint16
+
+// Has returns true if f is in the set.
+func (s FieldSet) Has(f Field) bool {
+	return s&(1<<uint(f)) != 0
+}
+
+// Add includes f in the set.
+func (s *FieldSet) Add(f Field) {
+	*s |= 1 << uint(f)
+}
+
+// Nullable returns true if values of the field may be missing.
+func (f Field) Nullable() bool {
+	switch f {
+	case FieldBYear, FieldBLocLat, FieldBLocLong, FieldDYear, FieldDLocLat, FieldDLocLong:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsNA returns true if the given field of the person is missing.
+func (p Person) IsNA(f Field) bool {
+	return p.NA.Has(f)
+}
+
+// HasLocations returns true if both the birth and the death location
+// labels of the person are given.  Analyses comparing the birth and
+// death locations use only these people, rather than treating an
+// empty label as a location.
+func (p *Person) HasLocations() bool {
+	return p.BLocLabel != "" && p.DLocLabel != ""
+}
+
+// SetNA marks the given field of the person as missing and sets it to
+// its zero value.
+func (p *Person) SetNA(f Field) {
+	p.NA.Add(f)
+	switch f {
+	case FieldBYear:
+		p.BYear = 0
+	case FieldBLocLat:
+		p.BLocLat = 0
+	case FieldBLocLong:
+		p.BLocLong = 0
+	case FieldDYear:
+		p.DYear = 0
+	case FieldDLocLat:
+		p.DLocLat = 0
+	case FieldDLocLong:
+		p.DLocLong = 0
+	}
+}
+
+// A Bitmap holds one bit for each row of a column.
+type Bitmap struct {
+
+	// The bits, 64 to a word
+	Bits []uint64
+
+	// The number of bits in use
+	N int
+}
+
+// Len returns the number of bits in the bitmap.
+func (b *Bitmap) Len() int {
+	return b.N
+}
+
+// Get returns bit i of the bitmap.
+func (b *Bitmap) Get(i int) bool {
+	return b.Bits[i/64]&(1<<uint(i%64)) != 0
+}
+
+// Set sets bit i of the bitmap to v, extending the bitmap with zero
+// bits if needed.
+func (b *Bitmap) Set(i int, v bool) {
+	for len(b.Bits) <= i/64 {
+		b.Bits = append(b.Bits, 0)
+	}
+	if i >= b.N {
+		b.N = i + 1
+	}
+	if v {
+		b.Bits[i/64] |= 1 << uint(i%64)
+	} else {
+		b.Bits[i/64] &^= 1 << uint(i%64)
+	}
+}
+
+// Append adds a bit with value v to the end of the bitmap.
+func (b *Bitmap) Append(v bool) {
+	b.Set(b.N, v)
+}
+
+// IsNA returns true if the given field is missing in row i.
+func (p *People) IsNA(f Field, i int) bool {
+	b := p.Valid[f]
+	return b != nil && i < b.N && !b.Get(i)
+}
+
+// SetNA marks the given field as missing in row i.
+func (p *People) SetNA(f Field, i int) {
+
+	if p.Valid == nil {
+		p.Valid = make(map[Field]*Bitmap)
+	}
+
+	b := p.Valid[f]
+	if b == nil {
+		b = new(Bitmap)
+		p.Valid[f] = b
+	}
+
+	// Rows past the end of the bitmap are valid
+	for b.N < i {
+		b.Append(true)
+	}
+
+	b.Set(i, false)
+}
+
+// A MissingAction is the way that records with missing values are
+// treated.
+type MissingAction int
+
+const (
+	// KeepMissing retains records with missing values, marking the
+	// missing values as NA.  Statistics skip NA values, but still
+	// count the record.
+	KeepMissing MissingAction = iota
+
+	// DropMissing discards records with missing values.
+	DropMissing
+
+	// ImputeMissing retains records with missing values, replacing
+	// each missing value with the corresponding value from
+	// MissingPolicy.Fill.
+	ImputeMissing
+)
+
+var missingActionNames = []string{"keep", "drop", "impute"}
+
+// String returns the name of the action.
+func (a MissingAction) String() string {
+	if a < 0 || int(a) >= len(missingActionNames) {
+		return fmt.Sprintf("MissingAction(%d)", int(a))
+	}
+	return missingActionNames[a]
+}
+
+// ParseMissingAction returns the action with the given name ("keep",
+// "drop" or "impute").
+func ParseMissingAction(name string) (MissingAction, error) {
+
+	for a, n := range missingActionNames {
+		if n == strings.ToLower(name) {
+			return MissingAction(a), nil
+		}
+	}
+
+	return KeepMissing, fmt.Errorf("notable: unknown missing value action %q", name)
+}
+
+// A MissingPolicy determines how records with missing values are
+// treated.
+type MissingPolicy struct {
+
+	// What to do with a record that has missing values
+	Action MissingAction
+
+	// The fields that are considered.  If empty, all nullable fields
+	// are considered.
+	Fields []Field
+
+	// The values substituted for missing values under ImputeMissing.
+	// See Means for a way to obtain these.
+	Fill Person
+}
+
+// considers returns true if the policy applies to field f.
+func (mp *MissingPolicy) considers(f Field) bool {
+
+	if len(mp.Fields) == 0 {
+		return f.Nullable()
+	}
+
+	for _, g := range mp.Fields {
+		if f == g {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Apply applies the policy to one person, returning false if the
+// person should be discarded.
+func (mp *MissingPolicy) Apply(p *Person) bool {
+
+	if p.NA == 0 {
+		return true
+	}
+
+	for _, f := range Fields() {
+
+		if !p.IsNA(f) || !mp.considers(f) {
+			continue
+		}
+
+		switch mp.Action {
+		case DropMissing:
+			return false
+		case ImputeMissing:
+			p.copyField(&mp.Fill, f)
+			p.NA &^= 1 << uint(f)
+		}
+	}
+
+	return true
+}
+
+// ApplyPeople applies the policy to each row of people, returning the
+// rows that are retained.
+func (mp *MissingPolicy) ApplyPeople(people *People) *People {
+
+	if mp.Action == KeepMissing || len(people.Valid) == 0 {
+		return people
+	}
+
+	result := new(People)
+	for i := 0; i < people.Len(); i++ {
+		person := people.Row(i)
+		if mp.Apply(&person) {
+			result.Append(person)
+		}
+	}
+
+	return result
+}
+
+// copyField copies field f from src to p.
+func (p *Person) copyField(src *Person, f Field) {
+	switch f {
+	case FieldPrsLabel:
+		p.PrsLabel = src.PrsLabel
+	case FieldBYear:
+		p.BYear = src.BYear
+	case FieldBLocLabel:
+		p.BLocLabel = src.BLocLabel
+	case FieldBLocLat:
+		p.BLocLat = src.BLocLat
+	case FieldBLocLong:
+		p.BLocLong = src.BLocLong
+	case FieldDYear:
+		p.DYear = src.DYear
+	case FieldDLocLabel:
+		p.DLocLabel = src.DLocLabel
+	case FieldDLocLat:
+		p.DLocLat = src.DLocLat
+	case FieldDLocLong:
+		p.DLocLong = src.DLocLong
+	case FieldGender:
+		p.Gender = src.Gender
+	}
+}
+
+// Means returns a Person holding the mean of the observed values of
+// each nullable field in the named file, for use as MissingPolicy.Fill.
+// Mean years are rounded to the nearest year, and the labels are
+// empty.
+func Means(fname string) (Person, error) {
+
+	rdr, err := NewReader(fname)
+	if err != nil {
+		return Person{}, err
+	}
+	defer rdr.Close()
+
+	var sum, num [numFields]float64
+	for rdr.Next() {
+		person := rdr.Person()
+		for _, f := range Fields() {
+			if f.Nullable() && !person.IsNA(f) {
+				sum[f] += person.float(f)
+				num[f]++
+			}
+		}
+	}
+	if err := rdr.Err(); err != nil {
+		return Person{}, err
+	}
+
+	mean := func(f Field) float64 {
+		if num[f] == 0 {
+			return 0
+		}
+		return sum[f] / num[f]
+	}
+
+	return Person{
+		BYear:    int(math.Round(mean(FieldBYear))),
+		BLocLat:  mean(FieldBLocLat),
+		BLocLong: mean(FieldBLocLong),
+		DYear:    int(math.Round(mean(FieldDYear))),
+		DLocLat:  mean(FieldDLocLat),
+		DLocLong: mean(FieldDLocLong),
+	}, nil
+}
+
+// float returns the value of a numeric field as a float64.
+func (p *Person) float(f Field) float64 {
+	switch f {
+	case FieldBYear:
+		return float64(p.BYear)
+	case FieldBLocLat:
+		return p.BLocLat
+	case FieldBLocLong:
+		return p.BLocLong
+	case FieldDYear:
+		return float64(p.DYear)
+	case FieldDLocLat:
+		return p.DLocLat
+	case FieldDLocLong:
+		return p.DLocLong
+	default:
+		panic(fmt.Sprintf("notable: %s is not numeric", f))
+	}
+}
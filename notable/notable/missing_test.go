@@ -0,0 +1,154 @@
+package notable
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBitmap(t *testing.T) {
+
+	var b Bitmap
+	b.Set(70, true)
+	b.Append(false)
+	b.Append(true)
+
+	if b.Len() != 73 {
+		t.Errorf("length %d, want 73", b.Len())
+	}
+	for i, want := range map[int]bool{0: false, 63: false, 70: true, 71: false, 72: true} {
+		if b.Get(i) != want {
+			t.Errorf("bit %d is %t, want %t", i, b.Get(i), want)
+		}
+	}
+
+	b.Set(70, false)
+	if b.Get(70) {
+		t.Errorf("bit 70 not cleared")
+	}
+}
+
+func TestPeopleSetNA(t *testing.T) {
+
+	people := samplePeople()
+	people.SetNA(FieldGender, 1)
+
+	cases := []struct {
+		field Field
+		row   int
+		na    bool
+	}{
+		{FieldGender, 0, false},
+		{FieldGender, 1, true},
+		{FieldGender, 2, false},
+		{FieldDYear, 2, true},
+		{FieldDYear, 1, false},
+		{FieldBLocLat, 3, true},
+		{FieldBLocLat, 10, false},
+	}
+
+	for _, c := range cases {
+		if people.IsNA(c.field, c.row) != c.na {
+			t.Errorf("%s in row %d: NA is %t, want %t", c.field, c.row, !c.na, c.na)
+		}
+	}
+}
+
+func TestHasLocations(t *testing.T) {
+
+	cases := []struct {
+		born, died string
+		want       bool
+	}{
+		{"London", "Paris", true},
+		{"London", "", false},
+		{"", "Paris", false},
+		{"", "", false},
+	}
+
+	for _, c := range cases {
+		p := Person{BLocLabel: c.born, DLocLabel: c.died}
+		if p.HasLocations() != c.want {
+			t.Errorf("born in %q, died in %q: HasLocations is %t", c.born, c.died, !c.want)
+		}
+	}
+}
+
+func TestMissingPolicy(t *testing.T) {
+
+	fill := Person{BYear: 1800, DYear: 1850, BLocLat: 1, BLocLong: 2, DLocLat: 3, DLocLong: 4}
+
+	cases := []struct {
+		policy MissingPolicy
+		names  []string
+		dyears []int
+	}{
+		{MissingPolicy{}, []string{"Ada", "Carl", "Emmy", "Hypatia"}, []int{1852, 1855, 0, 0}},
+		{MissingPolicy{Action: DropMissing}, []string{"Ada", "Carl"}, []int{1852, 1855}},
+		{MissingPolicy{Action: DropMissing, Fields: []Field{FieldBYear}},
+			[]string{"Ada", "Carl", "Emmy"}, []int{1852, 1855, 0}},
+		{MissingPolicy{Action: ImputeMissing, Fill: fill},
+			[]string{"Ada", "Carl", "Emmy", "Hypatia"}, []int{1852, 1855, 1850, 1850}},
+		{MissingPolicy{Action: ImputeMissing, Fill: fill, Fields: []Field{FieldBYear}},
+			[]string{"Ada", "Carl", "Emmy", "Hypatia"}, []int{1852, 1855, 0, 0}},
+	}
+
+	for _, c := range cases {
+		people := samplePeople()
+		result := c.policy.ApplyPeople(&people)
+
+		var names []string
+		var dyears []int
+		for i := 0; i < result.Len(); i++ {
+			p := result.Row(i)
+			names = append(names, p.PrsLabel)
+			dyears = append(dyears, p.DYear)
+		}
+		if !reflect.DeepEqual(names, c.names) || !reflect.DeepEqual(dyears, c.dyears) {
+			t.Errorf("%s %v: got %v %v, want %v %v", c.policy.Action, c.policy.Fields,
+				names, dyears, c.names, c.dyears)
+		}
+	}
+
+	// Imputed values are no longer missing
+	people := samplePeople()
+	p := people.Row(3)
+	mp := MissingPolicy{Action: ImputeMissing, Fill: fill}
+	if !mp.Apply(&p) || p.NA != 0 || p.BYear != 1800 || p.DLocLong != 4 {
+		t.Errorf("imputed person %+v", p)
+	}
+}
+
+func TestParseMissingAction(t *testing.T) {
+
+	for _, a := range []MissingAction{KeepMissing, DropMissing, ImputeMissing} {
+		if got, err := ParseMissingAction(a.String()); err != nil || got != a {
+			t.Errorf("ParseMissingAction(%q) = %s, %v", a.String(), got, err)
+		}
+	}
+
+	if _, err := ParseMissingAction("ignore"); err == nil {
+		t.Errorf("unknown action parsed")
+	}
+}
+
+// Means skips the missing values.
+func TestMeans(t *testing.T) {
+
+	people := samplePeople()
+	fname := filepath.Join(t.TempDir(), "people.ncol")
+	writePeople(t, fname, &people)
+
+	mean, err := Means(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mean.BYear != 1825 || mean.DYear != 1854 {
+		t.Errorf("mean years %d and %d, want 1825 and 1854", mean.BYear, mean.DYear)
+	}
+	if math.Abs(mean.BLocLat-153.4/3) > 1e-9 || math.Abs(mean.DLocLong-(-65.5/3)) > 1e-9 {
+		t.Errorf("mean coordinates %f and %f", mean.BLocLat, mean.DLocLong)
+	}
+}
@@ -0,0 +1,201 @@
+// Package output saves the tables made by the analysis scripts, in a
+// format, order and file chosen with command line flags.
+//
+// A script that saves a table for the births and another for the
+// deaths, in the order and format given by the -sort and -format
+// flags, does:
+//
+//	out := output.Options{Default: "%s_mean_by_year"}
+//	out.FileFlags(flag.CommandLine)
+//	out.SortFlags(flag.CommandLine)
+//	flag.Parse()
+//	if err := out.Check(); err != nil {
+//		...
+//	}
+//	...
+//	if err := out.Arrange(tab); err != nil {
+//		...
+//	}
+//	if err := out.Save(tab, "birth"); err != nil {
+//		...
+//	}
+package output
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+	"github.com/kshedden/godata_workshop/notable/notable/parquetio"
+)
+
+// Ext holds the file name extension of each output format.
+var Ext = map[string]string{
+	"csv":      ".csv",
+	"json":     ".jsonl",
+	"markdown": ".md",
+	"parquet":  ".parquet",
+}
+
+// Formats describes the output formats, for the help of a flag.
+const Formats = "csv, json (lines), markdown or parquet"
+
+// Check returns an error if format is not one of the output formats.
+func Check(format string) error {
+
+	if _, ok := Ext[format]; !ok {
+		return fmt.Errorf("output: unknown format %q", format)
+	}
+
+	return nil
+}
+
+// Save writes the table to the named file in the given format.  The
+// Parquet format is written by the parquetio package, the others by
+// Table.Write.
+func Save(tab *notable.Table, fname, format string) error {
+
+	if err := Check(format); err != nil {
+		return err
+	}
+
+	if format == "parquet" {
+		return parquetio.WriteTable(fname, tab)
+	}
+
+	out, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+	if err := tab.Write(out, format); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// Options describe the files that a script saves its tables to, and
+// the rows that the tables hold.
+type Options struct {
+
+	// The name of the output file.  If a script saves one table for
+	// each of several events, such as birth and death, %s stands for
+	// the event.  If empty, Default is used.
+	Pattern string
+
+	// The name of the output file if Pattern is empty, without the
+	// extension, which is given by the format.  %s stands for the
+	// event, as in Pattern.
+	Default string
+
+	// The format of the output files: csv, json, markdown or parquet
+	Format string
+
+	// The order of the rows: name (by the key columns), count
+	// (largest first) or mean (by the first column of means,
+	// smallest first).  If empty, the order is not changed.
+	Sort string
+
+	// Rows with a smaller count are left out
+	Min int
+}
+
+// perEvent returns true if the files are named for an event.
+func (o *Options) perEvent() bool {
+	return strings.Contains(o.Default, "%s")
+}
+
+// FileFlags defines the -out and -format flags, giving the name and
+// format of the output files.
+func (o *Options) FileFlags(fs *flag.FlagSet) {
+
+	usage := fmt.Sprintf("Output file name (default %s.<ext>)", o.Default)
+	if o.perEvent() {
+		usage = fmt.Sprintf("Output file name, with %%s standing for birth or death (default %s.<ext>)",
+			strings.Replace(o.Default, "%s", "<event>", -1))
+	}
+	fs.StringVar(&o.Pattern, "out", "", usage)
+	fs.StringVar(&o.Format, "format", "csv", "Output format: "+Formats)
+}
+
+// SortFlags defines the -sort and -min flags, giving the order of the
+// rows and the smallest count kept.
+func (o *Options) SortFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Sort, "sort", "name", "Order of the rows: name, count (largest first) or mean (earliest first)")
+	fs.IntVar(&o.Min, "min", 0, "Leave out rows with a count smaller than this")
+}
+
+// Check returns an error if the options are not valid, so that
+// mistakes are found before the data are read.
+func (o *Options) Check() error {
+
+	if err := Check(o.Format); err != nil {
+		return err
+	}
+
+	switch o.Sort {
+	case "", "name", "count", "mean":
+	default:
+		return fmt.Errorf("output: unknown sort order %q", o.Sort)
+	}
+
+	if o.Pattern != "" && o.perEvent() && !strings.Contains(o.Pattern, "%s") {
+		return fmt.Errorf("output: the file name %q must contain %%s, for birth or death", o.Pattern)
+	}
+
+	return nil
+}
+
+// Name returns the name of the output file for the given event, which
+// is ignored if the files are not named for an event.
+func (o *Options) Name(event string) string {
+
+	name := o.Pattern
+	if name == "" {
+		name = o.Default + Ext[o.Format]
+	}
+
+	if o.perEvent() {
+		name = strings.Replace(name, "%s", event, -1)
+	}
+
+	return name
+}
+
+// Arrange leaves out the rows of the table with a count smaller than
+// Min, and sorts the rest in the order given by Sort.  The table must
+// have a column named count if Min is set or Sort is count, and a
+// column whose name starts with mean_ if Sort is mean.
+func (o *Options) Arrange(tab *notable.Table) error {
+
+	if o.Min > 0 {
+		if err := tab.AtLeast("count", float64(o.Min)); err != nil {
+			return err
+		}
+	}
+
+	switch o.Sort {
+	case "name":
+		tab.Sort()
+	case "count":
+		return tab.SortBy("count", true)
+	case "mean":
+		for _, name := range tab.ValueNames {
+			if strings.HasPrefix(name, "mean_") {
+				return tab.SortBy(name, false)
+			}
+		}
+		return fmt.Errorf("output: the table has no mean to sort by")
+	}
+
+	return nil
+}
+
+// Save writes the table for the given event to the file given by Name,
+// in the format given by Format.
+func (o *Options) Save(tab *notable.Table, event string) error {
+	return Save(tab, o.Name(event), o.Format)
+}
@@ -0,0 +1,106 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+)
+
+func TestOptionsName(t *testing.T) {
+
+	cases := []struct {
+		opts Options
+		name string
+	}{
+		{Options{Default: "%s_stats", Format: "csv"}, "birth_stats.csv"},
+		{Options{Default: "%s_stats", Format: "json"}, "birth_stats.jsonl"},
+		{Options{Default: "%s_stats", Format: "csv", Pattern: "out/%s.txt"}, "out/birth.txt"},
+		{Options{Default: "edges", Format: "markdown"}, "edges.md"},
+		{Options{Default: "edges", Format: "csv", Pattern: "e.csv"}, "e.csv"},
+	}
+
+	for _, c := range cases {
+		if err := c.opts.Check(); err != nil {
+			t.Errorf("%+v: %v", c.opts, err)
+		}
+		if got := c.opts.Name("birth"); got != c.name {
+			t.Errorf("%+v: name %q, want %q", c.opts, got, c.name)
+		}
+	}
+
+	for _, o := range []Options{
+		{Default: "%s_stats", Format: "xml"},
+		{Default: "%s_stats", Format: "csv", Sort: "size"},
+		{Default: "%s_stats", Format: "csv", Pattern: "stats.csv"},
+	} {
+		if err := o.Check(); err == nil {
+			t.Errorf("%+v: no error", o)
+		}
+	}
+}
+
+func TestOptionsArrange(t *testing.T) {
+
+	table := func() *notable.Table {
+		return &notable.Table{
+			KeyNames:   []string{"BLocLabel"},
+			ValueNames: []string{"mean_BYear", "count"},
+			Keys:       [][]string{{"Rome"}, {"Berlin"}, {"Paris"}},
+			Values:     [][]float64{{1400, 5}, {1500, 2}, {1300, 9}},
+		}
+	}
+
+	cases := []struct {
+		opts Options
+		keys []string
+	}{
+		{Options{Sort: "name"}, []string{"Berlin", "Paris", "Rome"}},
+		{Options{Sort: "count"}, []string{"Paris", "Rome", "Berlin"}},
+		{Options{Sort: "mean", Min: 3}, []string{"Paris", "Rome"}},
+		{Options{}, []string{"Rome", "Berlin", "Paris"}},
+	}
+
+	for _, c := range cases {
+		tab := table()
+		if err := c.opts.Arrange(tab); err != nil {
+			t.Errorf("%+v: %v", c.opts, err)
+			continue
+		}
+		var keys []string
+		for _, k := range tab.Keys {
+			keys = append(keys, k[0])
+		}
+		if !reflect.DeepEqual(keys, c.keys) {
+			t.Errorf("%+v: rows %q, want %q", c.opts, keys, c.keys)
+		}
+	}
+}
+
+func TestSave(t *testing.T) {
+
+	tab := &notable.Table{
+		KeyNames:   []string{"BLocLabel"},
+		ValueNames: []string{"count"},
+		Keys:       [][]string{{"Rome"}},
+		Values:     [][]float64{{5}},
+	}
+
+	dir := t.TempDir()
+	for format, ext := range Ext {
+		fname := filepath.Join(dir, "tab"+ext)
+		if err := Save(tab, fname, format); err != nil {
+			t.Errorf("%s: %v", format, err)
+			continue
+		}
+		if fi, err := os.Stat(fname); err != nil || fi.Size() == 0 {
+			t.Errorf("%s: nothing written", format)
+		}
+	}
+
+	if err := Save(tab, filepath.Join(dir, "tab.xml"), "xml"); err == nil {
+		t.Errorf("unknown format saved")
+	}
+}
@@ -0,0 +1,350 @@
+// Package parquetio reads and writes the notable people data in
+// Apache Parquet format, so that the data can be used from pandas, R,
+// DuckDB, Spark and other tools that do not read gob.
+//
+// Years are stored as signed 32-bit integers, coordinates as doubles
+// and labels as UTF-8 strings.  Missing years and coordinates are
+// stored as nulls.  The location labels and gender use dictionary
+// encoding.
+package parquetio
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// record is the layout of one row of a Parquet file.
+type record struct {
+	PrsLabel  string   `parquet:"name=PrsLabel, type=BYTE_ARRAY, convertedtype=UTF8"`
+	BYear     *int32   `parquet:"name=BYear, type=INT32, convertedtype=INT_32, repetitiontype=OPTIONAL"`
+	BLocLabel string   `parquet:"name=BLocLabel, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	BLocLat   *float64 `parquet:"name=BLocLat, type=DOUBLE, repetitiontype=OPTIONAL"`
+	BLocLong  *float64 `parquet:"name=BLocLong, type=DOUBLE, repetitiontype=OPTIONAL"`
+	DYear     *int32   `parquet:"name=DYear, type=INT32, convertedtype=INT_32, repetitiontype=OPTIONAL"`
+	DLocLabel string   `parquet:"name=DLocLabel, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	DLocLat   *float64 `parquet:"name=DLocLat, type=DOUBLE, repetitiontype=OPTIONAL"`
+	DLocLong  *float64 `parquet:"name=DLocLong, type=DOUBLE, repetitiontype=OPTIONAL"`
+	Gender    string   `parquet:"name=Gender, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+}
+
+// The number of goroutines used to encode and decode pages
+const parallel = 4
+
+// The number of rows decoded at a time by a Reader
+const batchSize = 10000
+
+// ParseCompression returns the Parquet compression codec with the
+// given name, e.g. "snappy", "gzip", "zstd" or "uncompressed".
+func ParseCompression(name string) (parquet.CompressionCodec, error) {
+	return parquet.CompressionCodecFromString(strings.ToUpper(name))
+}
+
+// Writer writes Person records to a Parquet file.
+type Writer struct {
+	fw source.ParquetFile
+	pw *writer.ParquetWriter
+}
+
+// NewWriter creates a Parquet file that is compressed with the given
+// codec.
+func NewWriter(fname string, codec parquet.CompressionCodec) (*Writer, error) {
+
+	fw, err := local.NewLocalFileWriter(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	pw, err := writer.NewParquetWriter(fw, new(record), parallel)
+	if err != nil {
+		fw.Close()
+		return nil, err
+	}
+	pw.CompressionType = codec
+
+	return &Writer{fw: fw, pw: pw}, nil
+}
+
+// Write adds one person to the file.
+func (w *Writer) Write(person notable.Person) error {
+	return w.pw.Write(toRecord(person))
+}
+
+// WritePeople adds all the people in the collection to the file.
+func (w *Writer) WritePeople(people *notable.People) error {
+
+	for i := 0; i < people.Len(); i++ {
+		if err := w.Write(people.Row(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close writes the Parquet footer and closes the file, returning the
+// first error that occurs.
+func (w *Writer) Close() error {
+
+	err := w.pw.WriteStop()
+
+	if cerr := w.fw.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// Reader reads Person records from a Parquet file.  It is used in the
+// same way as notable.Reader.
+type Reader struct {
+	fr source.ParquetFile
+	pr *reader.ParquetReader
+
+	// The rows that have not yet been read from the file
+	remaining int
+
+	// The current batch of records, and the position in it
+	batch []record
+	pos   int
+
+	person notable.Person
+	err    error
+}
+
+// NewReader returns a Reader for the named Parquet file.
+func NewReader(fname string) (*Reader, error) {
+
+	fr, err := local.NewLocalFileReader(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, err := reader.NewParquetReader(fr, new(record), parallel)
+	if err != nil {
+		fr.Close()
+		return nil, fmt.Errorf("parquetio: %s: %v", fname, err)
+	}
+
+	return &Reader{fr: fr, pr: pr, remaining: int(pr.GetNumRows())}, nil
+}
+
+// NumRows returns the number of rows in the file.
+func (r *Reader) NumRows() int {
+	return int(r.pr.GetNumRows())
+}
+
+// Next advances to the next record, returning false when there are no
+// more records or an error occurs.
+func (r *Reader) Next() bool {
+
+	if r.err != nil {
+		return false
+	}
+
+	if r.pos >= len(r.batch) {
+		if r.remaining == 0 {
+			return false
+		}
+		n := batchSize
+		if n > r.remaining {
+			n = r.remaining
+		}
+		r.batch = make([]record, n)
+		if r.err = r.pr.Read(&r.batch); r.err != nil {
+			return false
+		}
+		r.remaining -= n
+		r.pos = 0
+	}
+
+	r.person = fromRecord(&r.batch[r.pos])
+	r.pos++
+
+	return true
+}
+
+// Person returns the record most recently read by Next.
+func (r *Reader) Person() notable.Person {
+	return r.person
+}
+
+// Err returns the first error encountered while reading, if any.
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Close releases the file underlying the reader.
+func (r *Reader) Close() error {
+	r.pr.ReadStop()
+	return r.fr.Close()
+}
+
+// WritePeople saves the collection to the named Parquet file.
+func WritePeople(fname string, people *notable.People, codec parquet.CompressionCodec) error {
+
+	w, err := NewWriter(fname, codec)
+	if err != nil {
+		return err
+	}
+
+	if err := w.WritePeople(people); err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// ReadPeople reads all the records in the named Parquet file.
+func ReadPeople(fname string) (*notable.People, error) {
+
+	r, err := NewReader(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	people := new(notable.People)
+	for r.Next() {
+		people.Append(r.Person())
+	}
+
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+
+	return people, nil
+}
+
+// toRecord converts a Person to a Parquet row, using nulls for the
+// missing values.
+func toRecord(p notable.Person) *record {
+
+	year := func(f notable.Field, v int) *int32 {
+		if p.IsNA(f) {
+			return nil
+		}
+		y := int32(v)
+		return &y
+	}
+
+	coord := func(f notable.Field, v float64) *float64 {
+		if p.IsNA(f) {
+			return nil
+		}
+		return &v
+	}
+
+	return &record{
+		PrsLabel:  p.PrsLabel,
+		BYear:     year(notable.FieldBYear, p.BYear),
+		BLocLabel: p.BLocLabel,
+		BLocLat:   coord(notable.FieldBLocLat, p.BLocLat),
+		BLocLong:  coord(notable.FieldBLocLong, p.BLocLong),
+		DYear:     year(notable.FieldDYear, p.DYear),
+		DLocLabel: p.DLocLabel,
+		DLocLat:   coord(notable.FieldDLocLat, p.DLocLat),
+		DLocLong:  coord(notable.FieldDLocLong, p.DLocLong),
+		Gender:    p.Gender,
+	}
+}
+
+// fromRecord converts a Parquet row to a Person, marking nulls as
+// missing values.
+func fromRecord(r *record) notable.Person {
+
+	p := notable.Person{
+		PrsLabel:  r.PrsLabel,
+		BLocLabel: r.BLocLabel,
+		DLocLabel: r.DLocLabel,
+		Gender:    r.Gender,
+	}
+
+	year := func(f notable.Field, v *int32, dst *int) {
+		if v == nil {
+			p.SetNA(f)
+			return
+		}
+		*dst = int(*v)
+	}
+
+	coord := func(f notable.Field, v *float64, dst *float64) {
+		if v == nil {
+			p.SetNA(f)
+			return
+		}
+		*dst = *v
+	}
+
+	year(notable.FieldBYear, r.BYear, &p.BYear)
+	coord(notable.FieldBLocLat, r.BLocLat, &p.BLocLat)
+	coord(notable.FieldBLocLong, r.BLocLong, &p.BLocLong)
+	year(notable.FieldDYear, r.DYear, &p.DYear)
+	coord(notable.FieldDLocLat, r.DLocLat, &p.DLocLat)
+	coord(notable.FieldDLocLong, r.DLocLong, &p.DLocLong)
+
+	return p
+}
+
+// WriteTable saves a table of grouped summaries to the named Parquet
+// file, compressed with snappy.  The key columns are stored as UTF-8
+// strings and the value columns as doubles, with NaN values stored as
+// nulls.
+func WriteTable(fname string, t *notable.Table) error {
+
+	var md []string
+	for _, name := range t.KeyNames {
+		md = append(md, fmt.Sprintf("name=%s, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY", name))
+	}
+	for _, name := range t.ValueNames {
+		md = append(md, fmt.Sprintf("name=%s, type=DOUBLE, repetitiontype=OPTIONAL", name))
+	}
+
+	fw, err := local.NewLocalFileWriter(fname)
+	if err != nil {
+		return err
+	}
+
+	pw, err := writer.NewCSVWriter(md, fw, parallel)
+	if err != nil {
+		fw.Close()
+		return err
+	}
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+
+	for i := range t.Keys {
+
+		// The writer keeps the rows until a row group is complete
+		row := make([]interface{}, len(md))
+		for j, k := range t.Keys[i] {
+			row[j] = k
+		}
+		for j, v := range t.Values[i] {
+			if math.IsNaN(v) {
+				row[len(t.KeyNames)+j] = nil
+			} else {
+				row[len(t.KeyNames)+j] = v
+			}
+		}
+		if err = pw.Write(row); err != nil {
+			break
+		}
+	}
+
+	if werr := pw.WriteStop(); err == nil {
+		err = werr
+	}
+	if cerr := fw.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
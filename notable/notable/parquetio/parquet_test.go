@@ -0,0 +1,86 @@
+package parquetio
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kshedden/godata_workshop/notable/notable/internal/fixture"
+)
+
+func TestRoundTrip(t *testing.T) {
+
+	want := fixture.People()
+
+	for _, name := range []string{"snappy", "gzip", "zstd", "uncompressed"} {
+		codec, err := ParseCompression(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fname := filepath.Join(t.TempDir(), "people.parquet")
+		if err := WritePeople(fname, want, codec); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		got, err := ReadPeople(fname)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got.Len() != want.Len() {
+			t.Errorf("%s: read %d people, want %d", name, got.Len(), want.Len())
+			continue
+		}
+		for i := 0; i < want.Len(); i++ {
+			if !reflect.DeepEqual(got.Row(i), want.Row(i)) {
+				t.Errorf("%s: row %d is %+v, want %+v", name, i, got.Row(i), want.Row(i))
+			}
+		}
+	}
+
+	if _, err := ParseCompression("bzip2"); err == nil {
+		t.Errorf("unknown compression parsed")
+	}
+}
+
+func TestReader(t *testing.T) {
+
+	want := fixture.People()
+	fname := filepath.Join(t.TempDir(), "people.parquet")
+	codec, _ := ParseCompression("snappy")
+
+	w, err := NewWriter(fname, codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < want.Len(); i++ {
+		if err := w.Write(want.Row(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rdr, err := NewReader(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdr.Close()
+
+	if rdr.NumRows() != want.Len() {
+		t.Errorf("%d rows, want %d", rdr.NumRows(), want.Len())
+	}
+	var i int
+	for ; rdr.Next(); i++ {
+		if i < want.Len() && !reflect.DeepEqual(rdr.Person(), want.Row(i)) {
+			t.Errorf("row %d is %+v, want %+v", i, rdr.Person(), want.Row(i))
+		}
+	}
+	if err := rdr.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if i != want.Len() {
+		t.Errorf("read %d rows, want %d", i, want.Len())
+	}
+}
@@ -0,0 +1,287 @@
+package notable
+
+import "fmt"
+
+// Len returns the number of people in the collection.
+func (p *People) Len() int {
+	return len(p.PrsLabel)
+}
+
+// Row returns the i'th person in the collection.
+func (p *People) Row(i int) Person {
+
+	person := Person{
+		PrsLabel:  p.PrsLabel[i],
+		BYear:     p.BYear[i],
+		BLocLabel: p.BLocLabel.Value(i),
+		BLocLat:   p.BLocLat[i],
+		BLocLong:  p.BLocLong[i],
+		DYear:     p.DYear[i],
+		DLocLabel: p.DLocLabel.Value(i),
+		DLocLat:   p.DLocLat[i],
+		DLocLong:  p.DLocLong[i],
+		Gender:    p.Gender.Value(i),
+	}
+
+	for f := range p.Valid {
+		if p.IsNA(f, i) {
+			person.NA.Add(f)
+		}
+	}
+
+	return person
+}
+
+// Append adds a person to the end of the collection.
+func (p *People) Append(person Person) {
+
+	i := p.Len()
+
+	p.PrsLabel = append(p.PrsLabel, person.PrsLabel)
+	p.BYear = append(p.BYear, person.BYear)
+	p.BLocLabel.Append(person.BLocLabel)
+	p.BLocLat = append(p.BLocLat, person.BLocLat)
+	p.BLocLong = append(p.BLocLong, person.BLocLong)
+	p.DYear = append(p.DYear, person.DYear)
+	p.DLocLabel.Append(person.DLocLabel)
+	p.DLocLat = append(p.DLocLat, person.DLocLat)
+	p.DLocLong = append(p.DLocLong, person.DLocLong)
+	p.Gender.Append(person.Gender)
+
+	if person.NA != 0 {
+		for _, f := range Fields() {
+			if person.IsNA(f) {
+				p.SetNA(f, i)
+			}
+		}
+	}
+}
+
+// Filter returns a new collection holding the people for which keep
+// returns true.
+func (p *People) Filter(keep func(Person) bool) People {
+
+	var result People
+	for i := 0; i < p.Len(); i++ {
+		if person := p.Row(i); keep(person) {
+			result.Append(person)
+		}
+	}
+
+	return result
+}
+
+// Take returns a new collection holding the people in the given rows,
+// in the given order.  Rows may be repeated.
+func (p *People) Take(indices []int) People {
+
+	var result People
+	for _, i := range indices {
+		result.Append(p.Row(i))
+	}
+
+	return result
+}
+
+// Slice returns the people in rows i through j-1.  The columns of the
+// result share storage with p, but the validity bitmaps do not.  The
+// columns have no spare capacity, so appending to the result copies
+// them rather than overwriting the later rows of p.
+func (p *People) Slice(i, j int) People {
+
+	result := People{
+		PrsLabel:  p.PrsLabel[i:j:j],
+		BYear:     p.BYear[i:j:j],
+		BLocLabel: p.BLocLabel.Slice(i, j),
+		BLocLat:   p.BLocLat[i:j:j],
+		BLocLong:  p.BLocLong[i:j:j],
+		DYear:     p.DYear[i:j:j],
+		DLocLabel: p.DLocLabel.Slice(i, j),
+		DLocLat:   p.DLocLat[i:j:j],
+		DLocLong:  p.DLocLong[i:j:j],
+		Gender:    p.Gender.Slice(i, j),
+	}
+
+	for f := range p.Valid {
+		for k := i; k < j; k++ {
+			if p.IsNA(f, k) {
+				result.SetNA(f, k-i)
+			}
+		}
+	}
+
+	return result
+}
+
+// Validate checks that all the columns have the same length, that the
+// codes of the dictionary-encoded columns are in range, and that the
+// validity bitmaps are consistent with the columns.
+func (p *People) Validate() error {
+
+	n := p.Len()
+
+	lens := []struct {
+		f Field
+		n int
+	}{
+		{FieldBYear, len(p.BYear)},
+		{FieldBLocLabel, p.BLocLabel.Len()},
+		{FieldBLocLat, len(p.BLocLat)},
+		{FieldBLocLong, len(p.BLocLong)},
+		{FieldDYear, len(p.DYear)},
+		{FieldDLocLabel, p.DLocLabel.Len()},
+		{FieldDLocLat, len(p.DLocLat)},
+		{FieldDLocLong, len(p.DLocLong)},
+		{FieldGender, p.Gender.Len()},
+	}
+	for _, c := range lens {
+		if c.n != n {
+			return fmt.Errorf("notable: column %s has length %d, but %s has length %d",
+				c.f, c.n, FieldPrsLabel, n)
+		}
+	}
+
+	dicts := []struct {
+		f Field
+		c *DictColumn
+	}{
+		{FieldBLocLabel, &p.BLocLabel},
+		{FieldDLocLabel, &p.DLocLabel},
+		{FieldGender, &p.Gender},
+	}
+	for _, d := range dicts {
+		for i, code := range d.c.Codes {
+			if code < 0 || int(code) >= len(d.c.Dict) {
+				return fmt.Errorf("notable: column %s has code %d in row %d, but the dictionary has %d values",
+					d.f, code, i, len(d.c.Dict))
+			}
+		}
+	}
+
+	for f, b := range p.Valid {
+		if !f.Nullable() {
+			return fmt.Errorf("notable: column %s has a validity bitmap but is not nullable", f)
+		}
+		if b == nil {
+			continue
+		}
+		if b.N > n {
+			return fmt.Errorf("notable: validity bitmap for %s has length %d, but the columns have length %d",
+				f, b.N, n)
+		}
+		if len(b.Bits) < (b.N+63)/64 {
+			return fmt.Errorf("notable: validity bitmap for %s holds %d words, too few for length %d",
+				f, len(b.Bits), b.N)
+		}
+	}
+
+	return nil
+}
+
+// buildIndexes creates the maps from values to codes of the
+// dictionary-encoded columns, which are not present after the columns
+// are decoded.
+func (p *People) buildIndexes() {
+	p.BLocLabel.buildIndex()
+	p.DLocLabel.buildIndex()
+	p.Gender.buildIndex()
+}
+
+// legacyPeople is the layout of People before the location and gender
+// columns were dictionary-encoded.  Files holding a People value in
+// this layout are converted when they are read.
+type legacyPeople struct {
+	PrsLabel  []string
+	BYear     []int
+	BLocLabel []string
+	BLocLat   []float64
+	BLocLong  []float64
+	DYear     []int
+	DLocLabel []string
+	DLocLat   []float64
+	DLocLong  []float64
+	Gender    []string
+	Valid     map[Field]*Bitmap
+}
+
+// people returns the collection in the current layout.  The columns
+// that are not dictionary-encoded are shared.
+func (lp *legacyPeople) people() People {
+	return People{
+		PrsLabel:  lp.PrsLabel,
+		BYear:     lp.BYear,
+		BLocLabel: NewDictColumn(lp.BLocLabel),
+		BLocLat:   lp.BLocLat,
+		BLocLong:  lp.BLocLong,
+		DYear:     lp.DYear,
+		DLocLabel: NewDictColumn(lp.DLocLabel),
+		DLocLat:   lp.DLocLat,
+		DLocLong:  lp.DLocLong,
+		Gender:    NewDictColumn(lp.Gender),
+		Valid:     lp.Valid,
+	}
+}
+
+// column returns a pointer to the column holding the given field.
+func (p *People) column(f Field) interface{} {
+	switch f {
+	case FieldPrsLabel:
+		return &p.PrsLabel
+	case FieldBYear:
+		return &p.BYear
+	case FieldBLocLabel:
+		return &p.BLocLabel
+	case FieldBLocLat:
+		return &p.BLocLat
+	case FieldBLocLong:
+		return &p.BLocLong
+	case FieldDYear:
+		return &p.DYear
+	case FieldDLocLabel:
+		return &p.DLocLabel
+	case FieldDLocLat:
+		return &p.DLocLat
+	case FieldDLocLong:
+		return &p.DLocLong
+	case FieldGender:
+		return &p.Gender
+	default:
+		panic(fmt.Sprintf("notable: unknown field %v", f))
+	}
+}
+
+// resize sets the length of every column to n, filling any new rows
+// with zero values.
+func (p *People) resize(n int) {
+	p.PrsLabel = resizeStrings(p.PrsLabel, n)
+	p.BYear = resizeInts(p.BYear, n)
+	p.BLocLabel.resize(n)
+	p.BLocLat = resizeFloats(p.BLocLat, n)
+	p.BLocLong = resizeFloats(p.BLocLong, n)
+	p.DYear = resizeInts(p.DYear, n)
+	p.DLocLabel.resize(n)
+	p.DLocLat = resizeFloats(p.DLocLat, n)
+	p.DLocLong = resizeFloats(p.DLocLong, n)
+	p.Gender.resize(n)
+}
+
+func resizeStrings(x []string, n int) []string {
+	if len(x) >= n {
+		return x[0:n]
+	}
+	return append(x, make([]string, n-len(x))...)
+}
+
+func resizeInts(x []int, n int) []int {
+	if len(x) >= n {
+		return x[0:n]
+	}
+	return append(x, make([]int, n-len(x))...)
+}
+
+func resizeFloats(x []float64, n int) []float64 {
+	if len(x) >= n {
+		return x[0:n]
+	}
+	return append(x, make([]float64, n-len(x))...)
+}
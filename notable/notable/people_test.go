@@ -0,0 +1,111 @@
+package notable
+
+import (
+	"reflect"
+	"testing"
+)
+
+// samplePeople returns a small collection of people, including
+// missing values, for use in tests.
+func samplePeople() People {
+
+	persons := []Person{
+		{PrsLabel: "Ada", BYear: 1815, BLocLabel: "London", BLocLat: 51.5, BLocLong: -0.1,
+			DYear: 1852, DLocLabel: "London", DLocLat: 51.5, DLocLong: -0.1, Gender: "female"},
+		{PrsLabel: "Carl", BYear: 1777, BLocLabel: "Brunswick", BLocLat: 52.3, BLocLong: 10.5,
+			DYear: 1855, DLocLabel: "Gottingen", DLocLat: 51.5, DLocLong: 9.9, Gender: "male"},
+		{PrsLabel: "Emmy", BYear: 1882, BLocLabel: "Erlangen", BLocLat: 49.6, BLocLong: 11.0,
+			DLocLabel: "Bryn Mawr", DLocLat: 40.0, DLocLong: -75.3, Gender: "female"},
+		{PrsLabel: "Hypatia", BLocLabel: "Alexandria", DLocLabel: "Alexandria", Gender: "female"},
+	}
+	persons[2].SetNA(FieldDYear)
+	persons[3].SetNA(FieldBYear)
+	persons[3].SetNA(FieldDYear)
+	for _, f := range []Field{FieldBLocLat, FieldBLocLong, FieldDLocLat, FieldDLocLong} {
+		persons[3].SetNA(f)
+	}
+
+	var people People
+	for _, p := range persons {
+		people.Append(p)
+	}
+
+	return people
+}
+
+func TestPeopleRowAppend(t *testing.T) {
+
+	people := samplePeople()
+	if err := people.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var copied People
+	for i := 0; i < people.Len(); i++ {
+		copied.Append(people.Row(i))
+	}
+
+	for i := 0; i < people.Len(); i++ {
+		if want, got := people.Row(i), copied.Row(i); !reflect.DeepEqual(want, got) {
+			t.Errorf("row %d: got %+v, want %+v", i, got, want)
+		}
+	}
+
+	if !people.Row(3).IsNA(FieldBYear) || people.Row(0).IsNA(FieldBYear) {
+		t.Errorf("missing birth years not kept")
+	}
+}
+
+func TestPeopleSelect(t *testing.T) {
+
+	people := samplePeople()
+
+	cases := []struct {
+		name string
+		got  People
+		want []string
+	}{
+		{"filter", people.Filter(func(p Person) bool { return p.Gender == "female" }),
+			[]string{"Ada", "Emmy", "Hypatia"}},
+		{"take", people.Take([]int{2, 0, 2}), []string{"Emmy", "Ada", "Emmy"}},
+		{"slice", people.Slice(1, 3), []string{"Carl", "Emmy"}},
+		{"empty slice", people.Slice(2, 2), nil},
+	}
+
+	for _, c := range cases {
+		var names []string
+		for i := 0; i < c.got.Len(); i++ {
+			names = append(names, c.got.Row(i).PrsLabel)
+		}
+		if !reflect.DeepEqual(names, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, names, c.want)
+		}
+		if err := c.got.Validate(); err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+	}
+}
+
+// Appending to a slice must not change the rows of the original
+// collection that follow the slice.
+func TestPeopleSliceAppend(t *testing.T) {
+
+	people := samplePeople()
+	want := make([]Person, people.Len())
+	for i := range want {
+		want[i] = people.Row(i)
+	}
+
+	part := people.Slice(0, 2)
+	part.Append(Person{PrsLabel: "Sofia", BYear: 1850, BLocLabel: "Moscow", DYear: 1891,
+		DLocLabel: "Stockholm", Gender: "female"})
+
+	for i := range want {
+		if got := people.Row(i); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("row %d changed to %+v", i, got)
+		}
+	}
+	if got := part.Row(2).PrsLabel; got != "Sofia" {
+		t.Errorf("appended row is %q", got)
+	}
+}
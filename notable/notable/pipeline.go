@@ -0,0 +1,334 @@
+package notable
+
+import (
+	"io"
+	"runtime"
+	"sync"
+)
+
+// Concurrent decoding
+//
+// A Reader created by NewParallelReader decodes a file in three
+// stages, each running on its own goroutines:
+//
+//	decompress    one goroutine reads ahead in the compressed stream
+//	read          one goroutine splits the stream into raw records
+//	parse         a pool of workers converts raw records to Person
+//	              values, e.g. parsing numbers with strconv
+//
+// Records pass between the stages in batches.  Gob streams can only
+// be decoded in order, so for gob files the workers have little to
+// do, but decompression still overlaps with decoding.
+
+// Pipeline configures the concurrent decoding of a file.  The zero
+// value uses a default for each setting.
+type Pipeline struct {
+
+	// The number of goroutines that parse records.  If not positive,
+	// runtime.GOMAXPROCS(0) is used.
+	Workers int
+
+	// The number of records in each batch passed from the reading
+	// goroutine to the workers.  If not positive, 1000 is used.
+	BatchSize int
+
+	// The number of batches, and of blocks of decompressed data,
+	// that may wait between one stage and the next.  If not
+	// positive, twice the number of workers is used.
+	Buffer int
+
+	// If true, records are returned in the order that their batches
+	// are parsed, rather than the order in which they appear in the
+	// file.  This can be faster when the order does not matter.
+	// Otherwise no more than Workers+Buffer batches are read ahead
+	// of the one being returned, so that batches parsed out of order
+	// do not pile up behind a slow one.
+	Unordered bool
+}
+
+// readAheadSize is the size of each block of decompressed data read
+// ahead of the parser.
+const readAheadSize = 1 << 20
+
+// setDefaults replaces settings that are not positive with their
+// defaults.
+func (p *Pipeline) setDefaults() {
+
+	if p.Workers <= 0 {
+		p.Workers = runtime.GOMAXPROCS(0)
+	}
+
+	if p.BatchSize <= 0 {
+		p.BatchSize = 1000
+	}
+
+	if p.Buffer <= 0 {
+		p.Buffer = 2 * p.Workers
+	}
+}
+
+// NewParallelReader returns a Reader for the named file that decodes
+// the records concurrently, as configured by p.  It is used in the
+// same way as a Reader returned by NewReader.  Errors are reported
+// after all the records that precede them in the file, unless
+// p.Unordered is true.
+func NewParallelReader(fname string, p Pipeline) (*Reader, error) {
+	p.setDefaults()
+	return newReader(fname, &p)
+}
+
+// batch is a group of consecutive records passing through the
+// pipeline.
+type batch struct {
+
+	// The position of the batch in the file, counting from zero
+	seq int
+
+	// The raw records
+	raw []interface{}
+
+	// The parsed records
+	people []Person
+
+	// An error that occurred while parsing, after the parsed
+	// records
+	err error
+}
+
+// startPipeline starts the goroutines that read and parse the file,
+// and returns a function yielding the parsed records.
+func (r *Reader) startPipeline() func() (Person, error) {
+
+	p := r.pipeline
+	work := make(chan *batch, p.Buffer)
+	results := make(chan *batch, p.Buffer)
+	done := make(chan struct{})
+
+	// The error that ended reading, usually io.EOF.  It is set
+	// before work is closed.
+	var readErr error
+
+	// In ordered mode, holds a token for each batch that has been
+	// read but not yet returned
+	var window chan struct{}
+	if !p.Unordered {
+		window = make(chan struct{}, p.Workers+p.Buffer)
+	}
+
+	// Read the raw records, in batches
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(work)
+		for seq := 0; ; seq++ {
+			b := &batch{seq: seq, raw: make([]interface{}, 0, p.BatchSize)}
+			for len(b.raw) < p.BatchSize && readErr == nil {
+				raw, err := r.read()
+				if err != nil {
+					readErr = err
+					break
+				}
+				b.raw = append(b.raw, raw)
+			}
+			if window != nil {
+				select {
+				case window <- struct{}{}:
+				case <-done:
+					return
+				}
+			}
+			select {
+			case work <- b:
+			case <-done:
+				return
+			}
+			if readErr != nil {
+				return
+			}
+		}
+	}()
+
+	// Parse the batches
+	var workers sync.WaitGroup
+	for k := 0; k < p.Workers; k++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for b := range work {
+				b.people = make([]Person, 0, len(b.raw))
+				for _, raw := range b.raw {
+					if r.parse == nil {
+						b.people = append(b.people, raw.(Person))
+						continue
+					}
+					person, err := r.parse(raw)
+					if err != nil {
+						b.err = err
+						break
+					}
+					b.people = append(b.people, person)
+				}
+				b.raw = nil
+				select {
+				case results <- b:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		workers.Wait()
+		close(results)
+	}()
+
+	// Stop the goroutines before the file is closed
+	var once sync.Once
+	stop := func() {
+		once.Do(func() { close(done) })
+		wg.Wait()
+	}
+	fclose := r.close
+	r.close = func() error {
+		stop()
+		return fclose()
+	}
+
+	// Batches that have arrived before the batches preceding them
+	pending := make(map[int]*batch)
+	var cur *batch
+	var seq, i int
+	var err error
+
+	// take makes b the batch being returned, letting another batch
+	// be read in its place
+	take := func(b *batch) {
+		cur, i = b, 0
+		seq++
+		if window != nil {
+			<-window
+		}
+	}
+
+	return func() (Person, error) {
+
+		for cur == nil || i >= len(cur.people) {
+
+			if cur != nil && cur.err != nil {
+				err = cur.err
+			}
+			if err != nil {
+				stop()
+				return Person{}, err
+			}
+
+			if b, ok := pending[seq]; ok && !p.Unordered {
+				delete(pending, seq)
+				take(b)
+				continue
+			}
+
+			b, ok := <-results
+			if !ok {
+				// All batches have been parsed
+				err = readErr
+				cur = nil
+				continue
+			}
+			if p.Unordered || b.seq == seq {
+				take(b)
+			} else {
+				pending[b.seq] = b
+			}
+		}
+
+		i++
+		return cur.people[i-1], nil
+	}
+}
+
+// readAhead reads blocks of data on its own goroutine, so that
+// decompressing a file overlaps with parsing it.
+type readAhead struct {
+
+	// The blocks that have been read
+	blocks chan []byte
+
+	// The unread part of the current block
+	cur []byte
+
+	// The error that ended reading.  It is set before blocks is
+	// closed.
+	err error
+
+	// Closed to stop reading
+	done chan struct{}
+
+	// Closed when the reading goroutine exits
+	exited chan struct{}
+}
+
+// newReadAhead starts reading from src, keeping up to n blocks ready.
+func newReadAhead(src io.Reader, n int) *readAhead {
+
+	ra := &readAhead{
+		blocks: make(chan []byte, n),
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(ra.exited)
+		defer close(ra.blocks)
+		for {
+			buf := make([]byte, readAheadSize)
+			k, err := io.ReadFull(src, buf)
+			if k > 0 {
+				select {
+				case ra.blocks <- buf[0:k]:
+				case <-ra.done:
+					return
+				}
+			}
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			if err != nil {
+				ra.err = err
+				return
+			}
+		}
+	}()
+
+	return ra
+}
+
+// Read reads data from the blocks read ahead.
+func (ra *readAhead) Read(p []byte) (int, error) {
+
+	for len(ra.cur) == 0 {
+		select {
+		case b, ok := <-ra.blocks:
+			if !ok {
+				return 0, ra.err
+			}
+			ra.cur = b
+		case <-ra.done:
+			return 0, io.ErrClosedPipe
+		}
+	}
+
+	n := copy(p, ra.cur)
+	ra.cur = ra.cur[n:]
+
+	return n, nil
+}
+
+// stop stops reading, and waits for the reading goroutine to exit.
+func (ra *readAhead) stop() {
+	close(ra.done)
+	<-ra.exited
+}
@@ -0,0 +1,203 @@
+package notable
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// manyPeople returns n people, each with a distinct name.
+func manyPeople(n int) *People {
+
+	sample := samplePeople()
+	people := new(People)
+	for i := 0; i < n; i++ {
+		p := sample.Row(i % sample.Len())
+		p.PrsLabel = fmt.Sprintf("%s %d", p.PrsLabel, i)
+		people.Append(p)
+	}
+
+	return people
+}
+
+// readAll returns the names of the records returned by rdr.
+func readAll(t *testing.T, rdr *Reader) []string {
+
+	t.Helper()
+
+	var names []string
+	for rdr.Next() {
+		names = append(names, rdr.Person().PrsLabel)
+	}
+	if err := rdr.Err(); err != nil {
+		t.Fatal(err)
+	}
+	rdr.Close()
+
+	return names
+}
+
+func TestParallelReader(t *testing.T) {
+
+	people := manyPeople(1000)
+	var want []string
+	for i := 0; i < people.Len(); i++ {
+		want = append(want, people.PrsLabel[i])
+	}
+
+	cases := []struct {
+		name   string
+		format Format
+	}{
+		{"rows.csv.gz", CSVRows},
+		{"rows.json.zst", JSONRows},
+		{"structs.json", JSONStructs},
+		{"rows.gob.lz4", GobRows},
+		{"structs.gob.gz", GobStructs},
+		{"columns.ncol", ColumnGroups},
+	}
+
+	pipelines := []Pipeline{
+		{},
+		{Workers: 3, BatchSize: 7, Buffer: 1},
+		{Workers: 1, BatchSize: 1},
+		{Workers: 4, BatchSize: 100, Unordered: true},
+	}
+
+	for _, c := range cases {
+		fname := filepath.Join(t.TempDir(), c.name)
+		writeFormat(t, fname, c.format, people)
+
+		for _, p := range pipelines {
+			rdr, err := NewParallelReader(fname, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := readAll(t, rdr)
+			if p.Unordered {
+				sort.Strings(got)
+				sorted := append([]string(nil), want...)
+				sort.Strings(sorted)
+				if !reflect.DeepEqual(got, sorted) {
+					t.Errorf("%s, %+v: the records differ", c.name, p)
+				}
+			} else if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s, %+v: the records differ, or are out of order", c.name, p)
+			}
+		}
+	}
+}
+
+// An error is reported after the records that precede it, and values
+// that cannot be parsed are counted as they are by NewReader.
+func TestParallelReaderErrors(t *testing.T) {
+
+	fname := filepath.Join(t.TempDir(), "rows.csv.gz")
+	w, err := NewCSVWriter(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header []string
+	for _, f := range Fields() {
+		header = append(header, f.String())
+	}
+	w.Write(header)
+	people := manyPeople(100)
+	for i := 0; i < people.Len(); i++ {
+		p := people.Row(i)
+		row := p.Strings()
+		if i%10 == 0 {
+			row[FieldBYear] = "unknown"
+		}
+		w.Write(row)
+	}
+	w.Write([]string{"too", "short"})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	readers := []func() (*Reader, error){
+		func() (*Reader, error) { return NewReader(fname) },
+		func() (*Reader, error) { return NewParallelReader(fname, Pipeline{Workers: 3, BatchSize: 7}) },
+	}
+
+	for i, open := range readers {
+		rdr, err := open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		for rdr.Next() {
+			n++
+		}
+		if n != 100 || rdr.Err() == nil {
+			t.Errorf("reader %d: %d records and error %v", i, n, rdr.Err())
+		}
+		if rdr.Invalid(FieldBYear) != 10 {
+			t.Errorf("reader %d: %d invalid birth years, want 10", i, rdr.Invalid(FieldBYear))
+		}
+		rdr.Close()
+	}
+}
+
+// A parallel reader can be closed before all the records are read.
+func TestParallelReaderClose(t *testing.T) {
+
+	fname := filepath.Join(t.TempDir(), "structs.gob.gz")
+	writeFormat(t, fname, GobStructs, manyPeople(5000))
+
+	rdr, err := NewParallelReader(fname, Pipeline{Workers: 2, BatchSize: 10, Buffer: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 25 && rdr.Next(); i++ {
+	}
+	if err := rdr.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+// In ordered mode, a slow batch holds up the reading of later ones,
+// rather than letting them pile up.
+func TestParallelReaderWindow(t *testing.T) {
+
+	fname := filepath.Join(t.TempDir(), "rows.csv.gz")
+	people := manyPeople(2000)
+	writeFormat(t, fname, CSVRows, people)
+
+	p := Pipeline{Workers: 4, BatchSize: 10, Buffer: 2}
+	rdr, err := NewParallelReader(fname, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Parsing the first record waits until the others have had time
+	// to be parsed
+	release := make(chan struct{})
+	var parsed, early int64
+	parse := rdr.parse
+	rdr.parse = func(raw interface{}) (Person, error) {
+		if raw.(numberedRow).n == 1 {
+			<-release
+		}
+		atomic.AddInt64(&parsed, 1)
+		return parse(raw)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		atomic.StoreInt64(&early, atomic.LoadInt64(&parsed))
+		close(release)
+	}()
+
+	got := readAll(t, rdr)
+	if len(got) != people.Len() || got[0] != people.PrsLabel[0] || got[len(got)-1] != people.PrsLabel[len(got)-1] {
+		t.Errorf("%d records, or out of order", len(got))
+	}
+	if max := int64((p.Workers + p.Buffer) * p.BatchSize); early > max {
+		t.Errorf("%d records parsed while the first was held up, want at most %d", early, max)
+	}
+}
@@ -0,0 +1,613 @@
+package notable
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// A Format identifies how the records of a dataset file are stored.
+type Format int
+
+const (
+	// UnknownFormat is used when the contents of a file are not
+	// recognized
+	UnknownFormat Format = iota
+
+	// CSVRows holds one row of strings per line, in CSV format, as
+	// written by convert.go
+	CSVRows
+
+	// JSONRows holds one json array of strings per line, as written
+	// by convert.go
+	JSONRows
+
+	// JSONStructs holds one json-encoded Person per line
+	JSONStructs
+
+	// GobRows holds a stream of gob-encoded []string values, as
+	// written by convert.go
+	GobRows
+
+	// GobStructs holds a stream of gob-encoded Person values, as
+	// written by convert_structs.go
+	GobStructs
+
+	// GobColumns holds a single gob-encoded People value, as written
+	// by convert_structs_cols.go
+	GobColumns
+
+	// ColumnGroups is a column file, written by ColumnWriter
+	ColumnGroups
+)
+
+// String returns a short name for the format.
+func (f Format) String() string {
+	switch f {
+	case CSVRows:
+		return "csv"
+	case JSONRows:
+		return "json"
+	case JSONStructs:
+		return "json-struct"
+	case GobRows:
+		return "gob"
+	case GobStructs:
+		return "struct"
+	case GobColumns:
+		return "cols"
+	case ColumnGroups:
+		return "ncol"
+	default:
+		return "unknown"
+	}
+}
+
+// Reader yields the records of a dataset file as Person values,
+// regardless of which of the supported formats the file uses.  The
+// format and the compression codec are detected from the contents of
+// the file.
+//
+// A Reader is used like a bufio.Scanner:
+//
+//	r, err := notable.NewReader("fb_struct.gob.gz")
+//	...
+//	defer r.Close()
+//	for r.Next() {
+//	    person := r.Person()
+//	    ...
+//	}
+//	if err := r.Err(); err != nil {
+//	    ...
+//	}
+type Reader struct {
+
+	// Schema locates the fields of a Person in the columns of
+	// row-oriented files (CSVRows, JSONRows and GobRows).  If nil,
+	// DefaultSchema is used.  Schema may be changed before the first
+	// call to Next.
+	Schema *Schema
+
+	// Missing determines how records with missing values are
+	// treated.  By default they are kept, with the missing values
+	// marked as NA.  Missing may be changed before the first call to
+	// Next.
+	Missing MissingPolicy
+
+	// Releases the underlying file
+	close func() error
+
+	// The name of the file
+	name string
+
+	// The header of the file, or nil if it has none
+	hdr *Header
+
+	// The detected format
+	format Format
+
+	// Converts the rows of a row-oriented file, once the header has
+	// been read
+	mapper *RowMapper
+
+	// The number of records read, before the missing value policy is
+	// applied
+	nrec int64
+
+	// Returns the next raw record, or io.EOF when there are no more.
+	// Raw records are converted to Person values by parse.
+	read func() (interface{}, error)
+
+	// Converts a raw record to a Person.  It may be called from
+	// several goroutines at once.  If nil, read returns Person
+	// values.
+	parse func(interface{}) (Person, error)
+
+	// Settings for concurrent decoding, or nil to decode on the
+	// calling goroutine
+	pipeline *Pipeline
+
+	// Returns the next record, or io.EOF when there are no more.  It
+	// is set up by the first call to Next.
+	next func() (Person, error)
+
+	// The most recent record
+	person Person
+
+	// The first error that is not io.EOF
+	err error
+}
+
+// NewReader returns a Reader for the named file.  See
+// NewParallelReader for a Reader that decodes the file concurrently.
+func NewReader(fname string) (*Reader, error) {
+	return newReader(fname, nil)
+}
+
+// newReader returns a Reader for the named file, which decodes the
+// file concurrently if p is not nil.
+func newReader(fname string, p *Pipeline) (*Reader, error) {
+
+	if IsColumnFile(fname) {
+		r, err := newColumnReader(fname)
+		if err != nil {
+			return nil, err
+		}
+		r.pipeline = p
+		r.name = fname
+		return r, nil
+	}
+
+	fr, err := openFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	if err := fr.hdr.complete(fname); err != nil {
+		fr.close()
+		return nil, err
+	}
+
+	// Decompress the file on its own goroutine
+	var src io.Reader = fr.zr
+	r := &Reader{close: fr.close, pipeline: p, name: fname, hdr: fr.hdr}
+	if p != nil {
+		ra := newReadAhead(fr.zr, p.Buffer)
+		src = ra
+		r.close = func() error {
+			ra.stop()
+			return fr.close()
+		}
+	}
+
+	if err := r.init(bufio.NewReader(src)); err != nil {
+		r.close()
+		if r.format != UnknownFormat {
+			// Report the contents that were expected
+			if herr := r.hdr.expect(fname, r.format); herr != nil {
+				return nil, herr
+			}
+		}
+		if r.hdr != nil && r.hdr.Format != UnknownFormat {
+			return nil, fmt.Errorf("notable: %s: header says the file holds %s, but: %v",
+				fname, r.hdr.Format.describe(), err)
+		}
+		return nil, fmt.Errorf("notable: %s: %v", fname, err)
+	}
+
+	// The contents must agree with the header
+	if err := r.hdr.expect(fname, r.format); err != nil {
+		r.close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// newColumnReader returns a Reader that reads a column file one row
+// group at a time.
+func newColumnReader(fname string) (*Reader, error) {
+
+	cf, err := OpenColumnFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Reader{close: cf.Close, format: ColumnGroups, hdr: cf.Header()}
+
+	var people *People
+	var g, i int
+	r.read = func() (interface{}, error) {
+		for people == nil || i >= people.Len() {
+			if g >= cf.NumGroups() {
+				return nil, io.EOF
+			}
+			if people, err = cf.ReadGroup(g); err != nil {
+				return nil, err
+			}
+			g++
+			i = 0
+		}
+		i++
+		return people.Row(i - 1), nil
+	}
+
+	return r, nil
+}
+
+// Header returns the header of the file being read, or nil if the
+// file has no header.
+func (r *Reader) Header() *Header {
+	return r.hdr
+}
+
+// Format returns the format of the file being read.
+func (r *Reader) Format() Format {
+	return r.format
+}
+
+// Invalid returns the number of values of the given field that could
+// not be parsed, and were treated as missing, in the records read so
+// far.  It is always zero for files that hold typed records.  If the
+// records are decoded concurrently (see NewParallelReader), Invalid
+// should only be called after Next has returned false.
+func (r *Reader) Invalid(f Field) int {
+	if r.mapper == nil {
+		return 0
+	}
+	return r.mapper.Invalid(f)
+}
+
+// rowCountError is returned when a file has been read in full, but
+// holds a different number of records than its header gives.
+type rowCountError struct {
+	name       string
+	rows, want int64
+}
+
+func (e *rowCountError) Error() string {
+	return fmt.Sprintf("notable: %s has %d records, but its header says it has %d", e.name, e.rows, e.want)
+}
+
+// Next advances to the next record, which is then available through
+// the Person method.  It returns false when there are no more
+// records or an error occurs.
+func (r *Reader) Next() bool {
+
+	if r.err != nil {
+		return false
+	}
+
+	if r.next == nil {
+		r.start()
+	}
+
+	for {
+		person, err := r.next()
+		if err == io.EOF && r.hdr != nil && r.hdr.Rows >= 0 && r.nrec != r.hdr.Rows {
+			err = &rowCountError{name: r.name, rows: r.nrec, want: r.hdr.Rows}
+		}
+		if err != nil {
+			if err != io.EOF {
+				r.err = err
+			}
+			r.next = func() (Person, error) { return Person{}, io.EOF }
+			return false
+		}
+
+		r.nrec++
+		if r.Missing.Apply(&person) {
+			r.person = person
+			return true
+		}
+	}
+}
+
+// start sets up the next function, either reading and parsing each
+// record in turn or starting a concurrent pipeline.
+func (r *Reader) start() {
+
+	if r.pipeline != nil {
+		r.next = r.startPipeline()
+		return
+	}
+
+	r.next = func() (Person, error) {
+		raw, err := r.read()
+		if err != nil {
+			return Person{}, err
+		}
+		if r.parse == nil {
+			return raw.(Person), nil
+		}
+		return r.parse(raw)
+	}
+}
+
+// Person returns the record most recently read by Next.
+func (r *Reader) Person() Person {
+	return r.person
+}
+
+// Err returns the first error encountered while reading, if any.
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Close releases the file underlying the reader.
+func (r *Reader) Close() error {
+	return r.close()
+}
+
+// ReadPeople reads every record of the named file into one People
+// value.  The file may be in any format read by NewReader, such as a
+// column file or a gob-encoded People value.
+func ReadPeople(fname string) (*People, error) {
+
+	rdr, err := NewReader(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer rdr.Close()
+
+	people := new(People)
+	for rdr.Next() {
+		people.Append(rdr.Person())
+	}
+	if err := rdr.Err(); err != nil {
+		return nil, err
+	}
+
+	return people, nil
+}
+
+// init detects the format of the data available from br and prepares
+// the next function to read it.
+func (r *Reader) init(br *bufio.Reader) error {
+
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	// An empty file has no records
+	first := bytes.TrimLeft(head, " \t\r\n")
+	if len(first) == 0 {
+		r.read = func() (interface{}, error) { return nil, io.EOF }
+		return nil
+	}
+
+	// Each json value is split from the stream when it is read, and
+	// decoded when it is parsed.
+	dec := json.NewDecoder(br)
+	readJSON := func() (interface{}, error) {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		return raw, err
+	}
+
+	switch {
+	case first[0] == '[':
+		r.format = JSONRows
+		r.rowSource(readJSON, func(v interface{}) ([]string, error) {
+			var row []string
+			err := json.Unmarshal(v.(json.RawMessage), &row)
+			return row, err
+		})
+		return nil
+	case first[0] == '{':
+		r.format = JSONStructs
+		r.read = readJSON
+		r.parse = func(v interface{}) (Person, error) {
+			var person Person
+			err := json.Unmarshal(v.(json.RawMessage), &person)
+			return person, err
+		}
+		return nil
+	case isText(head):
+		r.format = CSVRows
+		cr := csv.NewReader(br)
+		r.rowSource(func() (interface{}, error) { return cr.Read() }, stringRow)
+		return nil
+	default:
+		return r.initGob(br)
+	}
+}
+
+// initGob determines which kind of value a gob stream holds by trying
+// to decode the first value as each of the candidate types in turn.
+// The bytes consumed by a failed attempt are replayed to the next
+// one.
+func (r *Reader) initGob(src io.Reader) error {
+
+	// All bytes read from src so far
+	var seen []byte
+
+	attempt := func(value interface{}) (*gob.Decoder, error) {
+		rec := &recordingReader{r: src, buf: new(bytes.Buffer)}
+		dec := gob.NewDecoder(io.MultiReader(bytes.NewReader(seen), rec))
+		err := dec.Decode(value)
+
+		// Keep every byte the attempt read, which can run past the
+		// first value since the decoder buffers its input, so that
+		// the next attempt can replay them.  If this attempt
+		// succeeded, its decoder goes on reading without recording.
+		seen = append(seen, rec.buf.Bytes()...)
+		rec.buf = nil
+
+		return dec, err
+	}
+
+	// A stream of Person values
+	var person Person
+	dec, err := attempt(&person)
+	if err == nil {
+		r.format = GobStructs
+		pending := true
+		r.read = func() (interface{}, error) {
+			if pending {
+				pending = false
+				return person, nil
+			}
+			var p Person
+			err := dec.Decode(&p)
+			return p, err
+		}
+		return nil
+	}
+
+	// A stream of rows, starting with the header
+	var header []string
+	dec, err = attempt(&header)
+	if err == nil {
+		r.format = GobRows
+		r.rowSource(func() (interface{}, error) {
+			if header != nil {
+				row := header
+				header = nil
+				return row, nil
+			}
+			var row []string
+			err := dec.Decode(&row)
+			return row, err
+		}, stringRow)
+		return nil
+	}
+
+	// A single People value, possibly written before the location
+	// and gender columns were dictionary-encoded
+	var people People
+	_, err = attempt(&people)
+	if err != nil {
+		var old legacyPeople
+		if _, lerr := attempt(&old); lerr == nil {
+			people, err = old.people(), nil
+		}
+	}
+	if err == nil {
+		r.format = GobColumns
+		people.buildIndexes()
+		var i int
+		r.read = func() (interface{}, error) {
+			if i >= people.Len() {
+				return nil, io.EOF
+			}
+			i++
+			return people.Row(i - 1), nil
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unrecognized gob contents: %v", err)
+}
+
+// recordingReader keeps a copy of the bytes read from r while buf is
+// not nil.
+type recordingReader struct {
+	r   io.Reader
+	buf *bytes.Buffer
+}
+
+func (rr *recordingReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	if rr.buf != nil {
+		rr.buf.Write(p[:n])
+	}
+	return n, err
+}
+
+// numberedRow is a raw record from a row-oriented file.
+type numberedRow struct {
+
+	// The position of the row, counting from 1 after the header
+	n int
+
+	// The row, before it is split into strings
+	raw interface{}
+
+	// Converts the row to a Person
+	mapper *RowMapper
+}
+
+// stringRow is the split function for rows that are read as []string.
+func stringRow(v interface{}) ([]string, error) {
+	return v.([]string), nil
+}
+
+// rowSource sets up the reader to build Person values from the rows
+// returned by read, which are converted to strings by split.  The
+// first row holds the column labels, which are bound to fields using
+// the reader's schema.
+func (r *Reader) rowSource(read func() (interface{}, error), split func(interface{}) ([]string, error)) {
+
+	var mapper *RowMapper
+	var nr int
+
+	r.read = func() (interface{}, error) {
+
+		if mapper == nil {
+			raw, err := read()
+			if err != nil {
+				return nil, err
+			}
+			header, err := split(raw)
+			if err != nil {
+				return nil, err
+			}
+			schema := r.Schema
+			if schema == nil {
+				schema = DefaultSchema()
+			}
+			if mapper, err = schema.Bind(header); err != nil {
+				return nil, err
+			}
+			r.mapper = mapper
+		}
+
+		raw, err := read()
+		if err != nil {
+			return nil, err
+		}
+		nr++
+
+		return numberedRow{n: nr, raw: raw, mapper: mapper}, nil
+	}
+
+	r.parse = func(v interface{}) (Person, error) {
+
+		row := v.(numberedRow)
+		s, err := split(row.raw)
+		if err != nil {
+			return Person{}, fmt.Errorf("notable: row %d: %v", row.n, err)
+		}
+
+		person, err := row.mapper.Person(s)
+		if err != nil {
+			return Person{}, fmt.Errorf("notable: row %d: %v", row.n, err)
+		}
+
+		return person, nil
+	}
+}
+
+// isText returns true if b looks like the start of a text file.  A
+// multi-byte character may be cut off at the end of b.
+func isText(b []byte) bool {
+
+	for len(b) > 0 {
+		c, n := utf8.DecodeRune(b)
+		if c == utf8.RuneError && n == 1 && len(b) >= utf8.UTFMax {
+			return false
+		}
+		if c < ' ' && c != '\t' && c != '\r' && c != '\n' {
+			return false
+		}
+		b = b[n:]
+	}
+
+	return true
+}
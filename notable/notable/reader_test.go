@@ -0,0 +1,248 @@
+package notable
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writePeople writes the people to the named file, as a column file
+// if it has the .ncol extension and as a gob-encoded People value
+// otherwise.  The file does not exist yet, so IsColumnFile cannot
+// tell.
+func writePeople(t *testing.T, fname string, people *People) {
+
+	t.Helper()
+
+	if filepath.Ext(fname) == ".ncol" {
+		cw, err := NewColumnWriter(fname, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cw.WritePeople(people); err != nil {
+			t.Fatal(err)
+		}
+		if err := cw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	enc, err := NewGobEncoder(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(people); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadPeople(t *testing.T) {
+
+	want := samplePeople()
+
+	for _, name := range []string{"people.ncol", "people.gob.gz", "people.gob.zst"} {
+		fname := filepath.Join(t.TempDir(), name)
+		writePeople(t, fname, &want)
+
+		got, err := ReadPeople(fname)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got.Len() != want.Len() {
+			t.Errorf("%s: read %d people, want %d", name, got.Len(), want.Len())
+			continue
+		}
+		for i := 0; i < want.Len(); i++ {
+			if !reflect.DeepEqual(got.Row(i), want.Row(i)) {
+				t.Errorf("%s: row %d is %+v, want %+v", name, i, got.Row(i), want.Row(i))
+			}
+		}
+	}
+}
+
+// writeFormat writes the people to the named file in the given format.
+func writeFormat(t *testing.T, fname string, format Format, people *People) {
+
+	t.Helper()
+
+	var header []string
+	for _, f := range Fields() {
+		header = append(header, f.String())
+	}
+
+	switch format {
+	case CSVRows:
+		w, err := NewCSVWriter(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(header)
+		for i := 0; i < people.Len(); i++ {
+			p := people.Row(i)
+			w.Write(p.Strings())
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	case JSONRows, JSONStructs:
+		e, err := NewJSONEncoder(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format == JSONRows {
+			e.Encode(header)
+		}
+		for i := 0; i < people.Len(); i++ {
+			p := people.Row(i)
+			if format == JSONRows {
+				e.Encode(p.Strings())
+			} else {
+				e.Encode(p)
+			}
+		}
+		if err := e.Close(); err != nil {
+			t.Fatal(err)
+		}
+	case GobRows, GobStructs:
+		e, err := NewGobEncoder(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format == GobRows {
+			e.Encode(header)
+		}
+		for i := 0; i < people.Len(); i++ {
+			p := people.Row(i)
+			if format == GobRows {
+				e.Encode(p.Strings())
+			} else {
+				e.Encode(p)
+			}
+		}
+		if err := e.Close(); err != nil {
+			t.Fatal(err)
+		}
+	case GobColumns, ColumnGroups:
+		writePeople(t, fname, people)
+	default:
+		t.Fatalf("cannot write %s", format)
+	}
+}
+
+// The format of a file is found from its contents, whatever its name.
+func TestReaderFormats(t *testing.T) {
+
+	people := samplePeople()
+
+	cases := []struct {
+		name   string
+		format Format
+	}{
+		{"rows.csv", CSVRows},
+		{"rows.csv.gz", CSVRows},
+		{"rows.json.zst", JSONRows},
+		{"structs.json.sz", JSONStructs},
+		{"rows.gob.lz4", GobRows},
+		{"structs.gob.gz", GobStructs},
+		{"columns.gob.gz", GobColumns},
+		{"columns.ncol", ColumnGroups},
+		{"misnamed.json.gz", GobStructs},
+	}
+
+	for _, c := range cases {
+		fname := filepath.Join(t.TempDir(), c.name)
+		writeFormat(t, fname, c.format, &people)
+
+		rdr, err := NewReader(fname)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if rdr.Format() != c.format {
+			t.Errorf("%s: format %s, want %s", c.name, rdr.Format(), c.format)
+		}
+		var i int
+		for ; rdr.Next(); i++ {
+			if i < people.Len() && !reflect.DeepEqual(rdr.Person(), people.Row(i)) {
+				t.Errorf("%s: row %d is %+v, want %+v", c.name, i, rdr.Person(), people.Row(i))
+			}
+		}
+		if err := rdr.Err(); err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+		if i != people.Len() {
+			t.Errorf("%s: read %d records, want %d", c.name, i, people.Len())
+		}
+		rdr.Close()
+	}
+}
+
+// Files written before the location and gender columns were
+// dictionary-encoded can still be read.
+func TestReadLegacyPeople(t *testing.T) {
+
+	want := samplePeople()
+	old := legacyPeople{
+		PrsLabel:  want.PrsLabel,
+		BYear:     want.BYear,
+		BLocLabel: want.BLocLabel.Strings(),
+		BLocLat:   want.BLocLat,
+		BLocLong:  want.BLocLong,
+		DYear:     want.DYear,
+		DLocLabel: want.DLocLabel.Strings(),
+		DLocLat:   want.DLocLat,
+		DLocLong:  want.DLocLong,
+		Gender:    want.Gender.Strings(),
+		Valid:     want.Valid,
+	}
+
+	fname := filepath.Join(t.TempDir(), "people.gob.gz")
+	enc, err := NewGobEncoder(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(&old); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rdr, err := NewReader(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdr.Close()
+	if rdr.Format() != GobColumns {
+		t.Errorf("format %s, want %s", rdr.Format(), GobColumns)
+	}
+	var i int
+	for ; rdr.Next(); i++ {
+		if i < want.Len() && !reflect.DeepEqual(rdr.Person(), want.Row(i)) {
+			t.Errorf("row %d is %+v, want %+v", i, rdr.Person(), want.Row(i))
+		}
+	}
+	if err := rdr.Err(); err != nil || i != want.Len() {
+		t.Errorf("read %d records, want %d: %v", i, want.Len(), err)
+	}
+}
+
+// A file whose header disagrees with its contents is reported.
+func TestReaderHeaderMismatch(t *testing.T) {
+
+	people := samplePeople()
+	fname := filepath.Join(t.TempDir(), "structs.gob.gz")
+	writeFormat(t, fname, GobStructs, &people)
+
+	if err := ExpectFormat(fname, GobStructs); err != nil {
+		t.Error(err)
+	}
+	if err := ExpectFormat(fname, GobRows); err == nil {
+		t.Errorf("no error for the wrong format")
+	}
+}
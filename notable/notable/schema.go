@@ -0,0 +1,334 @@
+package notable
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// A Field identifies one of the attributes of a Person.
+type Field int
+
+const (
+	FieldPrsLabel Field = iota
+	FieldBYear
+	FieldBLocLabel
+	FieldBLocLat
+	FieldBLocLong
+	FieldDYear
+	FieldDLocLabel
+	FieldDLocLat
+	FieldDLocLong
+	FieldGender
+
+	// The number of fields
+	numFields
+)
+
+// fieldNames holds the name of each field, which is also the name of
+// the corresponding column in the Data S1 sheet.
+var fieldNames = [numFields]string{
+	"PrsLabel",
+	"BYear",
+	"BLocLabel",
+	"BLocLat",
+	"BLocLong",
+	"DYear",
+	"DLocLabel",
+	"DLocLat",
+	"DLocLong",
+	"Gender",
+}
+
+// Fields returns all the fields of a Person, in the order they are
+// declared.
+func Fields() []Field {
+	f := make([]Field, numFields)
+	for i := range f {
+		f[i] = Field(i)
+	}
+	return f
+}
+
+// String returns the name of the field.
+func (f Field) String() string {
+	if f < 0 || f >= numFields {
+		return fmt.Sprintf("Field(%d)", int(f))
+	}
+	return fieldNames[f]
+}
+
+// A Schema describes how the columns of a row-oriented file, such as
+// the Data S1 sheet, correspond to the fields of a Person.  Columns
+// are located by their label in the header row, so the columns can
+// appear in any order and unrelated columns are ignored.
+type Schema struct {
+
+	// Aliases holds additional column labels that are accepted for
+	// each field.  The name of the field itself is always accepted.
+	// Labels are matched without regard to case or surrounding
+	// white space.
+	Aliases map[Field][]string
+
+	// Optional lists the fields that may be absent from the header.
+	// All other fields are required.  Fields without a column are
+	// left at their zero value.
+	Optional []Field
+
+	// NAValues holds the strings that denote a missing value in a
+	// nullable field, matched after trimming white space.  If nil,
+	// only empty strings denote missing values.
+	NAValues []string
+
+	// Strict makes any other value of a nullable field that cannot be
+	// parsed an error.  Otherwise such a value is treated as missing,
+	// so that it is subject to the MissingPolicy, and is counted (see
+	// RowMapper.Invalid).
+	Strict bool
+}
+
+// DefaultSchema returns the schema used for the Data S1 sheet when no
+// other schema is provided.
+func DefaultSchema() *Schema {
+	return &Schema{
+		Aliases: map[Field][]string{
+			FieldPrsLabel:  {"Name", "Person"},
+			FieldBYear:     {"BirthYear"},
+			FieldBLocLabel: {"BirthLocation", "BirthPlace"},
+			FieldBLocLat:   {"BirthLat", "BirthLatitude"},
+			FieldBLocLong:  {"BLocLon", "BirthLong", "BirthLongitude"},
+			FieldDYear:     {"DeathYear"},
+			FieldDLocLabel: {"DeathLocation", "DeathPlace"},
+			FieldDLocLat:   {"DeathLat", "DeathLatitude"},
+			FieldDLocLong:  {"DLocLon", "DeathLong", "DeathLongitude"},
+			FieldGender:    {"Sex"},
+		},
+		NAValues: []string{"", "NA", "NaN", "null"},
+	}
+}
+
+// normLabel puts a column label into the form used for matching.
+func normLabel(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// Bind locates the column holding each field in the given header row.
+// It returns an error naming every required field that has no column,
+// or any field that matches more than one column.
+func (s *Schema) Bind(header []string) (*RowMapper, error) {
+
+	// Map from normalized label to field
+	labels := make(map[string]Field)
+	for _, f := range Fields() {
+		labels[normLabel(f.String())] = f
+		for _, a := range s.Aliases[f] {
+			labels[normLabel(a)] = f
+		}
+	}
+
+	m := &RowMapper{na: make(map[string]bool), strict: s.Strict}
+	if s.NAValues == nil {
+		m.na[""] = true
+	}
+	for _, v := range s.NAValues {
+		m.na[strings.TrimSpace(v)] = true
+	}
+
+	for i := range m.cols {
+		m.cols[i] = -1
+	}
+
+	for j, h := range header {
+		f, ok := labels[normLabel(h)]
+		if !ok {
+			continue
+		}
+		if m.cols[f] != -1 {
+			return nil, fmt.Errorf("notable: columns %q and %q both match field %s",
+				header[m.cols[f]], h, f)
+		}
+		m.cols[f] = j
+	}
+
+	optional := make(map[Field]bool)
+	for _, f := range s.Optional {
+		optional[f] = true
+	}
+
+	var missing []string
+	for _, f := range Fields() {
+		if m.cols[f] == -1 && !optional[f] {
+			missing = append(missing, f.String())
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("notable: header has no column for required field(s) %s",
+			strings.Join(missing, ", "))
+	}
+
+	return m, nil
+}
+
+// A RowMapper converts rows of strings to Person values, using the
+// column positions found by Schema.Bind.
+type RowMapper struct {
+
+	// The column holding each field, or -1 if the field is absent
+	cols [numFields]int
+
+	// The strings that denote missing values
+	na map[string]bool
+
+	// If false, values that cannot be parsed are missing
+	strict bool
+
+	// The number of values of each field that could not be parsed
+	// and were treated as missing.  The counts are updated
+	// atomically, since rows may be converted by several goroutines.
+	invalid [numFields]int64
+}
+
+// Index returns the position of the column holding the given field,
+// or -1 if the field has no column.
+func (m *RowMapper) Index(f Field) int {
+	return m.cols[f]
+}
+
+// Invalid returns the number of values of the given field that could
+// not be parsed, and were treated as missing, in the rows converted so
+// far.
+func (m *RowMapper) Invalid(f Field) int {
+	return int(atomic.LoadInt64(&m.invalid[f]))
+}
+
+// Person creates a Person from one row of data.  A value of a nullable
+// field that cannot be parsed is missing, unless the schema is strict.
+// It may be called from several goroutines at once.
+func (m *RowMapper) Person(row []string) (Person, error) {
+
+	var person Person
+
+	for _, f := range Fields() {
+
+		j := m.cols[f]
+		if j == -1 {
+			continue
+		}
+		if j >= len(row) {
+			return Person{}, fmt.Errorf("row has %d columns, %s is in column %d", len(row), f, j+1)
+		}
+
+		if f.Nullable() && m.na[strings.TrimSpace(row[j])] {
+			person.SetNA(f)
+			continue
+		}
+
+		if err := person.set(f, row[j]); err != nil {
+			if m.strict || !f.Nullable() {
+				return Person{}, err
+			}
+			atomic.AddInt64(&m.invalid[f], 1)
+			person.SetNA(f)
+		}
+	}
+
+	return person, nil
+}
+
+// set parses s and stores it in the given field of the person.
+func (p *Person) set(f Field, s string) error {
+
+	var err error
+	switch f {
+	case FieldPrsLabel:
+		p.PrsLabel = s
+	case FieldBYear:
+		p.BYear, err = parseYear(s)
+	case FieldBLocLabel:
+		p.BLocLabel = s
+	case FieldBLocLat:
+		p.BLocLat, err = strconv.ParseFloat(s, 64)
+	case FieldBLocLong:
+		p.BLocLong, err = strconv.ParseFloat(s, 64)
+	case FieldDYear:
+		p.DYear, err = parseYear(s)
+	case FieldDLocLabel:
+		p.DLocLabel = s
+	case FieldDLocLat:
+		p.DLocLat, err = strconv.ParseFloat(s, 64)
+	case FieldDLocLong:
+		p.DLocLong, err = strconv.ParseFloat(s, 64)
+	case FieldGender:
+		p.Gender = s
+	}
+
+	if err != nil {
+		return fmt.Errorf("field %s: %v", f, err)
+	}
+
+	return nil
+}
+
+// parseYear parses a year, which may be written as a whole number with
+// a fractional part of zero, such as "1815.0".
+func parseYear(s string) (int, error) {
+
+	s = strings.TrimSpace(s)
+	y, err := strconv.Atoi(s)
+	if err == nil {
+		return y, nil
+	}
+
+	x, ferr := strconv.ParseFloat(s, 64)
+	if ferr != nil || x != math.Trunc(x) || math.Abs(x) > math.MaxInt32 {
+		return 0, err
+	}
+
+	return int(x), nil
+}
+
+// Strings returns the fields of the person as strings, in the order
+// given by Fields.  Missing values are empty strings.  The result can
+// be converted back to a Person with a RowMapper bound to the names
+// of the fields.
+func (p *Person) Strings() []string {
+
+	row := make([]string, numFields)
+	for _, f := range Fields() {
+		if !p.IsNA(f) {
+			row[f] = p.get(f)
+		}
+	}
+
+	return row
+}
+
+// get returns the given field of the person as a string.
+func (p *Person) get(f Field) string {
+	switch f {
+	case FieldPrsLabel:
+		return p.PrsLabel
+	case FieldBYear:
+		return strconv.Itoa(p.BYear)
+	case FieldBLocLabel:
+		return p.BLocLabel
+	case FieldBLocLat:
+		return strconv.FormatFloat(p.BLocLat, 'g', -1, 64)
+	case FieldBLocLong:
+		return strconv.FormatFloat(p.BLocLong, 'g', -1, 64)
+	case FieldDYear:
+		return strconv.Itoa(p.DYear)
+	case FieldDLocLabel:
+		return p.DLocLabel
+	case FieldDLocLat:
+		return strconv.FormatFloat(p.DLocLat, 'g', -1, 64)
+	case FieldDLocLong:
+		return strconv.FormatFloat(p.DLocLong, 'g', -1, 64)
+	case FieldGender:
+		return p.Gender
+	default:
+		panic(fmt.Sprintf("notable: unknown field %v", f))
+	}
+}
@@ -0,0 +1,142 @@
+package notable
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSchemaBind(t *testing.T) {
+
+	all := []string{"PrsLabel", "BYear", "BLocLabel", "BLocLat", "BLocLong",
+		"DYear", "DLocLabel", "DLocLat", "DLocLong", "Gender"}
+
+	cases := []struct {
+		name   string
+		header []string
+		schema *Schema
+		col    map[Field]int
+		err    string
+	}{
+		{"names", all, DefaultSchema(),
+			map[Field]int{FieldPrsLabel: 0, FieldGender: 9}, ""},
+		{"aliases in any order", []string{"Sex", "other", " name ", "BirthYear", "BirthPlace",
+			"BirthLat", "BLocLon", "DeathYear", "DeathPlace", "DeathLat", "DLocLon"}, DefaultSchema(),
+			map[Field]int{FieldGender: 0, FieldPrsLabel: 2, FieldBYear: 3, FieldDLocLong: 10}, ""},
+		{"missing required", all[0:8], DefaultSchema(), nil, "DLocLong, Gender"},
+		{"missing optional", all[0:9], &Schema{Optional: []Field{FieldGender}},
+			map[Field]int{FieldGender: -1, FieldDLocLong: 8}, ""},
+		{"duplicate", append([]string{"Name"}, all...), DefaultSchema(), nil, "both match field PrsLabel"},
+	}
+
+	for _, c := range cases {
+		m, err := c.schema.Bind(c.header)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: got error %v, want one containing %q", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		for f, j := range c.col {
+			if got := m.Index(f); got != j {
+				t.Errorf("%s: %s is in column %d, want %d", c.name, f, got, j)
+			}
+		}
+	}
+}
+
+func TestRowMapperPerson(t *testing.T) {
+
+	header := []string{"PrsLabel", "BYear", "BLocLabel", "BLocLat", "BLocLong",
+		"DYear", "DLocLabel", "DLocLat", "DLocLong", "Gender"}
+
+	cases := []struct {
+		name    string
+		row     []string
+		strict  bool
+		na      []Field
+		byear   int
+		invalid int
+		err     bool
+	}{
+		{"complete", []string{"Ada", "1815", "London", "51.5", "-0.1", "1852", "London", "51.5", "-0.1", "female"},
+			false, nil, 1815, 0, false},
+		{"NA strings", []string{"Ada", " NA ", "London", "", "null", "NaN", "London", "51.5", "-0.1", "female"},
+			false, []Field{FieldBYear, FieldBLocLat, FieldBLocLong, FieldDYear}, 0, 0, false},
+		{"whole float year", []string{"Ada", "1815.0", "London", "51.5", "-0.1", "1852", "London", "51.5", "-0.1", "female"},
+			false, nil, 1815, 0, false},
+		{"invalid year", []string{"Ada", "c. 1815", "London", "51.5", "-0.1", "1852", "London", "51.5", "-0.1", "female"},
+			false, []Field{FieldBYear}, 0, 1, false},
+		{"fractional year", []string{"Ada", "1815.5", "London", "51.5", "-0.1", "1852", "London", "51.5", "-0.1", "female"},
+			false, []Field{FieldBYear}, 0, 1, false},
+		{"strict", []string{"Ada", "c. 1815", "London", "51.5", "-0.1", "1852", "London", "51.5", "-0.1", "female"},
+			true, nil, 0, 0, true},
+		{"short row", []string{"Ada", "1815"}, false, nil, 0, 0, true},
+	}
+
+	for _, c := range cases {
+		s := DefaultSchema()
+		s.Strict = c.strict
+		m, err := s.Bind(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := m.Person(c.row)
+		if c.err {
+			if err == nil {
+				t.Errorf("%s: no error", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+
+		var na []Field
+		for _, f := range Fields() {
+			if p.IsNA(f) {
+				na = append(na, f)
+			}
+		}
+		if !reflect.DeepEqual(na, c.na) {
+			t.Errorf("%s: missing fields %v, want %v", c.name, na, c.na)
+		}
+		if p.BYear != c.byear {
+			t.Errorf("%s: birth year %d, want %d", c.name, p.BYear, c.byear)
+		}
+		if got := m.Invalid(FieldBYear); got != c.invalid {
+			t.Errorf("%s: %d invalid birth years, want %d", c.name, got, c.invalid)
+		}
+	}
+}
+
+// Strings and a RowMapper bound to the field names are inverses.
+func TestPersonStrings(t *testing.T) {
+
+	var header []string
+	for _, f := range Fields() {
+		header = append(header, f.String())
+	}
+	m, err := DefaultSchema().Bind(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	people := samplePeople()
+	for i := 0; i < people.Len(); i++ {
+		want := people.Row(i)
+		got, err := m.Person(want.Strings())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("row %d: got %+v, want %+v", i, got, want)
+		}
+	}
+}
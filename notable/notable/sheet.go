@@ -0,0 +1,488 @@
+package notable
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Excel workbooks
+//
+// An xlsx workbook is a zip archive of XML documents.  Each sheet is a
+// separate document, and the strings in the cells of all sheets are
+// usually held in a shared table.  A SheetReader decodes the XML of
+// one sheet as a stream, so that only the shared strings and the
+// current row are held in memory, however large the sheet is.
+
+// SheetReader reads the rows of one sheet of an Excel (xlsx) workbook,
+// one at a time, in the manner of csv.Reader.
+type SheetReader struct {
+
+	// The workbook archive
+	zr *zip.ReadCloser
+
+	// The XML document of the sheet
+	rc io.ReadCloser
+
+	// Decodes the sheet
+	dec *xml.Decoder
+
+	// The shared strings table
+	shared []string
+
+	// The number of rows and columns given in the sheet's dimension,
+	// or 0 if not known
+	nrow, ncol int
+
+	// The number of the last row returned, counting from 1
+	rownum int
+
+	// A row that has been decoded but not yet returned, because
+	// empty rows precede it
+	ahead []string
+
+	// The number of the row in ahead
+	aheadNum int
+}
+
+// workbookXML holds the parts of xl/workbook.xml that are needed.
+type workbookXML struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+// relsXML holds the relationships of xl/workbook.xml, which give the
+// location of each sheet in the archive.
+type relsXML struct {
+	Rels []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// SheetNames returns the names of the sheets in the named workbook,
+// in order.
+func SheetNames(fname string) ([]string, error) {
+
+	zr, err := zip.OpenReader(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var wb workbookXML
+	if err := decodeZipXML(&zr.Reader, "xl/workbook.xml", &wb); err != nil {
+		return nil, fmt.Errorf("notable: %s: %v", fname, err)
+	}
+
+	var names []string
+	for _, s := range wb.Sheets {
+		names = append(names, s.Name)
+	}
+
+	return names, nil
+}
+
+// OpenSheet opens one sheet of the named workbook for reading.  The
+// sheet is given by its name or, if no sheet has that name, by its
+// position in the workbook, counting from 0.
+func OpenSheet(fname, sheet string) (*SheetReader, error) {
+
+	zr, err := zip.OpenReader(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := openSheet(zr, sheet)
+	if err != nil {
+		zr.Close()
+		return nil, fmt.Errorf("notable: %s: %v", fname, err)
+	}
+
+	return s, nil
+}
+
+// openSheet locates the sheet in the archive, reads the shared
+// strings, and positions the decoder at the start of the sheet data.
+func openSheet(zr *zip.ReadCloser, sheet string) (*SheetReader, error) {
+
+	var wb workbookXML
+	if err := decodeZipXML(&zr.Reader, "xl/workbook.xml", &wb); err != nil {
+		return nil, err
+	}
+
+	// Find the sheet by name, then by position
+	ix := -1
+	for i, s := range wb.Sheets {
+		if s.Name == sheet {
+			ix = i
+			break
+		}
+	}
+	if ix == -1 {
+		if i, err := strconv.Atoi(sheet); err == nil && i >= 0 && i < len(wb.Sheets) {
+			ix = i
+		}
+	}
+	if ix == -1 {
+		return nil, fmt.Errorf("no sheet named %q", sheet)
+	}
+
+	var rels relsXML
+	if err := decodeZipXML(&zr.Reader, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	var target string
+	for _, r := range rels.Rels {
+		if r.ID == wb.Sheets[ix].RID {
+			target = r.Target
+		}
+	}
+	if target == "" {
+		return nil, fmt.Errorf("sheet %q has no document", wb.Sheets[ix].Name)
+	}
+	if strings.HasPrefix(target, "/") {
+		target = target[1:]
+	} else {
+		target = path.Join("xl", target)
+	}
+
+	s := &SheetReader{zr: zr}
+
+	// A workbook without shared strings keeps its strings in the cells
+	if f := findZipFile(&zr.Reader, "xl/sharedStrings.xml"); f != nil {
+		var err error
+		if s.shared, err = readSharedStrings(f); err != nil {
+			return nil, err
+		}
+	}
+
+	f := findZipFile(&zr.Reader, target)
+	if f == nil {
+		return nil, fmt.Errorf("archive has no file %s", target)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	s.rc = rc
+	s.dec = xml.NewDecoder(rc)
+
+	// Read up to the rows, noting the dimension of the sheet
+	for {
+		tok, err := s.dec.Token()
+		if err != nil {
+			rc.Close()
+			return nil, err
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			switch se.Name.Local {
+			case "dimension":
+				s.nrow, s.ncol = parseDimension(xmlAttr(se, "ref"))
+			case "sheetData":
+				return s, nil
+			}
+		}
+	}
+}
+
+// Dims returns the number of rows and columns of the sheet, as
+// recorded in the workbook, or zeros if the workbook does not record
+// them.
+func (s *SheetReader) Dims() (int, int) {
+	return s.nrow, s.ncol
+}
+
+// Read returns the next row of the sheet, or io.EOF when there are no
+// more.  Each row is a new slice, holding at least as many values as
+// the sheet has columns.  Empty cells and rows hold empty strings.
+func (s *SheetReader) Read() ([]string, error) {
+
+	if s.ahead == nil {
+		row, num, err := s.nextRow()
+		if err == io.EOF {
+			return nil, err
+		} else if err != nil {
+			return nil, fmt.Errorf("notable: %v", err)
+		}
+		s.ahead, s.aheadNum = row, num
+	}
+
+	// Rows with no cells are left out of the document
+	s.rownum++
+	if s.aheadNum > s.rownum {
+		return make([]string, s.ncol), nil
+	}
+
+	row := s.ahead
+	s.ahead = nil
+
+	return row, nil
+}
+
+// Close closes the workbook.
+func (s *SheetReader) Close() error {
+
+	err := s.rc.Close()
+
+	if cerr := s.zr.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// nextRow decodes the next row element, returning its cells and its
+// row number.
+func (s *SheetReader) nextRow() ([]string, int, error) {
+
+	// Find the start of the row
+	var start xml.StartElement
+	for {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return nil, 0, err
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "row" {
+			start = se
+			break
+		}
+		if ee, ok := tok.(xml.EndElement); ok && ee.Name.Local == "sheetData" {
+			return nil, 0, io.EOF
+		}
+	}
+
+	num := s.rownum + 1
+	if r := xmlAttr(start, "r"); r != "" {
+		n, err := strconv.Atoi(r)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid row number %q", r)
+		}
+		num = n
+	}
+
+	row := make([]string, s.ncol)
+	col := 0
+	for {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return nil, 0, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "c" {
+				continue
+			}
+			if ref := xmlAttr(t, "r"); ref != "" {
+				if col, _, err = parseCellRef(ref); err != nil {
+					return nil, 0, fmt.Errorf("row %d: %v", num, err)
+				}
+			}
+			v, err := s.cellValue(t)
+			if err != nil {
+				return nil, 0, fmt.Errorf("row %d: %v", num, err)
+			}
+			for len(row) <= col {
+				row = append(row, "")
+			}
+			row[col] = v
+			col++
+		case xml.EndElement:
+			if t.Name.Local == "row" {
+				return row, num, nil
+			}
+		}
+	}
+}
+
+// cellValue decodes the contents of a c element, returning the value
+// of the cell as a string.
+func (s *SheetReader) cellValue(start xml.StartElement) (string, error) {
+
+	var v, inline strings.Builder
+	var inV, inT bool
+	for {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			inV = t.Name.Local == "v"
+			inT = t.Name.Local == "t"
+		case xml.EndElement:
+			inV, inT = false, false
+			if t.Name.Local == "c" {
+				return s.formatCell(xmlAttr(start, "t"), v.String(), inline.String())
+			}
+		case xml.CharData:
+			if inV {
+				v.Write(t)
+			} else if inT {
+				inline.Write(t)
+			}
+		}
+	}
+}
+
+// formatCell returns the value of a cell of the given type, from the
+// contents of its v element or its inline string.
+func (s *SheetReader) formatCell(typ, v, inline string) (string, error) {
+
+	switch typ {
+	case "s":
+		i, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || i < 0 || i >= len(s.shared) {
+			return "", fmt.Errorf("invalid shared string index %q", v)
+		}
+		return s.shared[i], nil
+	case "inlineStr":
+		return inline, nil
+	default:
+		// Numbers, formula strings, booleans and errors are kept as
+		// stored, as the xlsx package does, so that years are not
+		// rewritten
+		return v, nil
+	}
+}
+
+// readSharedStrings reads the shared strings table of a workbook.  A
+// string with rich text formatting is split into runs, whose text is
+// joined; phonetic guides are skipped.
+func readSharedStrings(f *zip.File) ([]string, error) {
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var shared []string
+	var cur strings.Builder
+	var inT, inPhonetic bool
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return shared, nil
+		} else if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				cur.Reset()
+			case "t":
+				inT = true
+			case "rPh":
+				inPhonetic = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				shared = append(shared, cur.String())
+			case "t":
+				inT = false
+			case "rPh":
+				inPhonetic = false
+			}
+		case xml.CharData:
+			if inT && !inPhonetic {
+				cur.Write(t)
+			}
+		}
+	}
+}
+
+// findZipFile returns the named file of the archive, or nil.
+func findZipFile(zr *zip.Reader, name string) *zip.File {
+	for _, f := range zr.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// decodeZipXML decodes the named XML document of the archive into v.
+func decodeZipXML(zr *zip.Reader, name string, v interface{}) error {
+
+	f := findZipFile(zr, name)
+	if f == nil {
+		return fmt.Errorf("archive has no file %s", name)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+// xmlAttr returns the value of the named attribute, or "".
+func xmlAttr(se xml.StartElement, name string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// maxSheetCols is the number of columns in a worksheet, the last
+// being XFD.
+const maxSheetCols = 16384
+
+// parseCellRef returns the column and row, counting from 0, of a cell
+// reference such as "AB12".
+func parseCellRef(ref string) (int, int, error) {
+
+	col, i := 0, 0
+	for ; i < len(ref); i++ {
+		c := ref[i]
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		col = 26*col + int(c-'A'+1)
+		if col > maxSheetCols {
+			return 0, 0, fmt.Errorf("cell reference %q is past the last column", ref)
+		}
+	}
+
+	row, err := strconv.Atoi(ref[i:])
+	if i == 0 || err != nil || row < 1 {
+		return 0, 0, fmt.Errorf("invalid cell reference %q", ref)
+	}
+
+	return col - 1, row - 1, nil
+}
+
+// parseDimension returns the number of rows and columns spanned by a
+// range such as "A1:M5001", or zeros if the range cannot be parsed.
+func parseDimension(ref string) (int, int) {
+
+	parts := strings.Split(ref, ":")
+	if len(parts) != 2 {
+		return 0, 0
+	}
+
+	c0, r0, err0 := parseCellRef(parts[0])
+	c1, r1, err1 := parseCellRef(parts[1])
+	if err0 != nil || err1 != nil || c1 < c0 || r1 < r0 {
+		return 0, 0
+	}
+
+	return r1 + 1, c1 + 1
+}
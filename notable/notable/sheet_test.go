@@ -0,0 +1,158 @@
+package notable
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeWorkbook creates an xlsx workbook holding one sheet, named
+// "Data", with the given sheetData contents, and returns its name.
+func writeWorkbook(t *testing.T, dim, rows string) string {
+
+	t.Helper()
+
+	files := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Data" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships>
+<Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst><si><t>PrsLabel</t></si><si><t>BYear</t></si>` +
+			`<si><r><t>Ada </t></r><r><t>Lovelace</t></r><rPh><t>x</t></rPh></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><dimension ref="` + dim + `"/><sheetData>` +
+			rows + `</sheetData></worksheet>`,
+	}
+
+	fname := filepath.Join(t.TempDir(), "test.xlsx")
+	f, err := os.Create(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return fname
+}
+
+// readSheet returns all the rows of the Data sheet, and the error that
+// ended the reading if it is not io.EOF.
+func readSheet(t *testing.T, fname string) ([][]string, error) {
+
+	t.Helper()
+
+	s, err := OpenSheet(fname, "Data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var rows [][]string
+	for {
+		row, err := s.Read()
+		if err == io.EOF {
+			return rows, nil
+		} else if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestSheetReader(t *testing.T) {
+
+	cases := []struct {
+		name string
+		dim  string
+		rows string
+		want [][]string
+		err  string
+	}{
+		{"shared and inline strings", "A1:B2",
+			`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row>` +
+				`<row r="2"><c r="A2" t="s"><v>2</v></c><c r="B2" t="inlineStr"><is><t>x</t></is></c></row>`,
+			[][]string{{"PrsLabel", "BYear"}, {"Ada Lovelace", "x"}}, ""},
+		{"numbers kept as stored", "A1:C1",
+			`<row r="1"><c r="A1"><v>1815</v></c><c r="B1" t="n"><v>51.507400000000004</v></c>` +
+				`<c r="C1"><v>1.8E3</v></c></row>`,
+			[][]string{{"1815", "51.507400000000004", "1.8E3"}}, ""},
+		{"empty rows and cells", "A1:C3",
+			`<row r="1"><c r="A1"><v>1</v></c></row><row r="3"><c r="C3"><v>3</v></c></row>`,
+			[][]string{{"1", "", ""}, {"", "", ""}, {"", "", "3"}}, ""},
+		{"no cell references", "A1:B1",
+			`<row><c><v>1</v></c><c><v>2</v></c></row>`,
+			[][]string{{"1", "2"}}, ""},
+		{"no dimension", "",
+			`<row r="1"><c r="B1"><v>2</v></c></row>`,
+			[][]string{{"", "2"}}, ""},
+		{"reference without a column", "A1:B1",
+			`<row r="1"><c r="1"><v>1</v></c></row>`, nil, `invalid cell reference "1"`},
+		{"reference without a row", "A1:B1",
+			`<row r="1"><c r="B"><v>1</v></c></row>`, nil, `invalid cell reference "B"`},
+		{"reference past the last column", "A1:B1",
+			`<row r="1"><c r="ZZZZ1"><v>1</v></c></row>`, nil, "past the last column"},
+		{"bad shared string", "A1:A1",
+			`<row r="1"><c r="A1" t="s"><v>9</v></c></row>`, nil, "invalid shared string index"},
+	}
+
+	for _, c := range cases {
+		rows, err := readSheet(t, writeWorkbook(t, c.dim, c.rows))
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: got error %v, want one containing %q", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(rows, c.want) {
+			t.Errorf("%s: got %q, want %q", c.name, rows, c.want)
+		}
+	}
+}
+
+func TestParseCellRef(t *testing.T) {
+
+	cases := []struct {
+		ref      string
+		col, row int
+		ok       bool
+	}{
+		{"A1", 0, 0, true},
+		{"z10", 25, 9, true},
+		{"AB12", 27, 11, true},
+		{"XFD1048576", 16383, 1048575, true},
+		{"XFE1", 0, 0, false},
+		{"", 0, 0, false},
+		{"A", 0, 0, false},
+		{"12", 0, 0, false},
+		{"A0", 0, 0, false},
+		{"A-1", 0, 0, false},
+	}
+
+	for _, c := range cases {
+		col, row, err := parseCellRef(c.ref)
+		if (err == nil) != c.ok || col != c.col || row != c.row {
+			t.Errorf("parseCellRef(%q) = %d, %d, %v", c.ref, col, row, err)
+		}
+	}
+}
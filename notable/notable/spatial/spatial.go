@@ -0,0 +1,255 @@
+// Package spatial indexes the birth or death locations of notable
+// people by their coordinates, so that the people born or died in a
+// region can be found without examining every person.
+//
+// The index is a quadtree (from github.com/paulmach/orb) holding one
+// entry for each distinct point, since many people share the
+// coordinates of a city.  Queries return row numbers of the indexed
+// People collection.  Distances are great-circle distances in km.
+//
+// For example, to find the notable people who died within 50 km of
+// Rome:
+//
+//	ix, err := spatial.New(people, spatial.Death)
+//	...
+//	rows := ix.Within(41.9, 12.5, 50)
+//	romans := people.Take(rows)
+package spatial
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/quadtree"
+)
+
+// An Event selects the birth or death locations.
+type Event int
+
+const (
+	Birth Event = iota
+	Death
+)
+
+// String returns the name of the event.
+func (e Event) String() string {
+	switch e {
+	case Birth:
+		return "birth"
+	case Death:
+		return "death"
+	default:
+		return fmt.Sprintf("Event(%d)", int(e))
+	}
+}
+
+// ParseEvent returns the event with the given name ("birth" or
+// "death").
+func ParseEvent(name string) (Event, error) {
+	switch name {
+	case "birth":
+		return Birth, nil
+	case "death":
+		return Death, nil
+	default:
+		return Birth, fmt.Errorf("spatial: unknown event %q", name)
+	}
+}
+
+// world is the range of longitude and latitude.
+var world = orb.Bound{Min: orb.Point{-180, -90}, Max: orb.Point{180, 90}}
+
+// halfCircumference is the greatest distance between two points on
+// the earth, in km.
+const halfCircumference = 20038
+
+// site holds the rows of the people at one point.  It implements
+// orb.Pointer so that it can be stored in the quadtree.
+type site struct {
+
+	// The location, as (longitude, latitude)
+	pt orb.Point
+
+	// The rows at this location, in increasing order
+	rows []int
+}
+
+// Point returns the location of the site.
+func (s *site) Point() orb.Point {
+	return s.pt
+}
+
+// Index is a spatial index of the birth or death locations of a
+// collection of people.
+type Index struct {
+
+	// Holds the sites
+	qt *quadtree.Quadtree
+
+	// The number of rows indexed
+	n int
+}
+
+// Neighbor is a row returned by Index.Nearest.
+type Neighbor struct {
+
+	// The row of the person
+	Row int
+
+	// The distance in km from the query point
+	Km float64
+}
+
+// New returns an index of the birth or death locations of people.
+// People whose latitude or longitude is missing are not indexed.
+func New(people *notable.People, event Event) (*Index, error) {
+
+	lat, long := people.BLocLat, people.BLocLong
+	latf, longf := notable.FieldBLocLat, notable.FieldBLocLong
+	if event == Death {
+		lat, long = people.DLocLat, people.DLocLong
+		latf, longf = notable.FieldDLocLat, notable.FieldDLocLong
+	}
+
+	// Group the rows by point
+	sites := make(map[orb.Point]*site)
+	var order []*site
+	ix := &Index{qt: quadtree.New(world)}
+	for i := 0; i < people.Len(); i++ {
+
+		if people.IsNA(latf, i) || people.IsNA(longf, i) {
+			continue
+		}
+
+		pt := orb.Point{long[i], lat[i]}
+		if !world.Contains(pt) {
+			return nil, fmt.Errorf("spatial: row %d has invalid %s coordinates (%g, %g)",
+				i, event, lat[i], long[i])
+		}
+
+		s, ok := sites[pt]
+		if !ok {
+			s = &site{pt: pt}
+			sites[pt] = s
+			order = append(order, s)
+		}
+		s.rows = append(s.rows, i)
+		ix.n++
+	}
+
+	for _, s := range order {
+		if err := ix.qt.Add(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return ix, nil
+}
+
+// Len returns the number of rows in the index.
+func (ix *Index) Len() int {
+	return ix.n
+}
+
+// Box returns the rows whose location lies in the given range of
+// latitude and longitude, in increasing order.  If minLong is greater
+// than maxLong, the box crosses the 180th meridian.
+func (ix *Index) Box(minLat, minLong, maxLat, maxLong float64) []int {
+
+	b := orb.Bound{Min: orb.Point{minLong, minLat}, Max: orb.Point{maxLong, maxLat}}
+
+	var rows []int
+	for _, s := range ix.inBound(b) {
+		rows = append(rows, s.rows...)
+	}
+	sort.Ints(rows)
+
+	return rows
+}
+
+// Within returns the rows whose location is within the given
+// distance in km of the point (lat, long), in increasing order.
+func (ix *Index) Within(lat, long, km float64) []int {
+
+	center := orb.Point{long, lat}
+
+	var rows []int
+	for _, s := range ix.inBound(geo.NewBoundAroundPoint(center, km*1000)) {
+		if geo.DistanceHaversine(center, s.pt)/1000 <= km {
+			rows = append(rows, s.rows...)
+		}
+	}
+	sort.Ints(rows)
+
+	return rows
+}
+
+// Nearest returns the k rows whose location is nearest to the point
+// (lat, long), ordered by distance.  Rows at the same distance are
+// ordered by row number.  Fewer than k rows are returned only if the
+// index holds fewer than k rows.
+func (ix *Index) Nearest(lat, long float64, k int) []Neighbor {
+
+	if k > ix.n {
+		k = ix.n
+	}
+	if k <= 0 {
+		return nil
+	}
+
+	// Search ever larger circles until one holds at least k rows.
+	// Every row that is not in the circle is farther away than
+	// every row that is.
+	center := orb.Point{long, lat}
+	for km := 100.0; ; km *= 2 {
+
+		var nb []Neighbor
+		for _, s := range ix.inBound(geo.NewBoundAroundPoint(center, km*1000)) {
+			if d := geo.DistanceHaversine(center, s.pt) / 1000; d <= km {
+				for _, i := range s.rows {
+					nb = append(nb, Neighbor{Row: i, Km: d})
+				}
+			}
+		}
+
+		if len(nb) >= k || km > halfCircumference {
+			sort.Slice(nb, func(i, j int) bool {
+				if nb[i].Km != nb[j].Km {
+					return nb[i].Km < nb[j].Km
+				}
+				return nb[i].Row < nb[j].Row
+			})
+			if len(nb) > k {
+				nb = nb[0:k]
+			}
+			return nb
+		}
+	}
+}
+
+// inBound returns the sites in the bound.  A bound whose minimum
+// longitude is greater than its maximum longitude crosses the 180th
+// meridian, and is searched in two parts.
+func (ix *Index) inBound(b orb.Bound) []*site {
+
+	var found []orb.Pointer
+	if b.Min[0] > b.Max[0] {
+		// InBound reuses its buffer, so the parts are found separately
+		west := orb.Bound{Min: b.Min, Max: orb.Point{180, b.Max[1]}}
+		east := orb.Bound{Min: orb.Point{-180, b.Min[1]}, Max: b.Max}
+		found = ix.qt.InBound(nil, west)
+		found = append(found, ix.qt.InBound(nil, east)...)
+	} else {
+		found = ix.qt.InBound(found, b)
+	}
+
+	sites := make([]*site, len(found))
+	for i, p := range found {
+		sites[i] = p.(*site)
+	}
+
+	return sites
+}
@@ -0,0 +1,132 @@
+package spatial
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+)
+
+// samplePeople returns people born on both sides of the 180th
+// meridian.  Only the person born in London has a known place of
+// death.
+func samplePeople() *notable.People {
+
+	persons := []notable.Person{
+		{PrsLabel: "A", BLocLabel: "Suva", BLocLat: -18.1, BLocLong: 178.4},
+		{PrsLabel: "B", BLocLabel: "Apia", BLocLat: -13.8, BLocLong: -171.8},
+		{PrsLabel: "C", BLocLabel: "Suva", BLocLat: -18.1, BLocLong: 178.4},
+		{PrsLabel: "D", BLocLabel: "London", BLocLat: 51.5, BLocLong: -0.1,
+			DLocLabel: "London", DLocLat: 51.5, DLocLong: -0.1},
+		{PrsLabel: "E"},
+		{PrsLabel: "F", BLocLabel: "Taveuni", BLocLat: -16.8, BLocLong: -179.97},
+	}
+	persons[4].SetNA(notable.FieldBLocLat)
+	persons[4].SetNA(notable.FieldBLocLong)
+	for i := range persons {
+		if i != 3 {
+			persons[i].SetNA(notable.FieldDLocLat)
+			persons[i].SetNA(notable.FieldDLocLong)
+		}
+	}
+
+	people := new(notable.People)
+	for _, p := range persons {
+		people.Append(p)
+	}
+
+	return people
+}
+
+func TestIndexQueries(t *testing.T) {
+
+	ix, err := New(samplePeople(), Birth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ix.Len() != 5 {
+		t.Errorf("%d rows indexed, want 5", ix.Len())
+	}
+
+	cases := []struct {
+		name string
+		got  []int
+		want []int
+	}{
+		{"box across the meridian", ix.Box(-20, 178, -10, -170), []int{0, 1, 2, 5}},
+		{"box west of the meridian", ix.Box(-20, 178, -10, 180), []int{0, 2}},
+		{"box", ix.Box(50, -1, 52, 1), []int{3}},
+		{"empty box", ix.Box(0, 0, 10, 10), nil},
+		{"within, across the meridian", ix.Within(-18, 179.9, 300), []int{0, 2, 5}},
+		{"within, east of the meridian", ix.Within(-16.8, -179.9, 50), []int{5}},
+		{"within", ix.Within(51.5, -0.1, 1), []int{3}},
+	}
+
+	for _, c := range cases {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Errorf("%s: rows %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+// People at the same distance are ordered by row.
+func TestIndexNearest(t *testing.T) {
+
+	ix, err := New(samplePeople(), Birth)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		lat, long float64
+		k         int
+		rows      []int
+	}{
+		{-18.1, 178.4, 1, []int{0}},
+		{-18.1, 178.4, 2, []int{0, 2}},
+		{-18.1, 178.4, 3, []int{0, 2, 5}},
+		{-16.8, 179.9, 1, []int{5}},
+		{51.5, -0.1, 10, []int{3, 1, 5, 0, 2}},
+		{0, 0, 0, nil},
+	}
+
+	for _, c := range cases {
+		var rows []int
+		for _, nb := range ix.Nearest(c.lat, c.long, c.k) {
+			rows = append(rows, nb.Row)
+		}
+		if !reflect.DeepEqual(rows, c.rows) {
+			t.Errorf("%d nearest to (%g, %g): rows %v, want %v", c.k, c.lat, c.long, rows, c.rows)
+		}
+	}
+
+	if nb := ix.Nearest(-18.1, 178.4, 1); nb[0].Km != 0 {
+		t.Errorf("distance %f to the same point", nb[0].Km)
+	}
+}
+
+func TestNewEvents(t *testing.T) {
+
+	ix, err := New(samplePeople(), Death)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ix.Len() != 1 || !reflect.DeepEqual(ix.Within(51.5, -0.1, 1), []int{3}) {
+		t.Errorf("death index holds %d rows", ix.Len())
+	}
+
+	bad := samplePeople()
+	bad.BLocLat[0] = 95
+	if _, err := New(bad, Birth); err == nil {
+		t.Errorf("no error for a latitude of 95")
+	}
+
+	for _, e := range []Event{Birth, Death} {
+		if got, err := ParseEvent(e.String()); err != nil || got != e {
+			t.Errorf("ParseEvent(%q) = %s, %v", e.String(), got, err)
+		}
+	}
+	if _, err := ParseEvent("marriage"); err == nil {
+		t.Errorf("unknown event parsed")
+	}
+}
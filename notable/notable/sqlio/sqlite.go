@@ -0,0 +1,282 @@
+// Package sqlio loads the notable people data into a SQLite database,
+// so that ad-hoc questions can be answered with SQL.  It uses a pure
+// Go SQLite driver, so no C compiler is needed.
+//
+// The database holds two tables.  The people table has one row per
+// person, with the same columns as notable.Person and NULL for
+// missing years and coordinates.  It is indexed by birth and death
+// location and year.  The locations table is derived from the people
+// table, and has one row per distinct location label, with the mean
+// coordinates and the numbers of births and deaths at the location.
+//
+// For example, to find the people born in Paris who died elsewhere
+// after 1800:
+//
+//	SELECT PrsLabel, DLocLabel, DYear FROM people
+//	WHERE BLocLabel = 'Paris' AND DLocLabel <> 'Paris' AND DYear > 1800;
+package sqlio
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+	_ "modernc.org/sqlite"
+)
+
+// createStmts create the people table, replacing any existing
+// tables.  They run in the same transaction as the load, so that the
+// old tables are kept if the load fails.
+var createStmts = []string{
+	`DROP TABLE IF EXISTS people`,
+	`DROP TABLE IF EXISTS locations`,
+	`CREATE TABLE people (
+		id INTEGER PRIMARY KEY,
+		PrsLabel TEXT NOT NULL,
+		BYear INTEGER,
+		BLocLabel TEXT NOT NULL,
+		BLocLat REAL,
+		BLocLong REAL,
+		DYear INTEGER,
+		DLocLabel TEXT NOT NULL,
+		DLocLat REAL,
+		DLocLong REAL,
+		Gender TEXT NOT NULL
+	)`,
+}
+
+// indexStmts index the people table and create the locations table.
+// They run after all the people have been inserted, which is faster
+// than maintaining the indexes during the load.
+var indexStmts = []string{
+	`CREATE INDEX people_bloc ON people (BLocLabel, BYear)`,
+	`CREATE INDEX people_dloc ON people (DLocLabel, DYear)`,
+	`CREATE INDEX people_byear ON people (BYear)`,
+	`CREATE INDEX people_dyear ON people (DYear)`,
+	`CREATE TABLE locations (
+		Label TEXT PRIMARY KEY,
+		Lat REAL,
+		Long REAL,
+		Births INTEGER NOT NULL,
+		Deaths INTEGER NOT NULL
+	)`,
+	`INSERT INTO locations
+	SELECT Label, AVG(Lat), AVG(Long), SUM(Births), SUM(Deaths) FROM (
+		SELECT BLocLabel AS Label, BLocLat AS Lat, BLocLong AS Long, 1 AS Births, 0 AS Deaths
+		FROM people
+		UNION ALL
+		SELECT DLocLabel, DLocLat, DLocLong, 0, 1 FROM people
+	) GROUP BY Label`,
+}
+
+const insertStmt = `INSERT INTO people
+	(PrsLabel, BYear, BLocLabel, BLocLat, BLocLong, DYear, DLocLabel, DLocLat, DLocLong, Gender)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// Writer loads Person records into a SQLite database.  The tables are
+// replaced and the records inserted in a single transaction, which is
+// committed by Close, or rolled back by Abort or by Close after a
+// failed Write.  Either way, the database holds the old tables or the
+// complete new ones.
+type Writer struct {
+	db   *sql.DB
+	tx   *sql.Tx
+	stmt *sql.Stmt
+
+	// The first error returned by Write
+	err error
+}
+
+// Create opens (creating if needed) the SQLite database in the named
+// file, and replaces the people and locations tables with empty ones.
+func Create(fname string) (*Writer, error) {
+
+	db, err := sql.Open("sqlite", fname)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{db: db}
+	if err := w.init(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlio: %s: %v", fname, err)
+	}
+
+	return w, nil
+}
+
+// init starts the transaction, creates the tables and prepares the
+// insert statement.
+func (w *Writer) init() error {
+
+	var err error
+	if w.tx, err = w.db.Begin(); err != nil {
+		return err
+	}
+
+	for _, s := range createStmts {
+		if _, err := w.tx.Exec(s); err != nil {
+			w.tx.Rollback()
+			return err
+		}
+	}
+
+	if w.stmt, err = w.tx.Prepare(insertStmt); err != nil {
+		w.tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// Write adds one person to the people table.  If it fails, Close
+// rolls back the load.
+func (w *Writer) Write(p notable.Person) error {
+
+	// Missing values are stored as NULL
+	value := func(f notable.Field, v interface{}) interface{} {
+		if p.IsNA(f) {
+			return nil
+		}
+		return v
+	}
+
+	_, err := w.stmt.Exec(
+		p.PrsLabel,
+		value(notable.FieldBYear, p.BYear),
+		p.BLocLabel,
+		value(notable.FieldBLocLat, p.BLocLat),
+		value(notable.FieldBLocLong, p.BLocLong),
+		value(notable.FieldDYear, p.DYear),
+		p.DLocLabel,
+		value(notable.FieldDLocLat, p.DLocLat),
+		value(notable.FieldDLocLong, p.DLocLong),
+		p.Gender,
+	)
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+
+	return err
+}
+
+// Close builds the indexes and the locations table, commits the load
+// and closes the database.  If a call to Write failed, the load is
+// rolled back instead, and the error from Write is returned.
+// Otherwise Close returns the first error that occurs, and the load
+// is rolled back if it could not be committed.
+func (w *Writer) Close() error {
+
+	if w.err != nil {
+		w.Abort()
+		return w.err
+	}
+
+	err := w.stmt.Close()
+	for _, s := range indexStmts {
+		if err != nil {
+			break
+		}
+		_, err = w.tx.Exec(s)
+	}
+
+	if err == nil {
+		err = w.tx.Commit()
+	} else {
+		w.tx.Rollback()
+	}
+
+	if cerr := w.db.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// Abort rolls back the load, leaving the database as it was before
+// Create, and closes the database.  It is used in place of Close when
+// the records cannot all be written, such as after a read error.
+func (w *Writer) Abort() error {
+
+	w.stmt.Close()
+	err := w.tx.Rollback()
+	if cerr := w.db.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// ReadPeople returns the people in the database at fname that satisfy
+// the given SQL condition, in the order they were loaded.  If where is
+// empty, all the people are returned.  The args fill any placeholders
+// in where.
+func ReadPeople(fname, where string, args ...interface{}) (*notable.People, error) {
+
+	db, err := sql.Open("sqlite", fname)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	q := `SELECT PrsLabel, BYear, BLocLabel, BLocLat, BLocLong,
+		DYear, DLocLabel, DLocLat, DLocLong, Gender FROM people`
+	if where != "" {
+		q += " WHERE " + where
+	}
+	q += " ORDER BY id"
+
+	rows, err := db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	people := new(notable.People)
+	for rows.Next() {
+
+		var p notable.Person
+		var byear, dyear sql.NullInt64
+		var blat, blong, dlat, dlong sql.NullFloat64
+
+		if err := rows.Scan(&p.PrsLabel, &byear, &p.BLocLabel, &blat, &blong,
+			&dyear, &p.DLocLabel, &dlat, &dlong, &p.Gender); err != nil {
+			return nil, err
+		}
+
+		setInt(&p, notable.FieldBYear, byear, &p.BYear)
+		setFloat(&p, notable.FieldBLocLat, blat, &p.BLocLat)
+		setFloat(&p, notable.FieldBLocLong, blong, &p.BLocLong)
+		setInt(&p, notable.FieldDYear, dyear, &p.DYear)
+		setFloat(&p, notable.FieldDLocLat, dlat, &p.DLocLat)
+		setFloat(&p, notable.FieldDLocLong, dlong, &p.DLocLong)
+
+		people.Append(p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return people, nil
+}
+
+// setInt stores v in dst, or marks field f of p as missing if v is
+// NULL.
+func setInt(p *notable.Person, f notable.Field, v sql.NullInt64, dst *int) {
+	if !v.Valid {
+		p.SetNA(f)
+		return
+	}
+	*dst = int(v.Int64)
+}
+
+// setFloat stores v in dst, or marks field f of p as missing if v is
+// NULL.
+func setFloat(p *notable.Person, f notable.Field, v sql.NullFloat64, dst *float64) {
+	if !v.Valid {
+		p.SetNA(f)
+		return
+	}
+	*dst = v.Float64
+}
@@ -0,0 +1,153 @@
+package sqlio
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kshedden/godata_workshop/notable/notable"
+	"github.com/kshedden/godata_workshop/notable/notable/internal/fixture"
+)
+
+// load writes the people to the named database.
+func load(t *testing.T, fname string, people *notable.People) {
+
+	t.Helper()
+
+	w, err := Create(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < people.Len(); i++ {
+		if err := w.Write(people.Row(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadPeople(t *testing.T) {
+
+	people := fixture.People()
+	fname := filepath.Join(t.TempDir(), "people.db")
+
+	// Loading again replaces the tables
+	load(t, fname, people)
+	load(t, fname, people)
+
+	cases := []struct {
+		where string
+		args  []interface{}
+		rows  []int
+	}{
+		{"", nil, []int{0, 1, 2}},
+		{"DYear IS NULL", nil, []int{1, 2}},
+		{"BYear < ?", []interface{}{0}, []int{2}},
+		{"BLocLabel = ? AND Gender = ?", []interface{}{"London", "female"}, []int{0}},
+		{"BLocLabel = 'Paris'", nil, nil},
+	}
+
+	for _, c := range cases {
+		got, err := ReadPeople(fname, c.where, c.args...)
+		if err != nil {
+			t.Errorf("%q: %v", c.where, err)
+			continue
+		}
+		want := people.Take(c.rows)
+		if got.Len() != want.Len() {
+			t.Errorf("%q: %d people, want %d", c.where, got.Len(), want.Len())
+			continue
+		}
+		for i := 0; i < want.Len(); i++ {
+			if !reflect.DeepEqual(got.Row(i), want.Row(i)) {
+				t.Errorf("%q: row %d is %+v, want %+v", c.where, i, got.Row(i), want.Row(i))
+			}
+		}
+	}
+}
+
+// The locations table counts births and deaths, and averages the
+// known coordinates.
+func TestLocations(t *testing.T) {
+
+	fname := filepath.Join(t.TempDir(), "people.db")
+	load(t, fname, fixture.People())
+
+	db, err := sql.Open("sqlite", fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	cases := []struct {
+		label          string
+		lat            sql.NullFloat64
+		births, deaths int
+	}{
+		{"", sql.NullFloat64{}, 0, 1},
+		{"Bryn Mawr", sql.NullFloat64{Float64: 40, Valid: true}, 0, 1},
+		{"London", sql.NullFloat64{Float64: 51.5, Valid: true}, 1, 1},
+		{"Memphis", sql.NullFloat64{}, 1, 0},
+	}
+
+	for _, c := range cases {
+		var lat sql.NullFloat64
+		var births, deaths int
+		err := db.QueryRow(`SELECT Lat, Births, Deaths FROM locations WHERE Label = ?`, c.label).
+			Scan(&lat, &births, &deaths)
+		if err != nil {
+			t.Errorf("%q: %v", c.label, err)
+			continue
+		}
+		if lat != c.lat || births != c.births || deaths != c.deaths {
+			t.Errorf("%q: %v, %d births and %d deaths, want %v, %d and %d",
+				c.label, lat, births, deaths, c.lat, c.births, c.deaths)
+		}
+	}
+}
+
+// A load that is aborted, or in which a write fails, leaves the old
+// tables in place.
+func TestAbort(t *testing.T) {
+
+	people := fixture.People()
+	fname := filepath.Join(t.TempDir(), "people.db")
+	load(t, fname, people)
+
+	w, err := Create(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(people.Row(0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Abort(); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err = Create(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(people.Row(0)); err != nil {
+		t.Fatal(err)
+	}
+	w.stmt.Close()
+	if err := w.Write(people.Row(1)); err == nil {
+		t.Fatalf("write with a closed statement succeeded")
+	}
+	if err := w.Close(); err == nil {
+		t.Errorf("Close after a failed write succeeded")
+	}
+
+	got, err := ReadPeople(fname, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Len() != people.Len() {
+		t.Errorf("%d people after the failed loads, want %d", got.Len(), people.Len())
+	}
+}
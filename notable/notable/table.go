@@ -0,0 +1,288 @@
+package notable
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Table holds the results of a grouped summary in tidy form: each
+// row is one group, given by the values of the key columns, followed
+// by the numeric summaries of the group.
+type Table struct {
+
+	// The names of the key columns
+	KeyNames []string
+
+	// The names of the value columns
+	ValueNames []string
+
+	// The values of the key columns in each row
+	Keys [][]string
+
+	// The values of the value columns in each row
+	Values [][]float64
+}
+
+// Len returns the number of rows in the table.
+func (t *Table) Len() int {
+	return len(t.Keys)
+}
+
+// Swap exchanges two rows of the table.
+func (t *Table) Swap(i, j int) {
+	t.Keys[i], t.Keys[j] = t.Keys[j], t.Keys[i]
+	t.Values[i], t.Values[j] = t.Values[j], t.Values[i]
+}
+
+// Sort sorts the rows by the values of the key columns, comparing the
+// first column first.
+func (t *Table) Sort() {
+	sort.Sort(byKey{t})
+}
+
+// byKey orders the rows of a table by their keys.
+type byKey struct {
+	*Table
+}
+
+func (b byKey) Less(i, j int) bool {
+	ki, kj := b.Keys[i], b.Keys[j]
+	for c := range ki {
+		if ki[c] != kj[c] {
+			return ki[c] < kj[c]
+		}
+	}
+	return false
+}
+
+// SortBy sorts the rows by the named value column, in increasing
+// order, or in decreasing order if descending is true.  Rows with
+// equal values are sorted by their keys, and NaN values come last.
+func (t *Table) SortBy(name string, descending bool) error {
+
+	j := t.Value(name)
+	if j == -1 {
+		return fmt.Errorf("notable: table has no column %q", name)
+	}
+
+	t.Sort()
+	sort.Stable(byValue{t, j, descending})
+
+	return nil
+}
+
+// byValue orders the rows of a table by one of their values.
+type byValue struct {
+	*Table
+	col        int
+	descending bool
+}
+
+func (b byValue) Less(i, j int) bool {
+	x, y := b.Values[i][b.col], b.Values[j][b.col]
+	switch {
+	case math.IsNaN(x):
+		return false
+	case math.IsNaN(y):
+		return true
+	case b.descending:
+		return x > y
+	default:
+		return x < y
+	}
+}
+
+// AtLeast removes the rows in which the named value column is less
+// than min, or is NaN.
+func (t *Table) AtLeast(name string, min float64) error {
+
+	j := t.Value(name)
+	if j == -1 {
+		return fmt.Errorf("notable: table has no column %q", name)
+	}
+
+	var keys [][]string
+	var values [][]float64
+	for i, row := range t.Values {
+		if row[j] >= min {
+			keys = append(keys, t.Keys[i])
+			values = append(values, row)
+		}
+	}
+	t.Keys, t.Values = keys, values
+
+	return nil
+}
+
+// Value returns the index of the named value column, or -1 if there
+// is no such column.
+func (t *Table) Value(name string) int {
+	for j, n := range t.ValueNames {
+		if n == name {
+			return j
+		}
+	}
+	return -1
+}
+
+// Column returns the values of the named value column.
+func (t *Table) Column(name string) ([]float64, error) {
+
+	j := t.Value(name)
+	if j == -1 {
+		return nil, fmt.Errorf("notable: table has no column %q", name)
+	}
+
+	x := make([]float64, t.Len())
+	for i, row := range t.Values {
+		x[i] = row[j]
+	}
+
+	return x, nil
+}
+
+// TableFormats holds the names of the formats that Write accepts.
+var TableFormats = []string{"csv", "json", "markdown"}
+
+// Write writes the table in the named format, one of TableFormats.
+func (t *Table) Write(w io.Writer, format string) error {
+	switch format {
+	case "csv":
+		return t.WriteCSV(w)
+	case "json":
+		return t.WriteJSON(w)
+	case "markdown":
+		return t.WriteMarkdown(w)
+	default:
+		return fmt.Errorf("notable: unknown table format %q", format)
+	}
+}
+
+// formatValue formats a number with as many digits as needed to
+// represent it exactly.
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// WriteCSV writes the table in CSV format, starting with a row of
+// column names.  Numbers are written with as many digits as needed
+// to represent them exactly.
+func (t *Table) WriteCSV(w io.Writer) error {
+
+	cw := csv.NewWriter(w)
+
+	header := append(append([]string{}, t.KeyNames...), t.ValueNames...)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	row := make([]string, len(header))
+	for i := range t.Keys {
+		copy(row, t.Keys[i])
+		for j, v := range t.Values[i] {
+			row[len(t.KeyNames)+j] = formatValue(v)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the table as json lines, with one object per row
+// holding the values of every column.  NaN values are written as
+// null.
+func (t *Table) WriteJSON(w io.Writer) error {
+
+	for i := range t.Keys {
+
+		// Build the object by hand to keep the columns in order
+		var b strings.Builder
+		b.WriteString("{")
+		for j, name := range t.KeyNames {
+			if j > 0 {
+				b.WriteString(",")
+			}
+			writeJSONString(&b, name)
+			b.WriteString(":")
+			writeJSONString(&b, t.Keys[i][j])
+		}
+		for j, name := range t.ValueNames {
+			if j > 0 || len(t.KeyNames) > 0 {
+				b.WriteString(",")
+			}
+			writeJSONString(&b, name)
+			b.WriteString(":")
+			if v := t.Values[i][j]; math.IsNaN(v) || math.IsInf(v, 0) {
+				b.WriteString("null")
+			} else {
+				b.WriteString(formatValue(v))
+			}
+		}
+		b.WriteString("}\n")
+
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeJSONString writes s as a quoted json string.
+func writeJSONString(b *strings.Builder, s string) {
+	q, _ := json.Marshal(s)
+	b.Write(q)
+}
+
+// WriteMarkdown writes the table as a Markdown (GitHub) table, with
+// the value columns aligned to the right.
+func (t *Table) WriteMarkdown(w io.Writer) error {
+
+	escape := strings.NewReplacer("|", "\\|", "\n", " ")
+
+	var b strings.Builder
+	b.WriteString("|")
+	for _, name := range t.KeyNames {
+		b.WriteString(" " + escape.Replace(name) + " |")
+	}
+	for _, name := range t.ValueNames {
+		b.WriteString(" " + escape.Replace(name) + " |")
+	}
+	b.WriteString("\n|")
+	for range t.KeyNames {
+		b.WriteString(" --- |")
+	}
+	for range t.ValueNames {
+		b.WriteString(" ---: |")
+	}
+	b.WriteString("\n")
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return err
+	}
+
+	for i := range t.Keys {
+		b.Reset()
+		b.WriteString("|")
+		for _, k := range t.Keys[i] {
+			b.WriteString(" " + escape.Replace(k) + " |")
+		}
+		for _, v := range t.Values[i] {
+			b.WriteString(" " + formatValue(v) + " |")
+		}
+		b.WriteString("\n")
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
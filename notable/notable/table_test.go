@@ -0,0 +1,110 @@
+package notable
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// sampleTable returns a small table with a NaN value and a tie.
+func sampleTable() *Table {
+	return &Table{
+		KeyNames:   []string{"BLocLabel"},
+		ValueNames: []string{"mean_BYear", "count"},
+		Keys:       [][]string{{"Rome"}, {"Alexandria"}, {"Paris|Nord"}, {"Berlin"}},
+		Values:     [][]float64{{1400.5, 2}, {math.NaN(), 1}, {1300, 2}, {1500, 3}},
+	}
+}
+
+// keys returns the first key of each row.
+func keys(tab *Table) []string {
+
+	var k []string
+	for _, row := range tab.Keys {
+		k = append(k, row[0])
+	}
+
+	return k
+}
+
+func TestTableSort(t *testing.T) {
+
+	cases := []struct {
+		name       string
+		descending bool
+		want       []string
+	}{
+		{"count", true, []string{"Berlin", "Paris|Nord", "Rome", "Alexandria"}},
+		{"count", false, []string{"Alexandria", "Paris|Nord", "Rome", "Berlin"}},
+		{"mean_BYear", false, []string{"Paris|Nord", "Rome", "Berlin", "Alexandria"}},
+		{"mean_BYear", true, []string{"Berlin", "Rome", "Paris|Nord", "Alexandria"}},
+	}
+
+	for _, c := range cases {
+		tab := sampleTable()
+		if err := tab.SortBy(c.name, c.descending); err != nil {
+			t.Fatal(err)
+		}
+		if got := keys(tab); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s, descending %t: %q, want %q", c.name, c.descending, got, c.want)
+		}
+	}
+
+	tab := sampleTable()
+	tab.Sort()
+	if got := keys(tab); !reflect.DeepEqual(got, []string{"Alexandria", "Berlin", "Paris|Nord", "Rome"}) {
+		t.Errorf("sorted by name: %q", got)
+	}
+	if err := tab.SortBy("median_BYear", false); err == nil {
+		t.Errorf("no error for an unknown column")
+	}
+}
+
+// NaN values are less than any threshold.
+func TestTableAtLeast(t *testing.T) {
+
+	tab := sampleTable()
+	if err := tab.AtLeast("mean_BYear", 1400); err != nil {
+		t.Fatal(err)
+	}
+	if got := keys(tab); !reflect.DeepEqual(got, []string{"Rome", "Berlin"}) {
+		t.Errorf("rows %q", got)
+	}
+
+	if err := tab.AtLeast("size", 1); err == nil {
+		t.Errorf("no error for an unknown column")
+	}
+}
+
+func TestTableWrite(t *testing.T) {
+
+	tab := sampleTable()
+	tab.Keys, tab.Values = tab.Keys[0:3], tab.Values[0:3]
+
+	cases := []struct {
+		format string
+		want   string
+	}{
+		{"csv", "BLocLabel,mean_BYear,count\nRome,1400.5,2\nAlexandria,NaN,1\nParis|Nord,1300,2\n"},
+		{"json", `{"BLocLabel":"Rome","mean_BYear":1400.5,"count":2}` + "\n" +
+			`{"BLocLabel":"Alexandria","mean_BYear":null,"count":1}` + "\n" +
+			`{"BLocLabel":"Paris|Nord","mean_BYear":1300,"count":2}` + "\n"},
+		{"markdown", "| BLocLabel | mean_BYear | count |\n| --- | ---: | ---: |\n" +
+			"| Rome | 1400.5 | 2 |\n| Alexandria | NaN | 1 |\n| Paris\\|Nord | 1300 | 2 |\n"},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		if err := tab.Write(&buf, c.format); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != c.want {
+			t.Errorf("%s:\n%s\nwant:\n%s", c.format, buf.String(), c.want)
+		}
+	}
+
+	if err := tab.Write(new(bytes.Buffer), "xml"); err == nil {
+		t.Errorf("no error for an unknown format")
+	}
+}
@@ -0,0 +1,187 @@
+package notable
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultCompression is the compression of a TDigest used by
+// ApproxQuantiles.  A digest holds at most about this many centroids.
+const DefaultCompression = 100
+
+// A TDigest summarizes a stream of numbers so that their quantiles can
+// be estimated, using a fixed amount of memory.  The values are
+// grouped into centroids, each holding the mean and number of a run of
+// adjacent values.  Centroids near the extremes hold few values, so
+// that the tails of the distribution are estimated accurately.  This
+// is the merging t-digest of Dunning and Ertl (2019), with the scale
+// function k1.
+//
+// Digests built from separate parts of the data can be merged, giving
+// nearly the same result as a digest of all the data.
+type TDigest struct {
+
+	// Determines the number of centroids that are kept
+	compression float64
+
+	// The centroids, sorted by mean
+	centroids []centroid
+
+	// Values and centroids added since the last compression
+	buf []centroid
+
+	// The total weight of centroids and buf
+	total float64
+
+	// The smallest and largest values seen
+	min, max float64
+}
+
+// A centroid summarizes a run of adjacent values.
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// NewTDigest returns an empty digest with the given compression.
+// Larger values give more accurate quantiles and larger digests.
+func NewTDigest(compression float64) *TDigest {
+
+	if compression < 10 {
+		compression = 10
+	}
+
+	return &TDigest{compression: compression, min: math.Inf(1), max: math.Inf(-1)}
+}
+
+// Add includes a value in the digest.  NaN values are ignored.
+func (t *TDigest) Add(x float64) {
+
+	if math.IsNaN(x) {
+		return
+	}
+
+	t.add(centroid{x, 1})
+	t.min = math.Min(t.min, x)
+	t.max = math.Max(t.max, x)
+}
+
+// add buffers a centroid, compressing the digest when the buffer is
+// full.
+func (t *TDigest) add(c centroid) {
+
+	t.buf = append(t.buf, c)
+	t.total += c.weight
+
+	if len(t.buf) >= int(5*t.compression) {
+		t.compress()
+	}
+}
+
+// Merge includes the values summarized by another digest.
+func (t *TDigest) Merge(other *TDigest) {
+
+	for _, c := range other.centroids {
+		t.add(c)
+	}
+	for _, c := range other.buf {
+		t.add(c)
+	}
+
+	t.min = math.Min(t.min, other.min)
+	t.max = math.Max(t.max, other.max)
+}
+
+// Count returns the number of values in the digest.
+func (t *TDigest) Count() float64 {
+	return t.total
+}
+
+// scale maps a probability to the scale k1, on which every centroid
+// spans at most one unit.
+func (t *TDigest) scale(q float64) float64 {
+	return t.compression / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+// unscale is the inverse of scale.
+func (t *TDigest) unscale(k float64) float64 {
+
+	k = math.Max(-t.compression/4, math.Min(t.compression/4, k))
+
+	return (math.Sin(2*math.Pi*k/t.compression) + 1) / 2
+}
+
+// compress merges the buffered values into the centroids, combining
+// adjacent centroids as long as each spans at most one unit of the
+// scale.
+func (t *TDigest) compress() {
+
+	if len(t.buf) == 0 {
+		return
+	}
+
+	all := append(t.centroids, t.buf...)
+	sort.Slice(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+
+	merged := []centroid{all[0]}
+	before := 0.0
+	limit := t.unscale(t.scale(0) + 1)
+	for _, c := range all[1:] {
+		cur := &merged[len(merged)-1]
+		if (before+cur.weight+c.weight)/t.total <= limit {
+			cur.weight += c.weight
+			cur.mean += (c.mean - cur.mean) * c.weight / cur.weight
+			continue
+		}
+		before += cur.weight
+		limit = t.unscale(t.scale(before/t.total) + 1)
+		merged = append(merged, c)
+	}
+
+	t.centroids = merged
+	t.buf = t.buf[0:0]
+}
+
+// Quantile returns an estimate of the quantile at probability q, or
+// NaN if the digest is empty.  The estimate interpolates between the
+// centres of adjacent centroids, and between the extreme centroids
+// and the smallest and largest values.
+func (t *TDigest) Quantile(q float64) float64 {
+
+	t.compress()
+
+	n := len(t.centroids)
+	switch {
+	case n == 0:
+		return math.NaN()
+	case q <= 0:
+		return t.min
+	case q >= 1:
+		return t.max
+	case n == 1:
+		return t.centroids[0].mean
+	}
+
+	target := q * t.total
+
+	// Before the centre of the first centroid
+	first := t.centroids[0]
+	if target < first.weight/2 {
+		return t.min + (first.mean-t.min)*target/(first.weight/2)
+	}
+
+	// Between the centres of two centroids
+	pos := first.weight / 2
+	for i := 0; i < n-1; i++ {
+		c, next := t.centroids[i], t.centroids[i+1]
+		step := (c.weight + next.weight) / 2
+		if target <= pos+step {
+			return c.mean + (next.mean-c.mean)*(target-pos)/step
+		}
+		pos += step
+	}
+
+	// After the centre of the last centroid
+	last := t.centroids[n-1]
+	return last.mean + (t.max-last.mean)*math.Min(1, (target-pos)/(last.weight/2))
+}
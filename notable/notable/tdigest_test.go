@@ -0,0 +1,96 @@
+package notable
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// rank returns the fraction of the sorted values that are less than x.
+func rank(sorted []float64, x float64) float64 {
+	return float64(sort.SearchFloat64s(sorted, x)) / float64(len(sorted))
+}
+
+// The estimated quantiles are close in rank to the true ones, most of
+// all in the tails, whether the digest is built at once or merged
+// from parts.
+func TestTDigestAccuracy(t *testing.T) {
+
+	rng := rand.New(rand.NewSource(1))
+	dists := []struct {
+		name string
+		draw func() float64
+	}{
+		{"uniform", rng.Float64},
+		{"normal", rng.NormFloat64},
+		{"exponential", rng.ExpFloat64},
+	}
+
+	probs := []float64{0.001, 0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99, 0.999}
+
+	for _, d := range dists {
+		x := make([]float64, 100000)
+		whole := NewTDigest(DefaultCompression)
+		merged := NewTDigest(DefaultCompression)
+		parts := make([]*TDigest, 10)
+		for i := range parts {
+			parts[i] = NewTDigest(DefaultCompression)
+		}
+		for i := range x {
+			x[i] = d.draw()
+			whole.Add(x[i])
+			parts[i%len(parts)].Add(x[i])
+		}
+		for _, p := range parts {
+			merged.Merge(p)
+		}
+		sort.Float64s(x)
+
+		for _, td := range []*TDigest{whole, merged} {
+			if td.Count() != float64(len(x)) {
+				t.Errorf("%s: count %f, want %d", d.name, td.Count(), len(x))
+			}
+			for _, q := range probs {
+				// The error allowed shrinks in the tails
+				tol := 0.0005 + 0.005*math.Sqrt(q*(1-q))
+				if err := math.Abs(rank(x, td.Quantile(q)) - q); err > tol {
+					t.Errorf("%s: quantile %g has rank error %g", d.name, q, err)
+				}
+			}
+			if td.Quantile(0) != x[0] || td.Quantile(1) != x[len(x)-1] {
+				t.Errorf("%s: extremes %f and %f, want %f and %f", d.name,
+					td.Quantile(0), td.Quantile(1), x[0], x[len(x)-1])
+			}
+			if len(td.centroids) > DefaultCompression {
+				t.Errorf("%s: %d centroids", d.name, len(td.centroids))
+			}
+		}
+	}
+}
+
+func TestTDigestSmall(t *testing.T) {
+
+	cases := []struct {
+		values []float64
+		q      float64
+		want   float64
+	}{
+		{nil, 0.5, math.NaN()},
+		{[]float64{math.NaN()}, 0.5, math.NaN()},
+		{[]float64{7}, 0.3, 7},
+		{[]float64{1, 2, 3, 4, 5}, 0.5, 3},
+		{[]float64{1, 2, 3, 4, 5}, 0, 1},
+		{[]float64{1, 2, 3, 4, 5, math.NaN()}, 1, 5},
+	}
+
+	for _, c := range cases {
+		td := NewTDigest(DefaultCompression)
+		for _, x := range c.values {
+			td.Add(x)
+		}
+		if got := td.Quantile(c.q); !(got == c.want || math.IsNaN(got) && math.IsNaN(c.want)) {
+			t.Errorf("%v: quantile %g is %f, want %f", c.values, c.q, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package notable
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+)
+
+// Verification
+//
+// The same records are stored in several formats, each converted from
+// another (see the convert scripts).  Verify reads a set of these
+// files side by side, checking that each can be read in full, that
+// the number of records in its header agrees with the number read,
+// that the file it was converted from is known, and that every file
+// holds the same records as the first one.  Records are matched by
+// their position, so the files should be converted with missing
+// values kept.
+//
+// The checksum of each file is used to recognize it when it is named
+// as the source of another file.  Files do not record their own
+// checksums, so the checksum of a file is otherwise only reported.
+
+// VerifyOptions controls the checks made by Verify.
+type VerifyOptions struct {
+
+	// The number of records whose values are compared, chosen at
+	// random.  If zero, every record is compared.  Every record is
+	// read and counted either way.
+	Sample int
+
+	// Seeds the choice of records to compare
+	Seed int64
+
+	// The maximum number of mismatched values that are recorded;
+	// further mismatches are only counted.  Defaults to 100.
+	MaxMismatches int
+
+	// Additional files, such as the Data S1 workbook, that the files
+	// may have been converted from.  If any are given, a file whose
+	// header names a source that is neither one of these nor one of
+	// the files being verified is reported as stale.
+	Sources []string
+}
+
+// setDefaults fills in the options that are not set.
+func (o *VerifyOptions) setDefaults() {
+	if o.MaxMismatches <= 0 {
+		o.MaxMismatches = 100
+	}
+}
+
+// A FileCheck describes one of the files checked by Verify.
+type FileCheck struct {
+
+	// The name of the file
+	Name string
+
+	// The format of the records, if the file could be opened
+	Format Format
+
+	// The header of the file, or nil if it has none
+	Header *Header
+
+	// The SHA-256 checksum of the file, for information; it is not
+	// checked against anything
+	Checksum [sha256.Size]byte
+
+	// The file whose checksum is recorded as the source in the
+	// header, or "" if it is not known
+	Source string
+
+	// The number of records read
+	Records int
+
+	// Problems found with the file as a whole, such as read errors
+	// and a number of records that differs from the first file
+	Problems []string
+}
+
+// A Mismatch is a value that differs from the value in the same row
+// and column of the first file.
+type Mismatch struct {
+
+	// The file holding the value
+	File string
+
+	// The position of the record, counting from 0
+	Row int
+
+	// The column holding the value
+	Field Field
+
+	// The value in the first file and in File.  Missing values are
+	// given as "NA".
+	Want, Got string
+}
+
+// String returns a one-line description of the mismatch.
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s: row %d, %s: %q, expected %q", m.File, m.Row, m.Field, m.Got, m.Want)
+}
+
+// A VerifyReport holds the results of Verify.
+type VerifyReport struct {
+
+	// The files, in the order given
+	Files []FileCheck
+
+	// The number of records whose values were compared
+	Compared int
+
+	// The mismatched values, up to VerifyOptions.MaxMismatches
+	Mismatches []Mismatch
+
+	// The total number of mismatched values
+	NumMismatches int
+}
+
+// OK returns true if no problems or mismatches were found.
+func (r *VerifyReport) OK() bool {
+
+	for _, fc := range r.Files {
+		if len(fc.Problems) > 0 {
+			return false
+		}
+	}
+
+	return r.NumMismatches == 0
+}
+
+// Verify checks that the named files hold the same records, using the
+// first file as the reference.  Problems with the files are returned
+// in the report; the error is only set if a file cannot be read at
+// all.
+func Verify(fnames []string, opts VerifyOptions) (*VerifyReport, error) {
+
+	if len(fnames) < 2 {
+		return nil, fmt.Errorf("notable: at least two files are needed for verification")
+	}
+	opts.setDefaults()
+
+	// Map the checksum of every known file to its name
+	rep := &VerifyReport{Files: make([]FileCheck, len(fnames))}
+	known := make(map[[sha256.Size]byte]string)
+	for _, fname := range opts.Sources {
+		sum, err := Checksum(fname)
+		if err != nil {
+			return nil, err
+		}
+		known[sum] = fname
+	}
+	for i, fname := range fnames {
+		sum, err := Checksum(fname)
+		if err != nil {
+			return nil, err
+		}
+		rep.Files[i] = FileCheck{Name: fname, Checksum: sum}
+		if _, ok := known[sum]; !ok {
+			known[sum] = fname
+		}
+	}
+
+	// Open every file, skipping those that cannot be opened
+	rdrs := make([]*Reader, len(fnames))
+	for i, fname := range fnames {
+		fc := &rep.Files[i]
+		r, err := NewReader(fname)
+		if err != nil {
+			fc.Problems = append(fc.Problems, err.Error())
+			continue
+		}
+		defer r.Close()
+		rdrs[i] = r
+		fc.Format = r.Format()
+		fc.Header = r.Header()
+		fc.checkSource(known, len(opts.Sources) > 0)
+	}
+	if rdrs[0] == nil {
+		return rep, nil
+	}
+
+	sample, err := chooseSample(fnames[0], opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the files side by side
+	persons := make([]Person, len(fnames))
+	have := make([]bool, len(fnames))
+	for row := 0; ; row++ {
+
+		more := false
+		for i, r := range rdrs {
+			have[i] = r != nil && r.Next()
+			if have[i] {
+				persons[i] = r.Person()
+				rep.Files[i].Records++
+				more = true
+			}
+		}
+		if !more {
+			break
+		}
+
+		if !have[0] || (sample != nil && !sample[row]) {
+			continue
+		}
+		rep.Compared++
+		for i := 1; i < len(fnames); i++ {
+			if have[i] {
+				rep.compare(fnames[i], row, &persons[0], &persons[i], opts.MaxMismatches)
+			}
+		}
+	}
+
+	refRead := false
+	for i, r := range rdrs {
+		if r == nil {
+			continue
+		}
+		fc := &rep.Files[i]
+		// The reader reports a header giving the wrong number of
+		// records once it has read the file in full
+		err := r.Err()
+		if _, ok := err.(*rowCountError); ok {
+			err = nil
+		}
+		if err != nil {
+			fc.Problems = append(fc.Problems, fmt.Sprintf("reading stopped after %d records: %v", fc.Records, err))
+			continue
+		}
+		if fc.Header != nil && fc.Header.Rows >= 0 && fc.Header.Rows != int64(fc.Records) {
+			fc.Problems = append(fc.Problems, fmt.Sprintf("header gives %d records, but %d were read",
+				fc.Header.Rows, fc.Records))
+		}
+		if i == 0 {
+			refRead = true
+		} else if refRead && fc.Records != rep.Files[0].Records {
+			fc.Problems = append(fc.Problems, fmt.Sprintf("has %d records, but %s has %d",
+				fc.Records, fnames[0], rep.Files[0].Records))
+		}
+	}
+
+	return rep, nil
+}
+
+// checkSource finds the file named as the source in the header.  If
+// strict is true, a source that is not known is a problem.
+func (fc *FileCheck) checkSource(known map[[sha256.Size]byte]string, strict bool) {
+
+	if fc.Header == nil || !fc.Header.HasSource() {
+		return
+	}
+
+	fc.Source = known[fc.Header.Source]
+	if fc.Source == "" && strict {
+		fc.Problems = append(fc.Problems, fmt.Sprintf("converted from a file with checksum %s, which matches none of the files given",
+			hex.EncodeToString(fc.Header.Source[:])))
+	}
+}
+
+// chooseSample returns the rows whose values are compared, or nil if
+// every row is compared.  The records of the reference file are
+// counted, rather than taken from its header, since the header is one
+// of the things being verified.
+func chooseSample(fname string, opts VerifyOptions) (map[int]bool, error) {
+
+	if opts.Sample <= 0 {
+		return nil, nil
+	}
+
+	r, err := NewReader(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var n int
+	for n = 0; r.Next(); n++ {
+	}
+	if err := r.Err(); err != nil {
+		if _, ok := err.(*rowCountError); !ok {
+			return nil, err
+		}
+	}
+	if opts.Sample >= n {
+		return nil, nil
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	sample := make(map[int]bool)
+	for _, i := range rng.Perm(n)[0:opts.Sample] {
+		sample[i] = true
+	}
+
+	return sample, nil
+}
+
+// compare records the fields of got that differ from want.
+func (rep *VerifyReport) compare(fname string, row int, want, got *Person, max int) {
+
+	for _, f := range Fields() {
+		w, g := naString(want, f), naString(got, f)
+		if w == g {
+			continue
+		}
+		rep.NumMismatches++
+		if len(rep.Mismatches) < max {
+			rep.Mismatches = append(rep.Mismatches, Mismatch{File: fname, Row: row, Field: f, Want: w, Got: g})
+		}
+	}
+}
+
+// naString returns the value of a field as a string, or "NA" if it is
+// missing.
+func naString(p *Person, f Field) string {
+	if p.IsNA(f) {
+		return "NA"
+	}
+	return p.get(f)
+}
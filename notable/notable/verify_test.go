@@ -0,0 +1,166 @@
+package notable
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+
+	dir := t.TempDir()
+	people := samplePeople()
+
+	// The same records in three formats
+	var fnames []string
+	for _, c := range []struct {
+		name   string
+		format Format
+	}{
+		{"rows.csv.gz", CSVRows},
+		{"structs.gob.zst", GobStructs},
+		{"columns.ncol", ColumnGroups},
+	} {
+		fname := filepath.Join(dir, c.name)
+		writeFormat(t, fname, c.format, &people)
+		fnames = append(fnames, fname)
+	}
+
+	// A changed birth year, and a missing record
+	changed := samplePeople()
+	changed.BYear[1] = 1778
+	fchanged := filepath.Join(dir, "changed.json")
+	writeFormat(t, fchanged, JSONStructs, &changed)
+	short := people.Slice(0, 3)
+	fshort := filepath.Join(dir, "short.gob.gz")
+	writeFormat(t, fshort, GobStructs, &short)
+
+	rep, err := Verify(fnames, VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rep.OK() || rep.Compared != 4 {
+		t.Errorf("%d records compared, problems %+v, mismatches %v", rep.Compared, rep.Files, rep.Mismatches)
+	}
+	for i, fc := range rep.Files {
+		if fc.Records != 4 || fc.Format != []Format{CSVRows, GobStructs, ColumnGroups}[i] {
+			t.Errorf("%s: %d records in format %s", fc.Name, fc.Records, fc.Format)
+		}
+	}
+
+	rep, err = Verify([]string{fnames[0], fchanged, fshort}, VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Mismatch{File: fchanged, Row: 1, Field: FieldBYear, Want: "1777", Got: "1778"}
+	if rep.OK() || rep.NumMismatches != 1 || rep.Mismatches[0] != want {
+		t.Errorf("mismatches %v, want %v", rep.Mismatches, want)
+	}
+	if p := rep.Files[2].Problems; len(p) != 1 || !strings.Contains(p[0], "has 3 records") {
+		t.Errorf("problems with the short file: %q", p)
+	}
+
+	// Only the sampled records are compared, but all are read
+	rep, err = Verify([]string{fnames[0], fchanged}, VerifyOptions{Sample: 2, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Compared != 2 || rep.Files[1].Records != 4 {
+		t.Errorf("%d records compared and %d read", rep.Compared, rep.Files[1].Records)
+	}
+
+	if _, err := Verify(fnames[0:1], VerifyOptions{}); err == nil {
+		t.Errorf("no error for a single file")
+	}
+}
+
+// A file converted from a source that is not given is stale.
+func TestVerifySources(t *testing.T) {
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "source.csv")
+	other := filepath.Join(dir, "other.csv")
+	writeCSV(t, src)
+	if err := os.WriteFile(other, []byte("PrsLabel\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	people := samplePeople()
+	fname := filepath.Join(dir, "structs.gob.gz")
+	e, err := NewGobEncoder(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetSource(src); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < people.Len(); i++ {
+		e.Encode(people.Row(i))
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ref := filepath.Join(dir, "rows.csv.gz")
+	writeFormat(t, ref, CSVRows, &people)
+
+	cases := []struct {
+		sources []string
+		source  string
+		ok      bool
+	}{
+		{nil, "", true},
+		{[]string{src}, src, true},
+		{[]string{other}, "", false},
+	}
+
+	for _, c := range cases {
+		rep, err := Verify([]string{ref, fname}, VerifyOptions{Sources: c.sources})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fc := rep.Files[1]; fc.Source != c.source || rep.OK() != c.ok {
+			t.Errorf("sources %q: source %q, problems %q", c.sources, fc.Source, fc.Problems)
+		}
+	}
+}
+
+// A header that gives the wrong number of records is a problem, and
+// is not used to choose the sample.
+func TestVerifyHeaderRows(t *testing.T) {
+
+	dir := t.TempDir()
+	people := samplePeople()
+	ref := filepath.Join(dir, "rows.csv.gz")
+	writeFormat(t, ref, CSVRows, &people)
+
+	fname := filepath.Join(dir, "columns.gob.gz")
+	e, err := NewGobEncoder(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Encode(&people); err != nil {
+		t.Fatal(err)
+	}
+	e.fw.hdr.Rows = 40
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := Verify([]string{ref, fname}, VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := rep.Files[1].Problems; rep.OK() || len(p) != 1 || !strings.Contains(p[0], "header gives 40 records") {
+		t.Errorf("problems %q", p)
+	}
+
+	// Every sampled row exists, so all of them are compared
+	rep, err = Verify([]string{fname, ref}, VerifyOptions{Sample: 3, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Compared != 3 {
+		t.Errorf("%d records compared, want 3", rep.Compared)
+	}
+}
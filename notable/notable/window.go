@@ -0,0 +1,129 @@
+package notable
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Time windows
+//
+// Statistics that pool people from every era, such as the mean birth
+// year at a location, hide how the locations change over time.  A
+// Windows value divides the years into windows, so that the
+// statistics can be computed within each one.  The windows all have
+// the same width, and start every Step years from Origin.  If the step
+// equals the width the windows are disjoint, as with centuries or
+// decades; if it is smaller they overlap, and a year falls in more
+// than one window.
+
+// A Window is a range of years.
+type Window struct {
+
+	// The first year in the window
+	Start int
+
+	// The year after the last year in the window
+	End int
+}
+
+// String returns the window as the first and last year, such as
+// "1800 to 1899".
+func (w Window) String() string {
+	return fmt.Sprintf("%d to %d", w.Start, w.End-1)
+}
+
+// Windows describes a set of time windows of the same width.
+type Windows struct {
+
+	// The number of years in each window
+	Width int
+
+	// The number of years between the starts of consecutive windows.
+	// Defaults to the width, giving disjoint windows.
+	Step int
+
+	// The start of one of the windows, so that with the default
+	// value of zero centuries start at 1800, 1900 and so on
+	Origin int
+}
+
+// Centuries and Decades are disjoint windows of 100 and 10 years.
+var (
+	Centuries = Windows{Width: 100, Step: 100}
+	Decades   = Windows{Width: 10, Step: 10}
+)
+
+// ParseWindows returns the windows described by spec, which is
+// "century", "decade", a width in years, or a width and a step
+// separated by a slash.  For example, "50/10" gives windows of 50
+// years starting every 10 years.
+func ParseWindows(spec string) (Windows, error) {
+
+	switch strings.ToLower(spec) {
+	case "century":
+		return Centuries, nil
+	case "decade":
+		return Decades, nil
+	}
+
+	parts := strings.SplitN(spec, "/", 2)
+	width, err := strconv.Atoi(parts[0])
+	if err != nil || width <= 0 {
+		return Windows{}, fmt.Errorf("notable: invalid window %q", spec)
+	}
+	w := Windows{Width: width, Step: width}
+	if len(parts) == 2 {
+		if w.Step, err = strconv.Atoi(parts[1]); err != nil || w.Step <= 0 {
+			return Windows{}, fmt.Errorf("notable: invalid window step in %q", spec)
+		}
+	}
+
+	return w, nil
+}
+
+// String returns the width and step of the windows, in the form
+// accepted by ParseWindows.
+func (w Windows) String() string {
+	return fmt.Sprintf("%d/%d", w.Width, w.step())
+}
+
+// step returns the step, or the width if no step is set.
+func (w Windows) step() int {
+	if w.Step <= 0 {
+		return w.Width
+	}
+	return w.Step
+}
+
+// Of returns the windows that contain the given year, in order.
+func (w Windows) Of(year int) []Window {
+
+	if w.Width <= 0 {
+		panic("notable: windows must have a positive width")
+	}
+	step := w.step()
+
+	// The windows starting at Origin+j*step for j from first to
+	// last contain the year.
+	last := floorDiv(year-w.Origin, step)
+	first := floorDiv(year-w.Origin-w.Width, step) + 1
+
+	var wins []Window
+	for j := first; j <= last; j++ {
+		start := w.Origin + j*step
+		wins = append(wins, Window{Start: start, End: start + w.Width})
+	}
+
+	return wins
+}
+
+// floorDiv returns a/b rounded down, for b > 0, so that years before
+// the origin are placed correctly.
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
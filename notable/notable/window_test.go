@@ -0,0 +1,82 @@
+package notable
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWindowsOf(t *testing.T) {
+
+	cases := []struct {
+		w    Windows
+		year int
+		want []Window
+	}{
+		{Centuries, 1850, []Window{{1800, 1900}}},
+		{Centuries, 1800, []Window{{1800, 1900}}},
+		{Centuries, 0, []Window{{0, 100}}},
+		{Centuries, -1, []Window{{-100, 0}}},
+		{Centuries, -100, []Window{{-100, 0}}},
+		{Centuries, -101, []Window{{-200, -100}}},
+		{Windows{Width: 100}, 1999, []Window{{1900, 2000}}},
+		{Windows{Width: 100, Origin: 50}, 1849, []Window{{1750, 1850}}},
+		{Windows{Width: 100, Origin: 50}, -51, []Window{{-150, -50}}},
+
+		// Overlapping windows
+		{Windows{Width: 50, Step: 10}, 1855, []Window{{1810, 1860}, {1820, 1870}, {1830, 1880},
+			{1840, 1890}, {1850, 1900}}},
+		{Windows{Width: 20, Step: 10}, -5, []Window{{-20, 0}, {-10, 10}}},
+		{Windows{Width: 20, Step: 10}, -10, []Window{{-20, 0}, {-10, 10}}},
+
+		// Windows with gaps between them
+		{Windows{Width: 10, Step: 20}, 1805, []Window{{1800, 1810}}},
+		{Windows{Width: 10, Step: 20}, 1815, nil},
+	}
+
+	for _, c := range cases {
+		if got := c.w.Of(c.year); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s windows of %d: %v, want %v", c.w, c.year, got, c.want)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("no panic for windows of width zero")
+		}
+	}()
+	Windows{}.Of(1800)
+}
+
+func TestParseWindows(t *testing.T) {
+
+	cases := []struct {
+		spec string
+		want Windows
+		name string
+	}{
+		{"century", Centuries, "100/100"},
+		{"Decade", Decades, "10/10"},
+		{"25", Windows{Width: 25, Step: 25}, "25/25"},
+		{"50/10", Windows{Width: 50, Step: 10}, "50/10"},
+	}
+
+	for _, c := range cases {
+		w, err := ParseWindows(c.spec)
+		if err != nil || w != c.want {
+			t.Errorf("ParseWindows(%q) = %+v, %v", c.spec, w, err)
+		}
+		if w.String() != c.name {
+			t.Errorf("%q: name %q, want %q", c.spec, w.String(), c.name)
+		}
+	}
+
+	for _, spec := range []string{"", "year", "0", "-10", "50/", "50/0", "50/x"} {
+		if _, err := ParseWindows(spec); err == nil {
+			t.Errorf("ParseWindows(%q): no error", spec)
+		}
+	}
+
+	if s := (Window{Start: -100, End: 0}).String(); s != "-100 to -1" {
+		t.Errorf("window name %q", s)
+	}
+}